@@ -4,19 +4,19 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/mattn/go-sqlite3"
-	"log"
+	"os"
 )
 
 /*
 	SQLiteRepository struct abstracts SQLite database
 
-	db: reference to a database, enabling db operations
+	db: reference to a database, or the transaction of Transaction, enabling
+	db operations
 	currentTable: the table that certain statements will be ran against
 */
 
 type SQLiteRepository struct {
-	db           *sql.DB
+	db           sqlConn
 	currentTable string
 }
 
@@ -34,11 +34,38 @@ func NewSQLiteRepository(db *sql.DB) (*SQLiteRepository, error) {
 	}
 
 	return &SQLiteRepository{
-		db:           db,
+		db:           dbConn{db},
 		currentTable: DEFAULT_TABLE,
 	}, nil
 }
 
+/*
+	Opens the SQLite database file at path and returns a SQLiteRepository for
+	it. The file is not created or initialized.
+*/
+
+func OpenSQLite(path string) (*SQLiteRepository, error) {
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlite, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, storageError("open database", err)
+	}
+
+	return NewSQLiteRepository(sqlite)
+}
+
+/*
+	Closes the SQLite database.
+*/
+
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
 func (r *SQLiteRepository) CurrentTable() string {
 	return r.currentTable
 }
@@ -46,26 +73,28 @@ func (r *SQLiteRepository) CurrentTable() string {
 /*
 	Createas the default table.
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) Initialize() error {
-	query := fmt.Sprintf(createTable, DEFAULT_TABLE)
-
-	_, err := r.db.Exec(query)
+	err := r.migrate()
+	if err != nil {
+		return err
+	}
 
+	err = createUserTable(r.db, DEFAULT_TABLE)
 	if err != nil {
-		log.Fatalf("cannot create table: %q", err)
+		return storageError("create table", err)
 	}
 
-	return err
+	return nil
 }
 
 /*
 	Inserts en Entry into currentTable and returns the reference of the Entry
 	with an ID given to it from the database.
 
-	Logs to console and terminates execution if there is an issue with SQLer
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) Insert(e Entry) (*Entry, error) {
@@ -74,32 +103,102 @@ func (r *SQLiteRepository) Insert(e Entry) (*Entry, error) {
 		return nil, err
 	}
 
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, storageError("insert record", err)
+	}
+	defer tx.Rollback()
+
+	used, err := r.usedSlots(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = assignKeySlots(&e, used, func(slot int64) error {
+		return r.checkSlot(tx, slot, e.Username)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	e.Number, err = r.insertNumber(tx, e.Number, e.Username)
+	if err != nil {
+		return nil, err
+	}
+
 	query := fmt.Sprintf(insert, r.currentTable)
-	res, err := r.db.Exec(query, e.Name, e.Username, e.Email)
+	res, err := tx.Exec(query,
+		append(entryArgs(&e), e.Slot, e.SMBSlot, e.FTPSlot, e.Number)...)
 
 	if err != nil {
-		var sqliteErr sqlite3.Error
-		if errors.As(err, &sqliteErr) {
-			if errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
-				return nil, ErrDuplicate
-			}
+		if isDuplicate(err) {
+			return nil, ErrDuplicate
 		}
-		log.Fatalf("cannot insert record into table: %q", err)
+
+		return nil, storageError("insert record", err)
 	}
 
 	id, err := res.LastInsertId()
+	if err == nil {
+		err = tx.Commit()
+	}
+
 	if err != nil {
-		return nil, err
+		return nil, storageError("insert record", err)
 	}
 
 	e.ID = id
 	return &e, nil
 }
 
+/*
+	rowScanner is satisfied by both *sql.Row and *sql.Rows so that a single
+	function can scan an Entry from either.
+*/
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+/*
+	Returns the fields of an Entry in the order of entryColumns, excluding the
+	ID, slots and Number, for use as the arguments of the insert and update
+	statements. The slots and Number are only changed by their operations.
+*/
+
+func entryArgs(e *Entry) []interface{} {
+	return []interface{}{
+		e.Name, e.Username, e.Email,
+		e.SMB.Host, e.SMB.Path, e.SMB.Login, e.SMB.Password, e.SMB.Port,
+		e.FTP.Host, e.FTP.Path, e.FTP.Login, e.FTP.Password, e.FTP.Port,
+		e.Fax.Number, e.Fax.Subaddress, e.Fax.IFaxAddress, e.Fax.CommSpeed,
+		e.Fax.ECM, e.Fax.Encryption, e.Fax.EncryptionKey,
+	}
+}
+
+/*
+	Scans the columns of a row, in the order of entryColumns, into a new Entry.
+*/
+
+func scanEntry(row rowScanner) (*Entry, error) {
+	e := new(Entry)
+	err := row.Scan(&e.ID, &e.Name, &e.Username, &e.Email,
+		&e.SMB.Host, &e.SMB.Path, &e.SMB.Login, &e.SMB.Password, &e.SMB.Port,
+		&e.FTP.Host, &e.FTP.Path, &e.FTP.Login, &e.FTP.Password, &e.FTP.Port,
+		&e.Fax.Number, &e.Fax.Subaddress, &e.Fax.IFaxAddress, &e.Fax.CommSpeed,
+		&e.Fax.ECM, &e.Fax.Encryption, &e.Fax.EncryptionKey, &e.Slot, &e.SMBSlot, &e.FTPSlot,
+		&e.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
 /*
 	Queries currentTable return all the Entries
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) All() (all []*Entry, err error) {
@@ -107,20 +206,23 @@ func (r *SQLiteRepository) All() (all []*Entry, err error) {
 	rows, err := r.db.Query(query)
 
 	if err != nil {
-		log.Fatalf("cannot query table: %q", err)
+		return nil, storageError("query table", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		e := new(Entry)
-		err := rows.Scan(&e.ID, &e.Name, &e.Username, &e.Email)
+		e, err := scanEntry(rows)
 		if err != nil {
-			log.Fatalf("cannot scan row: %q", err)
+			return nil, storageError("scan row", err)
 		}
 
 		all = append(all, e)
 	}
 
+	if err = rows.Err(); err != nil {
+		return nil, storageError("query table", err)
+	}
+
 	return all, nil
 }
 
@@ -128,7 +230,7 @@ func (r *SQLiteRepository) All() (all []*Entry, err error) {
 	Queries currentTable to return a reference to an Entry when given a valid
 	username.
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) GetByUsername(username string) (*Entry, error) {
@@ -140,13 +242,13 @@ func (r *SQLiteRepository) GetByUsername(username string) (*Entry, error) {
 	query := fmt.Sprintf(selectByUername, r.currentTable)
 	row := r.db.QueryRow(query, username)
 
-	e := new(Entry)
-	err = row.Scan(&e.ID, &e.Name, &e.Username, &e.Email)
-
+	e, err := scanEntry(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
+
+		return nil, storageError("query record", err)
 	}
 
 	return e, nil
@@ -156,10 +258,10 @@ func (r *SQLiteRepository) GetByUsername(username string) (*Entry, error) {
 	Updates an Entry in the currentTable given it's username with the newly
 	updated Entry. Returns the updated entry if there are no issues.
 	If there are no updates no Entry is returned and corresponding
-	error is returned also.
+	error is returned also. The OneTouchKeys of the Entry keep their slots,
+	a destination that is added is given a slot like Insert.
 
-	Logs error to console and terminates execution if there is an issue with the
-	SQL.
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) Update(username string, u *Entry) (*Entry, error) {
@@ -173,30 +275,61 @@ func (r *SQLiteRepository) Update(username string, u *Entry) (*Entry, error) {
 		return nil, err
 	}
 
-	query := fmt.Sprintf(update, r.currentTable)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, storageError("update record", err)
+	}
+	defer tx.Rollback()
+
+	updated := *u
+	query := fmt.Sprintf(selectKeySlots, r.currentTable)
+	err = tx.QueryRow(query, username).Scan(&updated.Slot, &updated.SMBSlot,
+		&updated.FTPSlot)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUpdateFailed
+	}
 
-	res, err := r.db.Exec(query, u.Name, u.Username, u.Email,
-		username)
 	if err != nil {
-		log.Fatalf("cannot execute statement: %q", err)
+		return nil, storageError("update record", err)
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	used, err := r.usedSlots(tx)
 	if err != nil {
-		log.Fatalf("cannot update record: %q", err)
+		return nil, err
 	}
 
-	if rowsAffected == 0 {
-		return nil, ErrUpdateFailed
+	err = assignKeySlots(&updated, used, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return u, nil
+	query = fmt.Sprintf(update, r.currentTable)
+	_, err = tx.Exec(query, append(entryArgs(u), username)...)
+	if err != nil {
+		if isDuplicate(err) {
+			return nil, ErrDuplicate
+		}
+
+		return nil, storageError("update record", err)
+	}
+
+	query = fmt.Sprintf(updateKeySlots, r.currentTable)
+	_, err = tx.Exec(query, updated.SMBSlot, updated.FTPSlot, u.Username)
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		return nil, storageError("update record", err)
+	}
+
+	return &updated, nil
 }
 
 /*
 	Deletes an Entry in the currentTable given a valid id.
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) Delete(username string) error {
@@ -205,22 +338,38 @@ func (r *SQLiteRepository) Delete(username string) error {
 		return err
 	}
 
-	query := fmt.Sprintf(delete, r.currentTable)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("delete record", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(deleteEntryMembers, r.currentTable)
+	_, err = tx.Exec(query, r.currentTable, username)
+	if err != nil {
+		return storageError("delete record", err)
+	}
 
-	res, err := r.db.Exec(query, username)
+	query = fmt.Sprintf(delete, r.currentTable)
+	res, err := tx.Exec(query, username)
 	if err != nil {
-		return err
+		return storageError("delete record", err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return err
+		return storageError("delete record", err)
 	}
 
 	if rowsAffected == 0 {
 		return ErrDeleteFailed
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return storageError("delete record", err)
+	}
+
 	return nil
 }
 
@@ -228,7 +377,7 @@ func (r *SQLiteRepository) Delete(username string) error {
 	Creates a new table within the database. currentTable is updated if the
 	table is valid and does not already exist.
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) NewTable(tableName string) error {
@@ -242,11 +391,19 @@ func (r *SQLiteRepository) NewTable(tableName string) error {
 		return ErrTableExists
 	}
 
-	query := fmt.Sprintf(createTable, tableName)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("create table", err)
+	}
+	defer tx.Rollback()
+
+	err = createUserTable(tx, tableName)
+	if err == nil {
+		err = tx.Commit()
+	}
 
-	_, err = r.db.Exec(query, tableName)
 	if err != nil {
-		log.Fatalf("cannot create table: %q", err)
+		return storageError("create table", err)
 	}
 
 	r.currentTable = tableName
@@ -257,7 +414,7 @@ func (r *SQLiteRepository) NewTable(tableName string) error {
 	Updates the currentTable variable to to the tableName parameter given the
 	table exists and has a valid name.
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) SwitchTable(tableName string) error {
@@ -277,14 +434,29 @@ func (r *SQLiteRepository) SwitchTable(tableName string) error {
 /*
 	Wipes the current table.
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 func (r *SQLiteRepository) ClearTable() error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("clear table", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(clearMembers, r.currentTable)
+	if err != nil {
+		return storageError("clear table", err)
+	}
+
 	query := fmt.Sprintf(clearTable, r.currentTable)
+	_, err = tx.Exec(query)
+	if err != nil {
+		return storageError("clear table", err)
+	}
 
-	_, err := r.db.Exec(query)
+	err = tx.Commit()
 	if err != nil {
-		log.Fatalf("could not drop table: %q", err)
+		return storageError("clear table", err)
 	}
 
 	return nil
@@ -295,7 +467,7 @@ func (r *SQLiteRepository) ClearTable() error {
 	for the table's existence. If the table does not exist an error will be
 	returned to the caller as to why not.
 
-	Logs to console and terminates execution if there is an issue with SQL
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) TableExists(tableName string) (bool, error) {
@@ -306,9 +478,8 @@ func (r *SQLiteRepository) TableExists(tableName string) (bool, error) {
 	}
 
 	rows, err := r.db.Query(selectTable, tableName)
-
 	if err != nil {
-		log.Fatalf("cannot query database: %q", err)
+		return false, storageError("query database", err)
 	}
 	defer rows.Close()
 
@@ -316,12 +487,18 @@ func (r *SQLiteRepository) TableExists(tableName string) (bool, error) {
 		return true, nil
 	}
 
+	if err = rows.Err(); err != nil {
+		return false, storageError("query database", err)
+	}
+
 	return false, ErrTableDoesNotExist
 }
 
 /*
 	Delete the table from the database that is passed. This function cannot and
 	will not delete the DEFAULT_TABLE.
+
+	Returns a StorageError if there is an issue with SQL
 */
 
 func (r *SQLiteRepository) DeleteTable(tableName string) error {
@@ -335,15 +512,31 @@ func (r *SQLiteRepository) DeleteTable(tableName string) error {
 		return ErrTableCannotBeDeleted
 	}
 
-	if r.currentTable == tableName {
-		r.currentTable = DEFAULT_TABLE
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("delete table", err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{clearMembers, deleteGroups, clearReserve} {
+		_, err = tx.Exec(stmt, tableName)
+		if err != nil {
+			return storageError("delete table", err)
+		}
 	}
 
 	query := fmt.Sprintf(deleteTable, tableName)
+	_, err = tx.Exec(query)
+	if err == nil {
+		err = tx.Commit()
+	}
 
-	_, err = r.db.Exec(query)
 	if err != nil {
-		return ErrTableCannotBeDeleted
+		return storageError("delete table", err)
+	}
+
+	if r.currentTable == tableName {
+		r.currentTable = DEFAULT_TABLE
 	}
 
 	return nil
@@ -351,24 +544,767 @@ func (r *SQLiteRepository) DeleteTable(tableName string) error {
 
 /*
 	List all tables that the user created.
+
+	Returns a StorageError if there is an issue with SQL
 */
 
-func (r *SQLiteRepository) ListTables() (tables []string) {
+func (r *SQLiteRepository) ListTables() (tables []string, err error) {
 	rows, err := r.db.Query(listTables)
 	if err != nil {
-		log.Fatalf("cannot query db: %q", err)
+		return nil, storageError("query database", err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var s string
 
 		err = rows.Scan(&s)
 		if err != nil {
-			log.Fatalf("cannot get row: %q", err)
+			return nil, storageError("scan row", err)
 		}
 
 		tables = append(tables, s)
 	}
 
-	return
+	if err = rows.Err(); err != nil {
+		return nil, storageError("query database", err)
+	}
+
+	return tables, nil
+}
+
+/*
+	Creates a new table that is a copy of an existing table, with its Entries,
+	Groups and reserved slots. The Entries keep their IDs, slots and numbers.
+	currentTable is updated to the copy like NewTable.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) CopyTable(tableName, newName string) error {
+	_, err := r.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+
+	exists, err := r.TableExists(newName)
+	if err != nil && !errors.Is(err, ErrTableDoesNotExist) {
+		return err
+	}
+
+	if exists {
+		return ErrTableExists
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("copy table", err)
+	}
+	defer tx.Rollback()
+
+	err = createUserTable(tx, newName)
+	if err != nil {
+		return storageError("copy table", err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(copyEntries, newName, tableName))
+	if err != nil {
+		return storageError("copy table", err)
+	}
+
+	_, err = tx.Exec(copyReserve, newName, tableName)
+	if err != nil {
+		return storageError("copy table", err)
+	}
+
+	err = copyTableGroups(tx, tableName, newName)
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		return storageError("copy table", err)
+	}
+
+	r.currentTable = newName
+	return nil
+}
+
+/*
+	Copies the Groups of a table, and their members, to another table.
+*/
+
+func copyTableGroups(tx sqlTx, tableName, newName string) error {
+	groups, err := queryGroups(tx, tableName)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		res, err := tx.Exec(insertGroup, newName, g.Name, g.Slot, g.Number)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(copyMembers, id, g.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Renames a table along with its Groups and reserved slots. currentTable is
+	updated if it is the table being renamed. The DEFAULT_TABLE cannot be
+	renamed.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) RenameTable(tableName, newName string) error {
+	_, err := r.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+
+	if tableName == DEFAULT_TABLE {
+		return ErrTableCannotBeRenamed
+	}
+
+	exists, err := r.TableExists(newName)
+	if err != nil && !errors.Is(err, ErrTableDoesNotExist) {
+		return err
+	}
+
+	if exists {
+		return ErrTableExists
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("rename table", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf(renameTable, tableName, newName))
+	if err != nil {
+		return storageError("rename table", err)
+	}
+
+	for _, stmt := range []string{renameGroups, renameReserve} {
+		_, err = tx.Exec(stmt, newName, tableName)
+		if err != nil {
+			return storageError("rename table", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return storageError("rename table", err)
+	}
+
+	if r.currentTable == tableName {
+		r.currentTable = newName
+	}
+
+	return nil
+}
+
+/*
+	Returns the Groups of a table in the order they were created, without
+	their members.
+*/
+
+func queryGroups(db execer, tableName string) ([]*Group, error) {
+	rows, err := db.Query(selectGroups, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*Group
+	for rows.Next() {
+		g := new(Group)
+		err = rows.Scan(&g.ID, &g.Name, &g.Slot, &g.Number)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+/*
+	Creates a new Group in the currentTable and returns it.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) NewGroup(name string) (*Group, error) {
+	return r.InsertGroup(Group{Name: name})
+}
+
+/*
+	Creates a Group in the currentTable with the name, slot and number of g
+	and returns it, its members are added with AddToGroup. A slot of 0 is
+	given the lowest slot that is not taken or reserved, any other slot is
+	checked to not be taken or reserved. A number of 0 is given the number
+	after the highest number of the table's Groups, any other number is
+	checked to not be taken.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) InsertGroup(g Group) (*Group, error) {
+	err := validateGroupName(g.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.Slot != 0 {
+		err = validateSlot(g.Slot)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if g.Number < 0 || g.Number > MaxNumber {
+		return nil, ErrInvalidNumber
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, storageError("create group", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(selectGroup, r.currentTable, g.Name).Scan(&id)
+	if err == nil {
+		return nil, ErrGroupExists
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, storageError("query group", err)
+	}
+
+	if g.Slot == 0 {
+		used, err := r.usedSlots(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		g.Slot = nextSlot(used)
+	} else {
+		err = r.checkSlot(tx, g.Slot, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	g.Number, err = r.groupNumber(tx, g.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Exec(insertGroup, r.currentTable, g.Name, g.Slot, g.Number)
+	if err != nil {
+		if isDuplicate(err) {
+			return nil, ErrGroupExists
+		}
+
+		return nil, storageError("create group", err)
+	}
+
+	id, err = res.LastInsertId()
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		return nil, storageError("create group", err)
+	}
+
+	return &Group{ID: id, Name: g.Name, Slot: g.Slot, Number: g.Number}, nil
+}
+
+/*
+	Returns the number of a Group being created in the currentTable. A number
+	of 0 is given the number after the highest number of the table's Groups,
+	any other number is checked to not be taken.
+*/
+
+func (r *SQLiteRepository) groupNumber(tx sqlTx, number int64) (int64, error) {
+	if number != 0 {
+		var id int64
+		err := tx.QueryRow(groupNumberHolder, r.currentTable, number).Scan(&id)
+		if err == nil {
+			return 0, ErrNumberTaken
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, storageError("query group number", err)
+		}
+
+		return number, nil
+	}
+
+	numbers, err := queryColumn(tx, groupNumbers, r.currentTable)
+	if err != nil {
+		return 0, storageError("query group numbers", err)
+	}
+
+	return nextNumber(numbers)
+}
+
+/*
+	Adds the Entry with the given username to the Group of the currentTable
+	with the given name.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) AddToGroup(name, username string) error {
+	err := validateGroupName(name)
+	if err != nil {
+		return err
+	}
+
+	e, err := r.GetByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	err = r.db.QueryRow(selectGroup, r.currentTable, name).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrGroupDoesNotExist
+	}
+
+	if err != nil {
+		return storageError("query group", err)
+	}
+
+	_, err = r.db.Exec(insertMember, id, e.ID)
+	if err != nil {
+		if isDuplicate(err) {
+			return ErrAlreadyInGroup
+		}
+
+		return storageError("add group member", err)
+	}
+
+	return nil
+}
+
+/*
+	Returns the Groups of the currentTable in the order they were created.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) Groups() ([]*Group, error) {
+	groups, err := queryGroups(r.db, r.currentTable)
+	if err != nil {
+		return nil, storageError("query groups", err)
+	}
+
+	byID := make(map[int64]*Group)
+	for _, g := range groups {
+		byID[g.ID] = g
+	}
+
+	members, err := r.db.Query(selectMembers, r.currentTable)
+	if err != nil {
+		return nil, storageError("query group members", err)
+	}
+	defer members.Close()
+
+	for members.Next() {
+		var groupID, entryID int64
+		err = members.Scan(&groupID, &entryID)
+		if err != nil {
+			return nil, storageError("scan row", err)
+		}
+
+		if g, ok := byID[groupID]; ok {
+			g.Members = append(g.Members, entryID)
+		}
+	}
+
+	if err = members.Err(); err != nil {
+		return nil, storageError("query group members", err)
+	}
+
+	return groups, nil
+}
+
+/*
+	Returns the values of the single column of the rows of a query, ie the
+	slots or numbers of a table.
+*/
+
+func queryColumn(db execer, query string, args ...interface{}) ([]int64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slots []int64
+	for rows.Next() {
+		var s int64
+		err = rows.Scan(&s)
+		if err != nil {
+			return nil, err
+		}
+
+		slots = append(slots, s)
+	}
+
+	return slots, rows.Err()
+}
+
+/*
+	Returns the slots of the currentTable that are given to a OneTouchKey or
+	reserved.
+*/
+
+func (r *SQLiteRepository) usedSlots(tx sqlTx) (map[int64]bool, error) {
+	slots, err := queryColumn(tx, fmt.Sprintf(usedSlots, r.currentTable),
+		r.currentTable)
+	if err != nil {
+		return nil, storageError("query slots", err)
+	}
+
+	used := make(map[int64]bool)
+	for _, s := range slots {
+		used[s] = true
+	}
+
+	return used, nil
+}
+
+/*
+	Checks that a slot of the currentTable is not reserved, given to a Group
+	or to a OneTouchKey other than the email key of the Entry with the given
+	username.
+*/
+
+func (r *SQLiteRepository) checkSlot(tx sqlTx, slot int64, username string) error {
+	if slot == NoSlot {
+		return nil
+	}
+
+	var s int64
+	err := tx.QueryRow(isReserved, r.currentTable, slot).Scan(&s)
+	if err == nil {
+		return ErrSlotReserved
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return storageError("query slot", err)
+	}
+
+	var holder string
+	query := fmt.Sprintf(slotHolder, r.currentTable)
+	err = tx.QueryRow(query, slot, username, r.currentTable).Scan(&holder)
+	if err == nil {
+		return ErrSlotTaken
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		return storageError("query slot", err)
+	}
+
+	return nil
+}
+
+/*
+	Moves the OneTouchKey of the Entry with the given username to a slot of
+	the currentTable, NoSlot removes the Entry's OneTouchKey.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) SetSlot(username string, slot int64) error {
+	err := validateField(username, usernamePattern, ErrInvalidUsername)
+	if err != nil {
+		return err
+	}
+
+	err = validateSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("update slot", err)
+	}
+	defer tx.Rollback()
+
+	err = r.checkSlot(tx, slot, username)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(fmt.Sprintf(updateSlot, r.currentTable), slot, username)
+	if err != nil {
+		return storageError("update slot", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return storageError("update slot", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return storageError("update slot", err)
+	}
+
+	return nil
+}
+
+/*
+	Swaps the slots of the OneTouchKeys of two Entries of the currentTable.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) SwapSlots(username, other string) error {
+	for _, u := range []string{username, other} {
+		err := validateField(u, usernamePattern, ErrInvalidUsername)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("swap slots", err)
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(selectSlot, r.currentTable)
+	slots := make([]int64, 2)
+	for i, u := range []string{username, other} {
+		err = tx.QueryRow(query, u).Scan(&slots[i])
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+
+		if err != nil {
+			return storageError("swap slots", err)
+		}
+	}
+
+	query = fmt.Sprintf(updateSlot, r.currentTable)
+	for i, u := range []string{other, username} {
+		_, err = tx.Exec(query, slots[i], u)
+		if err != nil {
+			return storageError("swap slots", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return storageError("swap slots", err)
+	}
+
+	return nil
+}
+
+/*
+	Reserves a slot of the currentTable so that it is not given to inserted
+	Entries and left empty when exported.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) ReserveSlot(slot int64) error {
+	if slot == NoSlot {
+		return ErrInvalidSlot
+	}
+
+	err := validateSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("reserve slot", err)
+	}
+	defer tx.Rollback()
+
+	err = r.checkSlot(tx, slot, "")
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(insertReserve, r.currentTable, slot)
+	if err == nil {
+		err = tx.Commit()
+	}
+
+	if err != nil {
+		return storageError("reserve slot", err)
+	}
+
+	return nil
+}
+
+/*
+	Releases a reserved slot of the currentTable.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) ReleaseSlot(slot int64) error {
+	res, err := r.db.Exec(deleteReserve, r.currentTable, slot)
+	if err != nil {
+		return storageError("release slot", err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return storageError("release slot", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrSlotNotReserved
+	}
+
+	return nil
+}
+
+/*
+	Returns the reserved slots of the currentTable in order.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) ReservedSlots() ([]int64, error) {
+	slots, err := queryColumn(r.db, selectReserve, r.currentTable)
+	if err != nil {
+		return nil, storageError("query reserved slots", err)
+	}
+
+	return slots, nil
+}
+
+/*
+	Returns the address book number of an Entry being inserted into the
+	currentTable. A number of 0 is given the next number, any other number is
+	checked to not be taken by an Entry other than the one with the given
+	username.
+*/
+
+func (r *SQLiteRepository) insertNumber(tx sqlTx, number int64, username string) (int64, error) {
+	if number != 0 {
+		var holder string
+		query := fmt.Sprintf(numberHolder, r.currentTable)
+		err := tx.QueryRow(query, number, username).Scan(&holder)
+		if err == nil {
+			return 0, ErrNumberTaken
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, storageError("query number", err)
+		}
+
+		return number, nil
+	}
+
+	rows, err := tx.Query(fmt.Sprintf(selectNumbers, r.currentTable))
+	if err != nil {
+		return 0, storageError("query numbers", err)
+	}
+	defer rows.Close()
+
+	var numbers []int64
+	for rows.Next() {
+		var n int64
+		err = rows.Scan(&n)
+		if err != nil {
+			return 0, storageError("scan row", err)
+		}
+
+		numbers = append(numbers, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, storageError("query numbers", err)
+	}
+
+	return nextNumber(numbers)
+}
+
+/*
+	Renumbers the Entries of the currentTable from 1 without gaps, keeping
+	their order. Returns the number of Entries that were given a new number.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) CompactNumbers() (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, storageError("compact numbers", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(fmt.Sprintf(numberOrder, r.currentTable))
+	if err != nil {
+		return 0, storageError("compact numbers", err)
+	}
+
+	var entries []*Entry
+	for rows.Next() {
+		e := new(Entry)
+		err = rows.Scan(&e.ID, &e.Number)
+		if err != nil {
+			rows.Close()
+			return 0, storageError("scan row", err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, storageError("compact numbers", err)
+	}
+
+	numbers := compactNumbers(entries)
+	query := fmt.Sprintf(updateNumber, r.currentTable)
+	for id, n := range numbers {
+		_, err = tx.Exec(query, n, id)
+		if err != nil {
+			return 0, storageError("compact numbers", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, storageError("compact numbers", err)
+	}
+
+	return len(numbers), nil
 }
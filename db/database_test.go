@@ -2,6 +2,7 @@ package db
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -16,6 +17,7 @@ func TestInsert(t *testing.T) {
 
 		expected, _ := newTestEntry(1, "Test One", "username1", "test1@test.com")
 		assertError(t, err, nil)
+		expected.Slot, expected.Number = 1, 1
 
 		assertEntry(t, got, expected)
 	})
@@ -49,6 +51,53 @@ func TestInsert(t *testing.T) {
 		assertError(t, err, ErrDuplicate)
 	})
 
+	t.Run("insert entry with scan destinations", func(t *testing.T) {
+		repo, teardown := setup(t)
+		defer teardown()
+
+		e := *e1
+		e.SMB = SMBDestination{
+			Host:     "fileserver",
+			Path:     `scans\username1`,
+			Login:    `EXAMPLE\username1`,
+			Password: "password",
+			Port:     445,
+		}
+		e.FTP = FTPDestination{
+			Host:     "ftpserver",
+			Path:     "/scans/username1",
+			Login:    "username1",
+			Password: "password",
+			Port:     21,
+		}
+		e.Fax = FaxDestination{
+			Number:      "555-0100",
+			Subaddress:  "12",
+			IFaxAddress: "fax@test.com",
+			ECM:         "Off",
+		}
+
+		_, err := repo.Insert(e)
+		assertError(t, err, nil)
+
+		// the keys of the destinations take the slots after the email key
+		e.SMBSlot, e.FTPSlot = 2, 3
+		got, err := repo.GetByUsername(e.Username)
+		assertError(t, err, nil)
+		assertEntry(t, got, &e)
+	})
+
+	t.Run("insert entry with invalid smb destination", func(t *testing.T) {
+		repo, teardown := setup(t)
+		defer teardown()
+
+		e := *e1
+		e.SMB = SMBDestination{Host: "fileserver"}
+
+		_, err := repo.Insert(e)
+		assertError(t, err, ErrInvalidSMBPath)
+	})
+
 	t.Run("insert into new table", func(t *testing.T) {
 		repo, teardown := SetupWithInserts(t)
 		defer teardown()
@@ -149,6 +198,10 @@ func TestUpdate(t *testing.T) {
 	found, foundErr := repo.Update("username1", updated)
 	notFound, notFoundErr := repo.Update("non-existingusername", updated)
 
+	// the updated Entry keeps its slot
+	expected := *updated
+	expected.Slot = e1.Slot
+
 	tt := []struct {
 		description string
 		got         entryInfo
@@ -161,7 +214,7 @@ func TestUpdate(t *testing.T) {
 				err:   foundErr,
 			},
 			expected: entryInfo{
-				entry: updated,
+				entry: &expected,
 				err:   nil,
 			},
 		},
@@ -195,7 +248,10 @@ func TestDelete(t *testing.T) {
 	e, err := newTestEntry(4, "Test One", "username1", "test1@test.com")
 	assertError(t, err, nil)
 
+	// the slot of the deleted user is given to the next one inserted, but
+	// not its address book number
 	inserted, err := repo.Insert(*e)
+	e.Slot, e.Number = 1, 4
 	assertEntry(t, inserted, e)
 	assertError(t, err, nil)
 
@@ -445,18 +501,21 @@ func TestListTables(t *testing.T) {
 	repo, teardown := setup(t)
 	defer teardown()
 
-	t1 := repo.ListTables()
+	t1, err := repo.ListTables()
+	assertError(t, err, nil)
 
 	repo.NewTable("new_table")
 	repo.NewTable("new_table1")
 	repo.NewTable("new_table2")
 
-	t2 := repo.ListTables()
+	t2, err := repo.ListTables()
+	assertError(t, err, nil)
 
 	repo.DeleteTable("new_table")
 	repo.DeleteTable("new_table2")
 
-	t3 := repo.ListTables()
+	t3, err := repo.ListTables()
+	assertError(t, err, nil)
 
 	tt := []struct {
 		description string
@@ -489,3 +548,144 @@ func TestListTables(t *testing.T) {
 		})
 	}
 }
+
+func TestCopyTable(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	repos := []struct {
+		description string
+		repo        AddressBookRepository
+	}{
+		{description: "sqlite", repo: sqliteRepo},
+		{description: "json", repo: jsonRepo},
+	}
+
+	for _, rc := range repos {
+		repo := rc.repo
+		t.Run(rc.description, func(t *testing.T) {
+			_, err := repo.NewGroup("Sales")
+			assertError(t, err, nil)
+			assertError(t, repo.AddToGroup("Sales", e2.Username), nil)
+			assertError(t, repo.ReserveSlot(8), nil)
+
+			assertError(t, repo.CopyTable(DEFAULT_TABLE, "site_b"), nil)
+			assertError(t, repo.CopyTable(DEFAULT_TABLE, "site_b"), ErrTableExists)
+			assertError(t, repo.CopyTable("missing", "site_c"), ErrTableDoesNotExist)
+			assertError(t, repo.CopyTable(DEFAULT_TABLE, "sql_table"),
+				ErrInvalidTableName)
+
+			if repo.CurrentTable() != "site_b" {
+				t.Fatalf("got: %v, expected: %v", repo.CurrentTable(), "site_b")
+			}
+
+			all, err := repo.All()
+			assertError(t, err, nil)
+
+			expected := []*Entry{e1, e2, e3}
+			if !reflect.DeepEqual(all, expected) {
+				t.Fatalf("got: %v, expected: %v", all, expected)
+			}
+
+			groups, err := repo.Groups()
+			assertError(t, err, nil)
+			if len(groups) != 1 || groups[0].Name != "Sales" ||
+				!reflect.DeepEqual(groups[0].Members, []int64{e2.ID}) {
+				t.Fatalf("got: %v, expected: %v", groups, "Sales with username2")
+			}
+
+			reserved, err := repo.ReservedSlots()
+			assertError(t, err, nil)
+			if !reflect.DeepEqual(reserved, []int64{8}) {
+				t.Fatalf("got: %v, expected: %v", reserved, []int64{8})
+			}
+
+			// the copy is independent of the original
+			assertError(t, repo.Delete(e1.Username), nil)
+			assertError(t, repo.SwitchTable(DEFAULT_TABLE), nil)
+
+			_, err = repo.GetByUsername(e1.Username)
+			assertError(t, err, nil)
+		})
+	}
+}
+
+func TestRenameTable(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	repos := []struct {
+		description string
+		repo        AddressBookRepository
+	}{
+		{description: "sqlite", repo: sqliteRepo},
+		{description: "json", repo: jsonRepo},
+	}
+
+	for _, rc := range repos {
+		repo := rc.repo
+		t.Run(rc.description, func(t *testing.T) {
+			assertError(t, repo.NewTable("sitr_a"), nil)
+			_, err := repo.Insert(*e1)
+			assertError(t, err, nil)
+			_, err = repo.NewGroup("Sales")
+			assertError(t, err, nil)
+			assertError(t, repo.ReserveSlot(5), nil)
+			assertError(t, repo.NewTable("site_b"), nil)
+			assertError(t, repo.SwitchTable(DEFAULT_TABLE), nil)
+
+			assertError(t, repo.RenameTable(DEFAULT_TABLE, "default_b"),
+				ErrTableCannotBeRenamed)
+			assertError(t, repo.RenameTable("sitr_a", "site_b"), ErrTableExists)
+			assertError(t, repo.RenameTable("sitr_a", DEFAULT_TABLE),
+				ErrTableExists)
+			assertError(t, repo.RenameTable("sitr_a", "site a"),
+				ErrInvalidTableName)
+			assertError(t, repo.RenameTable("missing", "site_c"),
+				ErrTableDoesNotExist)
+
+			// renaming another table keeps the current table
+			assertError(t, repo.RenameTable("site_b", "site_c"), nil)
+			if repo.CurrentTable() != DEFAULT_TABLE {
+				t.Fatalf("got: %v, expected: %v", repo.CurrentTable(),
+					DEFAULT_TABLE)
+			}
+
+			assertError(t, repo.SwitchTable("sitr_a"), nil)
+			assertError(t, repo.RenameTable("sitr_a", "site_a"), nil)
+			if repo.CurrentTable() != "site_a" {
+				t.Fatalf("got: %v, expected: %v", repo.CurrentTable(), "site_a")
+			}
+
+			tables, err := repo.ListTables()
+			assertError(t, err, nil)
+			sort.Strings(tables)
+
+			expected := []string{DEFAULT_TABLE, "site_a", "site_c"}
+			if !reflect.DeepEqual(tables, expected) {
+				t.Fatalf("got: %v, expected: %v", tables, expected)
+			}
+
+			e, err := repo.GetByUsername(e1.Username)
+			assertEntryInfo(t, entryInfo{entry: e, err: err}, entryInfo{entry: e1})
+
+			groups, err := repo.Groups()
+			assertError(t, err, nil)
+			if len(groups) != 1 || groups[0].Name != "Sales" {
+				t.Fatalf("got: %v, expected: %v", groups, "Sales")
+			}
+
+			reserved, err := repo.ReservedSlots()
+			assertError(t, err, nil)
+			if !reflect.DeepEqual(reserved, []int64{5}) {
+				t.Fatalf("got: %v, expected: %v", reserved, []int64{5})
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	SMBDestination struct models a scan to folder destination of an Entry.
+	The zero value means that the Entry has no SMB destination.
+
+	Host: hostname or IP address of the file server
+	Path: share and folder on the file server, ie scans\jdoe
+	Login: account used to write to the share, ie DOMAIN\jdoe
+	Password: password of the Login account
+	Port: port of the SMB service, 0 will use the scanner's default
+*/
+
+type SMBDestination struct {
+	Host     string `json:"host,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Login    string `json:"login,omitempty"`
+	Password string `json:"password,omitempty"`
+	Port     int64  `json:"port,omitempty"`
+}
+
+/*
+	Reports whether any of the SMBDestination's fields are set.
+*/
+
+func (d SMBDestination) IsSet() bool {
+	return d != SMBDestination{}
+}
+
+/*
+	Returns the UNC path of the SMBDestination ie \\host\scans\jdoe
+*/
+
+func (d SMBDestination) String() string {
+	return fmt.Sprintf(`\\%v\%v`, d.Host, d.Path)
+}
+
+/*
+	Function that checks the fields of a SMBDestination. An unset destination
+	is valid, otherwise both the Host and Path must be present.
+*/
+
+func validateSMB(d SMBDestination) error {
+	if !d.IsSet() {
+		return nil
+	}
+
+	err := validateField(d.Host, hostPattern, ErrInvalidSMBHost)
+	if err != nil {
+		return err
+	}
+
+	err = validateField(d.Path, pathPattern, ErrInvalidSMBPath)
+	if err != nil {
+		return err
+	}
+
+	if d.Login != "" {
+		err = validateField(d.Login, loginPattern, ErrInvalidSMBLogin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return validatePort(d.Port)
+}
+
+/*
+	FTPDestination struct models a scan to FTP destination of an Entry.
+	The zero value means that the Entry has no FTP destination.
+
+	Host: hostname or IP address of the FTP server
+	Path: directory on the FTP server, ie /scans/jdoe
+	Login: account used to log in to the FTP server
+	Password: password of the Login account
+	Port: port of the FTP service, 0 will use the scanner's default
+*/
+
+type FTPDestination struct {
+	Host     string `json:"host,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Login    string `json:"login,omitempty"`
+	Password string `json:"password,omitempty"`
+	Port     int64  `json:"port,omitempty"`
+}
+
+/*
+	Reports whether any of the FTPDestination's fields are set.
+*/
+
+func (d FTPDestination) IsSet() bool {
+	return d != FTPDestination{}
+}
+
+/*
+	Returns the URL of the FTPDestination ie ftp://host/scans/jdoe
+*/
+
+func (d FTPDestination) String() string {
+	return fmt.Sprintf("ftp://%v/%v", d.Host, strings.TrimPrefix(d.Path, "/"))
+}
+
+/*
+	Function that checks the fields of a FTPDestination. An unset destination
+	is valid, otherwise both the Host and Path must be present.
+*/
+
+func validateFTP(d FTPDestination) error {
+	if !d.IsSet() {
+		return nil
+	}
+
+	err := validateField(d.Host, hostPattern, ErrInvalidFTPHost)
+	if err != nil {
+		return err
+	}
+
+	err = validateField(d.Path, pathPattern, ErrInvalidFTPPath)
+	if err != nil {
+		return err
+	}
+
+	if d.Login != "" {
+		err = validateField(d.Login, loginPattern, ErrInvalidFTPLogin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return validatePort(d.Port)
+}
+
+/*
+	Fax settings that the scanner uses unless an Entry overrides them.
+*/
+
+const (
+	DefaultFaxCommSpeed  = "BPS_33600"
+	DefaultFaxECM        = "On"
+	DefaultFaxEncryption = "Off"
+)
+
+/*
+	Fax communication speeds that can be set on a FaxDestination.
+*/
+
+var faxCommSpeeds = []string{"BPS_33600", "BPS_14400", "BPS_9600"}
+
+/*
+	FaxDestination struct models the fax and internet fax details of an Entry.
+	The zero value means that the Entry has no fax destination. Empty
+	CommSpeed, ECM and Encryption fields use the scanner's defaults.
+
+	Number: fax number of the Entry's owner
+	Subaddress: optional fax sub-address
+	IFaxAddress: internet fax address of the Entry's owner
+	CommSpeed: starting communication speed, ie BPS_14400
+	ECM: error correction mode, On or Off
+	Encryption: encrypted transmission, On or Off
+	EncryptionKey: number of the encryption key used when Encryption is On
+*/
+
+type FaxDestination struct {
+	Number        string `json:"number,omitempty"`
+	Subaddress    string `json:"subaddress,omitempty"`
+	IFaxAddress   string `json:"ifax_address,omitempty"`
+	CommSpeed     string `json:"comm_speed,omitempty"`
+	ECM           string `json:"ecm,omitempty"`
+	Encryption    string `json:"encryption,omitempty"`
+	EncryptionKey int64  `json:"encryption_key,omitempty"`
+}
+
+/*
+	Reports whether any of the FaxDestination's fields are set.
+*/
+
+func (d FaxDestination) IsSet() bool {
+	return d != FaxDestination{}
+}
+
+/*
+	Function that checks the fields of a FaxDestination. Sub-addresses and
+	overrides of the fax settings require a fax number, and encryption
+	requires a key number.
+*/
+
+func validateFax(d FaxDestination) error {
+	if !d.IsSet() {
+		return nil
+	}
+
+	faxOnly := FaxDestination{IFaxAddress: d.IFaxAddress}
+	if d != faxOnly {
+		err := validateField(d.Number, faxPattern, ErrInvalidFaxNumber)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.Subaddress != "" {
+		err := validateField(d.Subaddress, subaddressPattern,
+			ErrInvalidFaxSubaddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.IFaxAddress != "" {
+		err := validateField(d.IFaxAddress, emailPattern, ErrInvalidIFaxAddress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.CommSpeed != "" {
+		speed, err := parseCommSpeed(d.CommSpeed)
+		if err != nil || speed != d.CommSpeed {
+			return ErrInvalidFaxSpeed
+		}
+	}
+
+	if d.ECM != "" && d.ECM != "On" && d.ECM != "Off" {
+		return ErrInvalidFaxECM
+	}
+
+	if d.Encryption != "" && d.Encryption != "On" && d.Encryption != "Off" {
+		return ErrInvalidFaxEncryption
+	}
+
+	if d.Encryption == "On" {
+		if d.EncryptionKey < 1 || d.EncryptionKey > 20 {
+			return ErrInvalidFaxEncryptionKey
+		}
+	}
+
+	return nil
+}
+
+/*
+	Function that converts a communication speed, ie 14400 or BPS_14400, into
+	the form the scanner expects.
+*/
+
+func parseCommSpeed(value string) (string, error) {
+	s := strings.ToUpper(value)
+	if !strings.HasPrefix(s, "BPS_") {
+		s = "BPS_" + s
+	}
+
+	for _, speed := range faxCommSpeeds {
+		if s == speed {
+			return speed, nil
+		}
+	}
+
+	return "", ErrInvalidFaxSpeed
+}
+
+/*
+	Function that converts an on/off value into On or Off, returning err if
+	the value is neither.
+*/
+
+func parseSwitch(value string, err error) (string, error) {
+	switch strings.ToLower(value) {
+	case "on":
+		return "On", nil
+	case "off":
+		return "Off", nil
+	default:
+		return "", err
+	}
+}
+
+/*
+	Function that converts the string value of an encryption key number field
+	into the key number.
+*/
+
+func parseEncryptionKey(value string) (int64, error) {
+	key, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || key < 1 || key > 20 {
+		return 0, ErrInvalidFaxEncryptionKey
+	}
+
+	return key, nil
+}
+
+/*
+	Function to ensure a port is either unset or within the range of valid TCP
+	ports.
+*/
+
+func validatePort(port int64) error {
+	if port < 0 || port > 65535 {
+		return ErrInvalidPort
+	}
+
+	return nil
+}
+
+/*
+	Function that converts the string value of a port field into a port
+	number.
+*/
+
+func parsePort(value string) (int64, error) {
+	port, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidPort
+	}
+
+	return port, validatePort(port)
+}
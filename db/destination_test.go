@@ -0,0 +1,263 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestValidateSMB(t *testing.T) {
+	tt := []struct {
+		description string
+		input       SMBDestination
+		expected    error
+	}{
+		{
+			description: "unset destination",
+			input:       SMBDestination{},
+			expected:    nil,
+		},
+		{
+			description: "valid destination",
+			input: SMBDestination{
+				Host:  "fileserver.example.com",
+				Path:  `scans\jdoe`,
+				Login: `EXAMPLE\jdoe`,
+				Port:  445,
+			},
+			expected: nil,
+		},
+		{
+			description: "destination without a host",
+			input:       SMBDestination{Path: `scans\jdoe`},
+			expected:    ErrInvalidSMBHost,
+		},
+		{
+			description: "destination without a path",
+			input:       SMBDestination{Host: "fileserver"},
+			expected:    ErrInvalidSMBPath,
+		},
+		{
+			description: "destination with an invalid login",
+			input: SMBDestination{
+				Host:  "fileserver",
+				Path:  `scans\jdoe`,
+				Login: "j doe",
+			},
+			expected: ErrInvalidSMBLogin,
+		},
+		{
+			description: "destination with an invalid port",
+			input: SMBDestination{
+				Host: "fileserver",
+				Path: `scans\jdoe`,
+				Port: 70000,
+			},
+			expected: ErrInvalidPort,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assertError(t, validateSMB(tc.input), tc.expected)
+		})
+	}
+}
+
+func TestValidateFTP(t *testing.T) {
+	tt := []struct {
+		description string
+		input       FTPDestination
+		expected    error
+	}{
+		{
+			description: "unset destination",
+			input:       FTPDestination{},
+			expected:    nil,
+		},
+		{
+			description: "valid destination",
+			input: FTPDestination{
+				Host:     "10.0.0.20",
+				Path:     "/scans/jdoe",
+				Login:    "jdoe",
+				Password: "password",
+				Port:     2121,
+			},
+			expected: nil,
+		},
+		{
+			description: "destination without a host",
+			input:       FTPDestination{Path: "/scans/jdoe"},
+			expected:    ErrInvalidFTPHost,
+		},
+		{
+			description: "destination without a path",
+			input:       FTPDestination{Host: "ftpserver"},
+			expected:    ErrInvalidFTPPath,
+		},
+		{
+			description: "destination with an invalid login",
+			input: FTPDestination{
+				Host:  "ftpserver",
+				Path:  "/scans/jdoe",
+				Login: "j doe",
+			},
+			expected: ErrInvalidFTPLogin,
+		},
+		{
+			description: "destination with an invalid port",
+			input: FTPDestination{
+				Host: "ftpserver",
+				Path: "/scans/jdoe",
+				Port: -1,
+			},
+			expected: ErrInvalidPort,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assertError(t, validateFTP(tc.input), tc.expected)
+		})
+	}
+}
+
+func TestValidateFax(t *testing.T) {
+	tt := []struct {
+		description string
+		input       FaxDestination
+		expected    error
+	}{
+		{
+			description: "unset destination",
+			input:       FaxDestination{},
+			expected:    nil,
+		},
+		{
+			description: "valid destination",
+			input: FaxDestination{
+				Number:        "+1 555-555-0100",
+				Subaddress:    "12#",
+				IFaxAddress:   "fax@example.com",
+				CommSpeed:     "BPS_9600",
+				ECM:           "Off",
+				Encryption:    "On",
+				EncryptionKey: 3,
+			},
+			expected: nil,
+		},
+		{
+			description: "internet fax only",
+			input:       FaxDestination{IFaxAddress: "fax@example.com"},
+			expected:    nil,
+		},
+		{
+			description: "overrides without a fax number",
+			input:       FaxDestination{ECM: "Off"},
+			expected:    ErrInvalidFaxNumber,
+		},
+		{
+			description: "fax number with letters",
+			input:       FaxDestination{Number: "555-FAX"},
+			expected:    ErrInvalidFaxNumber,
+		},
+		{
+			description: "invalid sub-address",
+			input:       FaxDestination{Number: "5550100", Subaddress: "12a"},
+			expected:    ErrInvalidFaxSubaddress,
+		},
+		{
+			description: "invalid internet fax address",
+			input:       FaxDestination{IFaxAddress: "fax.example.com"},
+			expected:    ErrInvalidIFaxAddress,
+		},
+		{
+			description: "invalid comm speed",
+			input:       FaxDestination{Number: "5550100", CommSpeed: "9600"},
+			expected:    ErrInvalidFaxSpeed,
+		},
+		{
+			description: "invalid ecm",
+			input:       FaxDestination{Number: "5550100", ECM: "yes"},
+			expected:    ErrInvalidFaxECM,
+		},
+		{
+			description: "encryption without a key",
+			input:       FaxDestination{Number: "5550100", Encryption: "On"},
+			expected:    ErrInvalidFaxEncryptionKey,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assertError(t, validateFax(tc.input), tc.expected)
+		})
+	}
+}
+
+func TestParseCommSpeed(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		expected    string
+		err         error
+	}{
+		{
+			description: "speed without prefix",
+			input:       "14400",
+			expected:    "BPS_14400",
+			err:         nil,
+		},
+		{
+			description: "speed with prefix",
+			input:       "bps_9600",
+			expected:    "BPS_9600",
+			err:         nil,
+		},
+		{
+			description: "unsupported speed",
+			input:       "2400",
+			expected:    "",
+			err:         ErrInvalidFaxSpeed,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseCommSpeed(tc.input)
+			assertError(t, err, tc.err)
+			if got != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSMBString(t *testing.T) {
+	d := SMBDestination{Host: "fileserver", Path: `scans\jdoe`}
+	expected := `\\fileserver\scans\jdoe`
+
+	if d.String() != expected {
+		t.Fatalf("got: %v, expected: %v", d.String(), expected)
+	}
+}
+
+func TestFTPString(t *testing.T) {
+	d := FTPDestination{Host: "ftpserver", Path: "/scans/jdoe"}
+	expected := "ftp://ftpserver/scans/jdoe"
+
+	if d.String() != expected {
+		t.Fatalf("got: %v, expected: %v", d.String(), expected)
+	}
+}
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	FieldChange describes a field of an Entry whose value differs between two
+	address books. Passwords are masked.
+
+	Field: name of the field ie email or smb_host
+	Old: value of the field in the address book that is compared against
+	New: value of the field in the current table
+*/
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+/*
+	EntryChange describes an Entry that is in both address books with
+	different fields.
+
+	Username: username of the Entry in the current table
+	Fields: the fields that differ
+*/
+
+type EntryChange struct {
+	Username string        `json:"username"`
+	Fields   []FieldChange `json:"fields"`
+}
+
+/*
+	SlotMove describes an Entry whose OneTouchKey is in a different slot.
+
+	Username: username of the Entry in the current table
+	From: slot of the Entry in the address book that is compared against
+	To: slot of the Entry in the current table
+*/
+
+type SlotMove struct {
+	Username string `json:"username"`
+	From     int64  `json:"from"`
+	To       int64  `json:"to"`
+}
+
+/*
+	TableDiff holds the differences between an address book and the current
+	table, as returned by Diff.
+
+	Added: Entries of the current table that are not in the other address book
+	Removed: Entries of the other address book that are not in the table
+	Modified: Entries in both whose fields differ
+	Moved: Entries in both whose OneTouchKey slot differs
+*/
+
+type TableDiff struct {
+	Added    []*Entry      `json:"added,omitempty"`
+	Removed  []*Entry      `json:"removed,omitempty"`
+	Modified []EntryChange `json:"modified,omitempty"`
+	Moved    []SlotMove    `json:"moved,omitempty"`
+}
+
+/*
+	Returns the number of Entries that were added, removed, modified or moved.
+*/
+
+func (d *TableDiff) Count() int {
+	return len(d.Added) + len(d.Removed) + len(d.Modified) + len(d.Moved)
+}
+
+/*
+	Reports whether two Entries are the same contact, that is they have the
+	same username or email address.
+*/
+
+func (e *Entry) Matches(other *Entry) bool {
+	return e.Username == other.Username ||
+		strings.EqualFold(e.Email, other.Email)
+}
+
+/*
+	Fields of an Entry that are compared by Diff. The username is not compared
+	as it identifies the Entry and is derived from the email address in
+	exported address books.
+*/
+
+var diffFields = append([]string{"name", "email", "number"}, DestinationFields...)
+
+/*
+	Returns the value of one of the diffFields of an Entry as it is shown to
+	the user, empty if it is unset.
+*/
+
+func fieldValue(e *Entry, key string) string {
+	var v interface{}
+
+	switch key {
+	case "name":
+		v = e.Name
+	case "email":
+		v = e.Email
+	case "number":
+		v = e.Number
+	case "smb_host":
+		v = e.SMB.Host
+	case "smb_path":
+		v = e.SMB.Path
+	case "smb_login":
+		v = e.SMB.Login
+	case "smb_password":
+		v = e.SMB.Password
+	case "smb_port":
+		v = e.SMB.Port
+	case "ftp_host":
+		v = e.FTP.Host
+	case "ftp_path":
+		v = e.FTP.Path
+	case "ftp_login":
+		v = e.FTP.Login
+	case "ftp_password":
+		v = e.FTP.Password
+	case "ftp_port":
+		v = e.FTP.Port
+	case "fax_number":
+		v = e.Fax.Number
+	case "fax_subaddress":
+		v = e.Fax.Subaddress
+	case "ifax_address":
+		v = e.Fax.IFaxAddress
+	case "fax_speed":
+		v = e.Fax.CommSpeed
+	case "fax_ecm":
+		v = e.Fax.ECM
+	case "fax_encryption":
+		v = e.Fax.Encryption
+	case "fax_encryption_key":
+		v = e.Fax.EncryptionKey
+	}
+
+	if n, ok := v.(int64); ok && n == 0 {
+		return ""
+	}
+
+	return fmt.Sprint(v)
+}
+
+/*
+	Returns the fields that differ between the old and current version of
+	an Entry, in the order of diffFields.
+*/
+
+func diffEntry(old, current *Entry) []FieldChange {
+	var changes []FieldChange
+	for _, key := range diffFields {
+		o, n := fieldValue(old, key), fieldValue(current, key)
+		if o == n {
+			continue
+		}
+
+		if strings.HasSuffix(key, "_password") {
+			o, n = maskPassword(o), maskPassword(n)
+		}
+
+		changes = append(changes, FieldChange{Field: key, Old: o, New: n})
+	}
+
+	return changes
+}
+
+/*
+	Hides a password that is shown in a FieldChange.
+*/
+
+func maskPassword(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return "********"
+}
+
+/*
+	Compares the Entries of an address book against the Entries of the
+	current table. Entries are matched by username first and then by email
+	address, each Entry of old is matched at most once.
+
+	old: Entries of the address book that is compared against, ie an export
+	current: Entries of the current table
+*/
+
+func Diff(old, current []*Entry) *TableDiff {
+	d := &TableDiff{}
+	matched := make([]bool, len(old))
+
+	match := func(e *Entry, byEmail bool) *Entry {
+		for i, o := range old {
+			if matched[i] {
+				continue
+			}
+
+			if (!byEmail && o.Username == e.Username) ||
+				(byEmail && e.Matches(o)) {
+				matched[i] = true
+				return o
+			}
+		}
+
+		return nil
+	}
+
+	pairs := make([]*Entry, len(current))
+	for i, e := range current {
+		pairs[i] = match(e, false)
+	}
+
+	for i, e := range current {
+		if pairs[i] == nil {
+			pairs[i] = match(e, true)
+		}
+	}
+
+	for i, e := range current {
+		o := pairs[i]
+		if o == nil {
+			d.Added = append(d.Added, e)
+			continue
+		}
+
+		fields := diffEntry(o, e)
+		if len(fields) > 0 {
+			d.Modified = append(d.Modified,
+				EntryChange{Username: e.Username, Fields: fields})
+		}
+
+		if o.Slot != e.Slot {
+			d.Moved = append(d.Moved,
+				SlotMove{Username: e.Username, From: o.Slot, To: e.Slot})
+		}
+	}
+
+	for i, o := range old {
+		if !matched[i] {
+			d.Removed = append(d.Removed, o)
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEntryMatches(t *testing.T) {
+	tt := []struct {
+		description string
+		other       *Entry
+		expected    bool
+	}{
+		{
+			description: "same username",
+			other:       &Entry{Username: "username1", Email: "other@test.com"},
+			expected:    true,
+		},
+		{
+			description: "same email in another case",
+			other:       &Entry{Username: "test1", Email: "Test1@Test.com"},
+			expected:    true,
+		},
+		{
+			description: "different username and email",
+			other:       &Entry{Username: "test1", Email: "other@test.com"},
+			expected:    false,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got := e1.Matches(tc.other)
+			if got != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := []*Entry{
+		{Username: "test1", Name: "Test One", Email: "test1@test.com",
+			Number: 1, Slot: 1},
+		{Username: "username2", Name: "Test Two", Email: "test2@test.com",
+			Number: 2, Slot: 2, SMB: SMBDestination{Host: "files",
+				Path: "scans", Password: "old"}},
+		{Username: "gone", Name: "Gone", Email: "gone@test.com", Number: 4,
+			Slot: 4},
+	}
+
+	current := []*Entry{
+		{Username: "username1", Name: "Test One", Email: "test1@test.com",
+			Number: 1, Slot: 1},
+		{Username: "username2", Name: "Test Too", Email: "test2@test.com",
+			Number: 2, Slot: 3, SMB: SMBDestination{Host: "files",
+				Path: "scans", Password: "new"}},
+		{Username: "username3", Name: "Test Three", Email: "test3@test.com",
+			Number: 5, Slot: NoSlot},
+	}
+
+	got := Diff(old, current)
+	expected := &TableDiff{
+		Added:   []*Entry{current[2]},
+		Removed: []*Entry{old[2]},
+		Modified: []EntryChange{{Username: "username2", Fields: []FieldChange{
+			{Field: "name", Old: "Test Two", New: "Test Too"},
+			{Field: "smb_password", Old: "********", New: "********"},
+		}}},
+		Moved: []SlotMove{{Username: "username2", From: 2, To: 3}},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %+v, expected: %+v", got, expected)
+	}
+
+	if got.Count() != 4 {
+		t.Fatalf("got: %v, expected: %v", got.Count(), 4)
+	}
+
+	t.Run("identical tables", func(t *testing.T) {
+		d := Diff(current, current)
+		if d.Count() != 0 {
+			t.Fatalf("got: %+v, expected: %+v", d, &TableDiff{})
+		}
+	})
+
+	t.Run("username matches before email", func(t *testing.T) {
+		// username2 takes the email of test1 who is matched by username
+		old := []*Entry{
+			{Username: "test1", Email: "shared@test.com"},
+			{Username: "username2", Email: "test2@test.com"},
+		}
+
+		current := []*Entry{
+			{Username: "username2", Email: "shared@test.com"},
+			{Username: "test1", Email: "test1@test.com"},
+		}
+
+		d := Diff(old, current)
+		if len(d.Added) != 0 || len(d.Removed) != 0 || len(d.Modified) != 2 {
+			t.Fatalf("got: %+v, expected: %v", d, "two modified entries")
+		}
+	})
+}
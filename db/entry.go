@@ -14,13 +14,53 @@ import (
 	Name: the owner of the entry
 	Username: unique identifier for the entry
 	Email: email address of the Entry's owner
+	SMB: optional scan to folder destination of the Entry's owner
+	FTP: optional scan to FTP destination of the Entry's owner
+	Fax: optional fax and internet fax details of the Entry's owner
+	Slot: position of the Entry's OneTouchKey on the panel or NoSlot
+	SMBSlot: position of the OneTouchKey of the SMB destination or NoSlot, 0
+	when the Entry has no SMB destination
+	FTPSlot: position of the OneTouchKey of the FTP destination or NoSlot, 0
+	when the Entry has no FTP destination
+	Number: the Entry's contact number in the exported address book
 */
 
 type Entry struct {
-	ID       int64
-	Name     string
-	Username string
-	Email    string
+	ID       int64          `json:"id"`
+	Name     string         `json:"name"`
+	Username string         `json:"username"`
+	Email    string         `json:"email"`
+	SMB      SMBDestination `json:"smb"`
+	FTP      FTPDestination `json:"ftp"`
+	Fax      FaxDestination `json:"fax"`
+	Slot     int64          `json:"slot"`
+	SMBSlot  int64          `json:"smb_slot,omitempty"`
+	FTPSlot  int64          `json:"ftp_slot,omitempty"`
+	Number   int64          `json:"number"`
+}
+
+/*
+	Keys of the optional Entry fields that can be set with SetField.
+*/
+
+var DestinationFields = []string{
+	"smb_host",
+	"smb_path",
+	"smb_login",
+	"smb_password",
+	"smb_port",
+	"ftp_host",
+	"ftp_path",
+	"ftp_login",
+	"ftp_password",
+	"ftp_port",
+	"fax_number",
+	"fax_subaddress",
+	"ifax_address",
+	"fax_speed",
+	"fax_ecm",
+	"fax_encryption",
+	"fax_encryption_key",
 }
 
 /*
@@ -32,6 +72,250 @@ type Entry struct {
 func (e *Entry) Display(writer io.Writer) {
 	fmt.Fprintf(writer, "ID: %d\nName: %v\nUsername: %v\nEmail: %v\n",
 		e.ID, e.Name, e.Username, e.Email)
+
+	if e.Number > 0 {
+		fmt.Fprintf(writer, "Number: %d\n", e.Number)
+	}
+
+	if e.Slot > 0 {
+		fmt.Fprintf(writer, "Slot: %d\n", e.Slot)
+	}
+
+	if e.SMB.IsSet() {
+		fmt.Fprintf(writer, "SMB: %v\n", e.SMB)
+	}
+
+	if e.SMBSlot > 0 {
+		fmt.Fprintf(writer, "SMB Slot: %d\n", e.SMBSlot)
+	}
+
+	if e.FTP.IsSet() {
+		fmt.Fprintf(writer, "FTP: %v\n", e.FTP)
+	}
+
+	if e.FTPSlot > 0 {
+		fmt.Fprintf(writer, "FTP Slot: %d\n", e.FTPSlot)
+	}
+
+	if e.Fax.Number != "" {
+		fmt.Fprintf(writer, "Fax: %v\n", e.Fax.Number)
+	}
+
+	if e.Fax.IFaxAddress != "" {
+		fmt.Fprintf(writer, "Internet Fax: %v\n", e.Fax.IFaxAddress)
+	}
+}
+
+/*
+	Returns a copy of the Entry with the passwords of its destinations
+	masked, for output that can end up in scripts and logs.
+*/
+
+func (e *Entry) Masked() *Entry {
+	m := *e
+	m.SMB.Password = maskPassword(m.SMB.Password)
+	m.FTP.Password = maskPassword(m.FTP.Password)
+
+	return &m
+}
+
+/*
+	Returns the address types the Entry can be scanned to, ie EMAIL, SMB, FTP,
+	FAX and IFAX.
+*/
+
+func (e *Entry) Destinations() []string {
+	d := []string{"EMAIL"}
+	if e.SMB.IsSet() {
+		d = append(d, "SMB")
+	}
+
+	if e.FTP.IsSet() {
+		d = append(d, "FTP")
+	}
+
+	if e.Fax.Number != "" {
+		d = append(d, "FAX")
+	}
+
+	if e.Fax.IFaxAddress != "" {
+		d = append(d, "IFAX")
+	}
+
+	return d
+}
+
+/*
+	Sets one of the optional fields of the Entry given its key from
+	DestinationFields. The value is checked against the field's pattern and
+	an error is returned if it does not conform or the key is unknown.
+
+	key: name of the field ie smb_host
+	value: the new value of the field
+*/
+
+func (e *Entry) SetField(key, value string) error {
+	var err error
+
+	switch key {
+	case "smb_host":
+		err = validateField(value, hostPattern, ErrInvalidSMBHost)
+		e.SMB.Host = value
+	case "smb_path":
+		err = validateField(value, pathPattern, ErrInvalidSMBPath)
+		e.SMB.Path = value
+	case "smb_login":
+		err = validateField(value, loginPattern, ErrInvalidSMBLogin)
+		e.SMB.Login = value
+	case "smb_password":
+		e.SMB.Password = value
+	case "smb_port":
+		e.SMB.Port, err = parsePort(value)
+	case "ftp_host":
+		err = validateField(value, hostPattern, ErrInvalidFTPHost)
+		e.FTP.Host = value
+	case "ftp_path":
+		err = validateField(value, pathPattern, ErrInvalidFTPPath)
+		e.FTP.Path = value
+	case "ftp_login":
+		err = validateField(value, loginPattern, ErrInvalidFTPLogin)
+		e.FTP.Login = value
+	case "ftp_password":
+		e.FTP.Password = value
+	case "ftp_port":
+		e.FTP.Port, err = parsePort(value)
+	case "fax_number":
+		err = validateField(value, faxPattern, ErrInvalidFaxNumber)
+		e.Fax.Number = value
+	case "fax_subaddress":
+		err = validateField(value, subaddressPattern, ErrInvalidFaxSubaddress)
+		e.Fax.Subaddress = value
+	case "ifax_address":
+		err = validateField(value, emailPattern, ErrInvalidIFaxAddress)
+		e.Fax.IFaxAddress = value
+	case "fax_speed":
+		e.Fax.CommSpeed, err = parseCommSpeed(value)
+	case "fax_ecm":
+		e.Fax.ECM, err = parseSwitch(value, ErrInvalidFaxECM)
+	case "fax_encryption":
+		e.Fax.Encryption, err = parseSwitch(value, ErrInvalidFaxEncryption)
+	case "fax_encryption_key":
+		e.Fax.EncryptionKey, err = parseEncryptionKey(value)
+	default:
+		err = ErrUnknownField
+	}
+
+	return err
+}
+
+/*
+	Returns a pointer to a field of the Entry given its key, one of name,
+	username, email or DestinationFields, or nil if the key is unknown.
+*/
+
+func (e *Entry) field(key string) interface{} {
+	switch key {
+	case "name":
+		return &e.Name
+	case "username":
+		return &e.Username
+	case "email":
+		return &e.Email
+	case "smb_host":
+		return &e.SMB.Host
+	case "smb_path":
+		return &e.SMB.Path
+	case "smb_login":
+		return &e.SMB.Login
+	case "smb_password":
+		return &e.SMB.Password
+	case "smb_port":
+		return &e.SMB.Port
+	case "ftp_host":
+		return &e.FTP.Host
+	case "ftp_path":
+		return &e.FTP.Path
+	case "ftp_login":
+		return &e.FTP.Login
+	case "ftp_password":
+		return &e.FTP.Password
+	case "ftp_port":
+		return &e.FTP.Port
+	case "fax_number":
+		return &e.Fax.Number
+	case "fax_subaddress":
+		return &e.Fax.Subaddress
+	case "ifax_address":
+		return &e.Fax.IFaxAddress
+	case "fax_speed":
+		return &e.Fax.CommSpeed
+	case "fax_ecm":
+		return &e.Fax.ECM
+	case "fax_encryption":
+		return &e.Fax.Encryption
+	case "fax_encryption_key":
+		return &e.Fax.EncryptionKey
+	}
+
+	return nil
+}
+
+/*
+	Copies the given fields of src into the Entry and leaves the others as
+	they are, ie to update only the columns that are in a csv file. Unset
+	fields of src are copied too so that they clear the field. The merged
+	Entry is not checked, Validate or Update will check it.
+
+	src: the Entry the fields are copied from
+	keys: names of the fields ie name, email or smb_host
+*/
+
+func (e *Entry) MergeFields(src *Entry, keys ...string) error {
+	for _, key := range keys {
+		switch p := e.field(key).(type) {
+		case *string:
+			*p = *src.field(key).(*string)
+		case *int64:
+			*p = *src.field(key).(*int64)
+		default:
+			return ErrUnknownField
+		}
+	}
+
+	return nil
+}
+
+/*
+	Checks the value of a single field of an Entry given its key, one of
+	name, username, email or DestinationFields, without setting it. Names are
+	checked as NewEntry would set them.
+
+	key: name of the field ie email or smb_host
+	value: the value to check
+*/
+
+func CheckField(key, value string) error {
+	switch key {
+	case "name":
+		name := strings.Title(strings.ToLower(value))
+		return validateField(name, namePattern, ErrInvalidName)
+	case "username":
+		return validateField(value, usernamePattern, ErrInvalidUsername)
+	case "email":
+		return validateField(value, emailPattern, ErrInvalidEmail)
+	}
+
+	return new(Entry).SetField(key, value)
+}
+
+/*
+	Checks every field of the Entry, including those that depend on each
+	other, ie an SMB destination needs a host and a path. Returns the error
+	of the first field that is not valid.
+*/
+
+func (e *Entry) Validate() error {
+	return validateEntry(e)
 }
 
 /*
@@ -109,5 +393,24 @@ func validateEntry(e *Entry) error {
 		return err
 	}
 
-	return nil
+	err = validateSMB(e.SMB)
+	if err != nil {
+		return err
+	}
+
+	err = validateFTP(e.FTP)
+	if err != nil {
+		return err
+	}
+
+	err = validateKeySlots(e)
+	if err != nil {
+		return err
+	}
+
+	if e.Number < 0 || e.Number > MaxNumber {
+		return ErrInvalidNumber
+	}
+
+	return validateFax(e.Fax)
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 )
 
@@ -14,12 +15,12 @@ func TestDisplay(t *testing.T) {
 		{
 			description: "Entry 1",
 			got:         *e1,
-			expected:    "ID: 1\nName: Test One\nUsername: username1\nEmail: test1@test.com\n",
+			expected:    "ID: 1\nName: Test One\nUsername: username1\nEmail: test1@test.com\nNumber: 1\nSlot: 1\n",
 		},
 		{
 			description: "Entry 2",
 			got:         *e2,
-			expected:    "ID: 2\nName: Test Two\nUsername: username2\nEmail: test2@test.com\n",
+			expected:    "ID: 2\nName: Test Two\nUsername: username2\nEmail: test2@test.com\nNumber: 2\nSlot: 2\n",
 		},
 	}
 
@@ -187,3 +188,239 @@ func TestValidateField(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckField(t *testing.T) {
+	tt := []struct {
+		description string
+		key         string
+		value       string
+		expected    error
+	}{
+		{description: "valid name", key: "name", value: "jane doe"},
+		{description: "invalid name", key: "name", value: "jane_doe",
+			expected: ErrInvalidName},
+		{description: "invalid username", key: "username", value: "jane doe",
+			expected: ErrInvalidUsername},
+		{description: "invalid email", key: "email", value: "jane.email.com",
+			expected: ErrInvalidEmail},
+		{description: "valid smb host", key: "smb_host", value: "fileserver"},
+		{description: "invalid smb host", key: "smb_host", value: "file server",
+			expected: ErrInvalidSMBHost},
+		{description: "unknown field", key: "phone", value: "555-0100",
+			expected: ErrUnknownField},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			assertError(t, CheckField(tc.key, tc.value), tc.expected)
+		})
+	}
+}
+
+func TestSetField(t *testing.T) {
+	tt := []struct {
+		description string
+		key         string
+		value       string
+		expected    error
+	}{
+		{
+			description: "set smb host",
+			key:         "smb_host",
+			value:       "fileserver",
+			expected:    nil,
+		},
+		{
+			description: "set invalid smb host",
+			key:         "smb_host",
+			value:       "file server",
+			expected:    ErrInvalidSMBHost,
+		},
+		{
+			description: "set smb path",
+			key:         "smb_path",
+			value:       `scans\jdoe`,
+			expected:    nil,
+		},
+		{
+			description: "set invalid smb port",
+			key:         "smb_port",
+			value:       "port",
+			expected:    ErrInvalidPort,
+		},
+		{
+			description: "set ftp host",
+			key:         "ftp_host",
+			value:       "ftp.example.com",
+			expected:    nil,
+		},
+		{
+			description: "set invalid ftp login",
+			key:         "ftp_login",
+			value:       "j doe",
+			expected:    ErrInvalidFTPLogin,
+		},
+		{
+			description: "set fax number",
+			key:         "fax_number",
+			value:       "555-0100",
+			expected:    nil,
+		},
+		{
+			description: "set invalid fax number",
+			key:         "fax_number",
+			value:       "five five five",
+			expected:    ErrInvalidFaxNumber,
+		},
+		{
+			description: "set fax ecm",
+			key:         "fax_ecm",
+			value:       "OFF",
+			expected:    nil,
+		},
+		{
+			description: "set invalid fax encryption key",
+			key:         "fax_encryption_key",
+			value:       "21",
+			expected:    ErrInvalidFaxEncryptionKey,
+		},
+		{
+			description: "set unknown field",
+			key:         "phone_number",
+			value:       "555-5555",
+			expected:    ErrUnknownField,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			e := *e1
+			assertError(t, e.SetField(tc.key, tc.value), tc.expected)
+		})
+	}
+}
+
+func TestDestinations(t *testing.T) {
+	e := *e1
+	if got := e.Destinations(); !reflect.DeepEqual(got, []string{"EMAIL"}) {
+		t.Fatalf("got: %v, expected: %v", got, []string{"EMAIL"})
+	}
+
+	e.SMB = SMBDestination{Host: "fileserver", Path: `scans\jdoe`}
+	expected := []string{"EMAIL", "SMB"}
+	if got := e.Destinations(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+
+	e.FTP = FTPDestination{Host: "ftpserver", Path: "/scans/jdoe"}
+	expected = []string{"EMAIL", "SMB", "FTP"}
+	if got := e.Destinations(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+
+	e.Fax = FaxDestination{Number: "5550100", IFaxAddress: "fax@test.com"}
+	expected = []string{"EMAIL", "SMB", "FTP", "FAX", "IFAX"}
+	if got := e.Destinations(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+}
+
+func TestSetFieldNormalizesFaxSettings(t *testing.T) {
+	e := *e1
+	assertError(t, e.SetField("fax_number", "5550100"), nil)
+	assertError(t, e.SetField("fax_speed", "14400"), nil)
+	assertError(t, e.SetField("fax_ecm", "off"), nil)
+	assertError(t, e.SetField("fax_encryption", "ON"), nil)
+	assertError(t, e.SetField("fax_encryption_key", "2"), nil)
+
+	expected := FaxDestination{
+		Number:        "5550100",
+		CommSpeed:     "BPS_14400",
+		ECM:           "Off",
+		Encryption:    "On",
+		EncryptionKey: 2,
+	}
+
+	if e.Fax != expected {
+		t.Fatalf("got: %v, expected: %v", e.Fax, expected)
+	}
+
+	assertError(t, validateEntry(&e), nil)
+}
+
+func TestMergeFields(t *testing.T) {
+	existing := *e1
+	existing.SMB = SMBDestination{Host: "fileserver", Path: "scans"}
+	existing.Fax = FaxDestination{Number: "5550100"}
+
+	src := &Entry{Name: "Test Uno", Username: e1.Username, Email: e1.Email,
+		FTP: FTPDestination{Host: "ftpserver", Path: "/scans"}}
+
+	tt := []struct {
+		description string
+		keys        []string
+		expected    Entry
+		err         error
+	}{
+		{
+			description: "merge no fields",
+			expected:    existing,
+		},
+		{
+			description: "merge name",
+			keys:        []string{"name"},
+			expected: func() Entry {
+				e := existing
+				e.Name = src.Name
+				return e
+			}(),
+		},
+		{
+			description: "merge ftp and clear fax",
+			keys:        []string{"ftp_host", "ftp_path", "fax_number"},
+			expected: func() Entry {
+				e := existing
+				e.FTP = src.FTP
+				e.Fax = FaxDestination{}
+				return e
+			}(),
+		},
+		{
+			description: "merge unknown field",
+			keys:        []string{"phone_number"},
+			expected:    existing,
+			err:         ErrUnknownField,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got := existing
+			assertError(t, got.MergeFields(src, tc.keys...), tc.err)
+			assertEntry(t, &got, &tc.expected)
+		})
+	}
+}
+
+func TestMasked(t *testing.T) {
+	e := *e1
+	e.SMB = SMBDestination{Host: "fileserver", Path: "scans", Password: "secret"}
+	e.FTP = FTPDestination{Host: "ftpserver", Path: "/scans"}
+
+	got := e.Masked()
+	if got.SMB.Password != "********" || got.FTP.Password != "" {
+		t.Fatalf("got: %v, expected: %v", got, "masked smb password")
+	}
+
+	if e.SMB.Password != "secret" {
+		t.Fatalf("got: %v, expected: %v", e.SMB.Password, "secret")
+	}
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"fmt"
+)
+
+/*
+	StorageError is returned by a repository when the database itself fails
+	rather than the data given to it, ie the database file is locked by
+	another process or the disk is full. The session can carry on after one
+	is returned.
+
+	Op: the operation that failed, ie insert record
+	Kind: ErrDatabaseLocked or ErrStorage, matched with errors.Is
+	Err: the error returned by the database driver
+*/
+
+type StorageError struct {
+	Op   string
+	Kind error
+	Err  error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("cannot %v: %v: %v", e.Op, e.Kind, e.Err)
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+/*
+	Reports whether target is the Kind of the StorageError so that callers can
+	check for ErrDatabaseLocked or ErrStorage with errors.Is.
+*/
+
+func (e *StorageError) Is(target error) bool {
+	return target == e.Kind
+}
+
+/*
+	Wraps an error returned by the database or file system while running op
+	in a StorageError. Busy and locked databases are given the ErrDatabaseLocked
+	Kind, every other failure is given ErrStorage.
+*/
+
+func storageError(op string, err error) error {
+	kind := ErrStorage
+	if isLocked(err) {
+		kind = ErrDatabaseLocked
+	}
+
+	return &StorageError{Op: op, Kind: kind, Err: err}
+}
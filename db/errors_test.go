@@ -0,0 +1,142 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestStorageErrors(t *testing.T) {
+	repo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	// every statement fails once the database is closed
+	repo.db.Close()
+
+	tt := []struct {
+		description string
+		run         func() error
+	}{
+		{
+			description: "initialize",
+			run:         repo.Initialize,
+		},
+		{
+			description: "insert",
+			run: func() error {
+				_, err := repo.Insert(Entry{Name: "Jane Doe",
+					Username: "jdoe", Email: "jdoe@email.com"})
+				return err
+			},
+		},
+		{
+			description: "all",
+			run: func() error {
+				_, err := repo.All()
+				return err
+			},
+		},
+		{
+			description: "get by username",
+			run: func() error {
+				_, err := repo.GetByUsername(e1.Username)
+				return err
+			},
+		},
+		{
+			description: "update",
+			run: func() error {
+				_, err := repo.Update(e1.Username, e2)
+				return err
+			},
+		},
+		{
+			description: "delete",
+			run: func() error {
+				return repo.Delete(e1.Username)
+			},
+		},
+		{
+			description: "new table",
+			run: func() error {
+				return repo.NewTable("new_table")
+			},
+		},
+		{
+			description: "clear table",
+			run:         repo.ClearTable,
+		},
+		{
+			description: "table exists",
+			run: func() error {
+				_, err := repo.TableExists(DEFAULT_TABLE)
+				return err
+			},
+		},
+		{
+			description: "list tables",
+			run: func() error {
+				_, err := repo.ListTables()
+				return err
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			err := tc.run()
+			if !errors.Is(err, ErrStorage) {
+				t.Fatalf("got: %v, expected: %v", err, ErrStorage)
+			}
+
+			var storageErr *StorageError
+			if !errors.As(err, &storageErr) {
+				t.Fatalf("got: %T, expected: %T", err, storageErr)
+			}
+		})
+	}
+}
+
+func TestDatabaseLocked(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	locker, err := sql.Open("sqlite3", f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer locker.Close()
+
+	sqlite, err := sql.Open("sqlite3", f.Name()+"?_busy_timeout=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	repo, err := NewSQLiteRepository(sqlite)
+	assertError(t, err, nil)
+	assertError(t, repo.Initialize(), nil)
+
+	tx, err := locker.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("CREATE TABLE lock (id INTEGER)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Insert(*e1)
+	if !errors.Is(err, ErrDatabaseLocked) {
+		t.Fatalf("got: %v, expected: %v", err, ErrDatabaseLocked)
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+/*
+	Group struct models a Kyocera address book group, a named list of Entries
+	that a single OneTouchKey can send to. Groups belong to a table and their
+	members are Entries of the same table.
+
+	ID: a unique id of the Group in the database
+	Name: the name shown for the group on the scanner
+	Slot: position of the Group's OneTouchKey on the panel or NoSlot
+	Number: the Group's Id in the exported address book
+	Members: IDs of the Entries in the group, in the order they were added
+*/
+
+type Group struct {
+	ID      int64   `json:"id"`
+	Name    string  `json:"name"`
+	Slot    int64   `json:"slot,omitempty"`
+	Number  int64   `json:"number,omitempty"`
+	Members []int64 `json:"members"`
+}
+
+/*
+	Reports whether the Entry with the given ID is a member of the Group.
+*/
+
+func (g *Group) HasMember(id int64) bool {
+	for _, m := range g.Members {
+		if m == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Function that checks the name of a Group.
+*/
+
+func validateGroupName(name string) error {
+	return validateField(name, groupPattern, ErrInvalidGroupName)
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateGroupName(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		expected    error
+	}{
+		{
+			description: "valid group name",
+			input:       "Sales Team 2",
+			expected:    nil,
+		},
+		{
+			description: "empty group name",
+			input:       "",
+			expected:    ErrInvalidGroupName,
+		},
+		{
+			description: "group name with symbols",
+			input:       "sales;drop",
+			expected:    ErrInvalidGroupName,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assertError(t, validateGroupName(tc.input), tc.expected)
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	repos := []struct {
+		description string
+		repo        AddressBookRepository
+	}{
+		{description: "sqlite", repo: sqliteRepo},
+		{description: "json", repo: jsonRepo},
+	}
+
+	for _, rc := range repos {
+		repo := rc.repo
+		t.Run(rc.description, func(t *testing.T) {
+			g, err := repo.NewGroup("Sales")
+			assertError(t, err, nil)
+			if g.Name != "Sales" {
+				t.Fatalf("got: %v, expected: %v", g.Name, "Sales")
+			}
+
+			_, err = repo.NewGroup("Support")
+			assertError(t, err, nil)
+
+			_, err = repo.NewGroup("sales")
+			assertError(t, err, ErrGroupExists)
+
+			_, err = repo.NewGroup("sales;")
+			assertError(t, err, ErrInvalidGroupName)
+
+			_, err = repo.InsertGroup(Group{Name: "Marketing", Number: 2})
+			assertError(t, err, ErrNumberTaken)
+
+			assertError(t, repo.AddToGroup("Sales", e1.Username), nil)
+			assertError(t, repo.AddToGroup("sales", e3.Username), nil)
+			assertError(t, repo.AddToGroup("Support", e2.Username), nil)
+			assertError(t, repo.AddToGroup("Sales", e1.Username),
+				ErrAlreadyInGroup)
+			assertError(t, repo.AddToGroup("Marketing", e1.Username),
+				ErrGroupDoesNotExist)
+			assertError(t, repo.AddToGroup("Sales", "missing"), ErrNotFound)
+
+			groups, err := repo.Groups()
+			assertError(t, err, nil)
+			expected := []*Group{
+				{ID: groups[0].ID, Name: "Sales", Slot: 4, Number: 1,
+					Members: []int64{1, 3}},
+				{ID: groups[1].ID, Name: "Support", Slot: 5, Number: 2,
+					Members: []int64{2}},
+			}
+
+			if !reflect.DeepEqual(groups, expected) {
+				t.Fatalf("got: %v, expected: %v", groups, expected)
+			}
+
+			// deleting a user removes them from their groups
+			assertError(t, repo.Delete(e1.Username), nil)
+			groups, err = repo.Groups()
+			assertError(t, err, nil)
+			if !reflect.DeepEqual(groups[0].Members, []int64{3}) {
+				t.Fatalf("got: %v, expected: %v", groups[0].Members, []int64{3})
+			}
+
+			// groups belong to their table
+			assertError(t, repo.NewTable("new_table"), nil)
+			groups, err = repo.Groups()
+			assertError(t, err, nil)
+			if len(groups) != 0 {
+				t.Fatalf("got: %v, expected: %v", groups, nil)
+			}
+
+			_, err = repo.NewGroup("Sales")
+			assertError(t, err, nil)
+
+			// deleting a table deletes its groups
+			assertError(t, repo.DeleteTable("new_table"), nil)
+			assertError(t, repo.NewTable("new_table"), nil)
+			groups, err = repo.Groups()
+			assertError(t, err, nil)
+			if len(groups) != 0 {
+				t.Fatalf("got: %v, expected: %v", groups, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,1213 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+	jsonTable is a table of a JSONRepository as it is stored in the file.
+
+	Name: name of the table
+	NextID: ID given to the next Entry inserted, IDs are never reused
+	Entries: the Entries of the table in the order they were inserted
+	NextGroupID: ID given to the next Group created
+	Groups: the Groups of the table in the order they were created
+	Reserved: the reserved OneTouchKey slots of the table in order
+*/
+
+type jsonTable struct {
+	Name        string   `json:"name"`
+	NextID      int64    `json:"next_id"`
+	Entries     []*Entry `json:"entries"`
+	NextGroupID int64    `json:"next_group_id,omitempty"`
+	Groups      []*Group `json:"groups,omitempty"`
+	Reserved    []int64  `json:"reserved_slots,omitempty"`
+}
+
+/*
+	Removes the Entry with the given ID from every Group of the table.
+*/
+
+func (t *jsonTable) removeMember(id int64) {
+	for _, g := range t.Groups {
+		members := g.Members[:0]
+		for _, m := range g.Members {
+			if m != id {
+				members = append(members, m)
+			}
+		}
+
+		g.Members = members
+	}
+}
+
+/*
+	Returns the Group of the table with the given name, ignoring case, or nil
+	if there isn't one.
+*/
+
+func (t *jsonTable) group(name string) *Group {
+	for _, g := range t.Groups {
+		if strings.EqualFold(g.Name, name) {
+			return g
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the numbers of the Entries of the table that have been given one.
+*/
+
+func (t *jsonTable) entryNumbers() []int64 {
+	var numbers []int64
+	for _, e := range t.Entries {
+		if e.Number > 0 {
+			numbers = append(numbers, e.Number)
+		}
+	}
+
+	return numbers
+}
+
+/*
+	Returns the numbers of the Groups of the table that have been given one.
+*/
+
+func (t *jsonTable) groupNumbers() []int64 {
+	var numbers []int64
+	for _, g := range t.Groups {
+		if g.Number > 0 {
+			numbers = append(numbers, g.Number)
+		}
+	}
+
+	return numbers
+}
+
+/*
+	Reports whether a number is given to one of the Groups of the table.
+*/
+
+func (t *jsonTable) groupNumberTaken(number int64) bool {
+	for _, g := range t.Groups {
+		if g.Number == number {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Returns the slots of the table that are given to a OneTouchKey or
+	reserved.
+*/
+
+func (t *jsonTable) usedSlots() map[int64]bool {
+	used := make(map[int64]bool)
+	for _, e := range t.Entries {
+		for _, slot := range []int64{e.Slot, e.SMBSlot, e.FTPSlot} {
+			if slot > 0 {
+				used[slot] = true
+			}
+		}
+	}
+
+	for _, g := range t.Groups {
+		if g.Slot > 0 {
+			used[g.Slot] = true
+		}
+	}
+
+	for _, slot := range t.Reserved {
+		used[slot] = true
+	}
+
+	return used
+}
+
+/*
+	Checks that a slot of the table is not reserved, given to a Group or to a
+	OneTouchKey other than the email key of the Entry with the given
+	username.
+*/
+
+func (t *jsonTable) checkSlot(slot int64, username string) error {
+	if slot == NoSlot {
+		return nil
+	}
+
+	for _, s := range t.Reserved {
+		if s == slot {
+			return ErrSlotReserved
+		}
+	}
+
+	for _, e := range t.Entries {
+		if (e.Slot == slot && e.Username != username) ||
+			e.SMBSlot == slot || e.FTPSlot == slot {
+			return ErrSlotTaken
+		}
+	}
+
+	for _, g := range t.Groups {
+		if g.Slot == slot {
+			return ErrSlotTaken
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the Entry of the table with the given username or nil if there
+	isn't one.
+*/
+
+func (t *jsonTable) entry(username string) *Entry {
+	for _, e := range t.Entries {
+		if e.Username == username {
+			return e
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the Entry of the table with the given address book number or nil
+	if there isn't one.
+*/
+
+func (t *jsonTable) number(n int64) *Entry {
+	for _, e := range t.Entries {
+		if e.Number == n {
+			return e
+		}
+	}
+
+	return nil
+}
+
+/*
+	jsonBook is the content of the file of a JSONRepository.
+*/
+
+type jsonBook struct {
+	Tables []*jsonTable `json:"tables"`
+}
+
+/*
+	Returns a copy of the jsonTable that can be changed without changing the
+	original.
+*/
+
+func (t *jsonTable) clone() *jsonTable {
+	entries := make([]*Entry, len(t.Entries))
+	for i, e := range t.Entries {
+		entry := *e
+		entries[i] = &entry
+	}
+
+	groups := make([]*Group, len(t.Groups))
+	for i, g := range t.Groups {
+		members := append([]int64(nil), g.Members...)
+		groups[i] = &Group{ID: g.ID, Name: g.Name, Slot: g.Slot,
+			Number: g.Number, Members: members}
+	}
+
+	return &jsonTable{Name: t.Name, NextID: t.NextID, Entries: entries,
+		NextGroupID: t.NextGroupID, Groups: groups,
+		Reserved: append([]int64(nil), t.Reserved...)}
+}
+
+/*
+	Returns a copy of the jsonBook that can be changed without changing the
+	original.
+*/
+
+func (b *jsonBook) clone() *jsonBook {
+	c := &jsonBook{Tables: make([]*jsonTable, len(b.Tables))}
+	for i, t := range b.Tables {
+		c.Tables[i] = t.clone()
+	}
+
+	return c
+}
+
+/*
+	Returns the table with the given name or nil if there isn't one.
+*/
+
+func (b *jsonBook) table(tableName string) *jsonTable {
+	for _, t := range b.Tables {
+		if t.Name == tableName {
+			return t
+		}
+	}
+
+	return nil
+}
+
+/*
+	JSONRepository struct stores the address book in a single JSON file. It
+	does not need CGO so it can be used where SQLiteRepository cannot be
+	built, ie cross-compiling for Windows.
+
+	path: location of the JSON file
+	book: the tables of the file, every change is written back to path
+	currentTable: the table that certain operations will be ran against
+	inTransaction: changes are only kept in book until Transaction writes them
+*/
+
+type JSONRepository struct {
+	path          string
+	book          *jsonBook
+	currentTable  string
+	inTransaction bool
+}
+
+var _ AddressBookRepository = (*JSONRepository)(nil)
+
+/*
+	JSONRepository struct constructor
+
+	Given the path of the JSON file a reference to a new JSONRepository will
+	be returned. The file is read and created by Initialize.
+*/
+
+func NewJSONRepository(path string) (*JSONRepository, error) {
+	err := validateTableName(DEFAULT_TABLE)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONRepository{
+		path:         path,
+		book:         &jsonBook{},
+		currentTable: DEFAULT_TABLE,
+	}, nil
+}
+
+func (r *JSONRepository) CurrentTable() string {
+	return r.currentTable
+}
+
+/*
+	Reads the JSON file, if it exists, and creates the default table. Entries
+	written before OneTouchKey slots and address book numbers were stored are
+	given the number of their position in their table and the lowest slot
+	that is not taken, and their SMB, FTP and group keys the slots they were
+	exported with, see backfillKeySlots. Groups written before their numbers
+	were stored are numbered in the order they were created.
+
+	Returns a StorageError if the file cannot be read or written
+*/
+
+func (r *JSONRepository) Initialize() error {
+	b, err := ioutil.ReadFile(r.path)
+	switch {
+	case os.IsNotExist(err):
+		r.book = &jsonBook{}
+	case err != nil:
+		return storageError("read database", err)
+	default:
+		book := &jsonBook{}
+		err = json.Unmarshal(b, book)
+		if err != nil {
+			return storageError("read database", err)
+		}
+
+		r.book = book
+	}
+
+	if r.book.table(DEFAULT_TABLE) != nil && !unassigned(r.book) {
+		return nil
+	}
+
+	return r.commit(func(b *jsonBook) error {
+		for _, t := range b.Tables {
+			used := t.usedSlots()
+			for _, e := range t.Entries {
+				if e.Slot == 0 {
+					e.Slot = nextSlot(used)
+					used[e.Slot] = true
+				}
+
+				if e.Number == 0 {
+					n, err := nextNumber(t.entryNumbers())
+					if err != nil {
+						return err
+					}
+
+					e.Number = n
+				}
+			}
+
+			backfillKeySlots(t.Entries, t.Groups, t.Reserved)
+
+			for _, g := range t.Groups {
+				if g.Number == 0 {
+					n, err := nextNumber(t.groupNumbers())
+					if err != nil {
+						return err
+					}
+
+					g.Number = n
+				}
+			}
+		}
+
+		if b.table(DEFAULT_TABLE) == nil {
+			b.Tables = append(b.Tables, &jsonTable{Name: DEFAULT_TABLE, NextID: 1})
+		}
+
+		return nil
+	})
+}
+
+/*
+	Reports whether any Entry of the book has not been given a slot or an
+	address book number, any of its destinations has not been given a slot
+	or any Group has not been given a slot or a number.
+*/
+
+func unassigned(b *jsonBook) bool {
+	for _, t := range b.Tables {
+		for _, e := range t.Entries {
+			if e.Slot == 0 || e.Number == 0 ||
+				(e.SMB.IsSet() && e.SMBSlot == 0) ||
+				(e.FTP.IsSet() && e.FTPSlot == 0) {
+				return true
+			}
+		}
+
+		for _, g := range t.Groups {
+			if g.Slot == 0 || g.Number == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+	Applies change to a copy of the tables and writes the copy to the JSON
+	file. The tables are only replaced once the file has been written so a
+	failed change leaves the repository as it was. The file is not written
+	inside of a Transaction.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) commit(change func(b *jsonBook) error) error {
+	book := r.book.clone()
+	err := change(book)
+	if err != nil {
+		return err
+	}
+
+	if r.inTransaction {
+		r.book = book
+		return nil
+	}
+
+	data, err := json.MarshalIndent(book, "", "  ")
+	if err != nil {
+		return storageError("write database", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(r.path), filepath.Base(r.path))
+	if err != nil {
+		return storageError("write database", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return storageError("write database", err)
+	}
+
+	err = os.Rename(tmp.Name(), r.path)
+	if err != nil {
+		return storageError("write database", err)
+	}
+
+	r.book = book
+	return nil
+}
+
+/*
+	Checks that no Entry of t, other than the one with the given ID, has the
+	username or email of e.
+*/
+
+func checkDuplicate(t *jsonTable, e *Entry, id int64) error {
+	for _, entry := range t.Entries {
+		if entry.ID == id {
+			continue
+		}
+
+		if entry.Username == e.Username || entry.Email == e.Email {
+			return ErrDuplicate
+		}
+	}
+
+	return nil
+}
+
+/*
+	Inserts en Entry into currentTable and returns the reference of the Entry
+	with an ID given to it.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) Insert(e Entry) (*Entry, error) {
+	err := validateEntry(&e)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		err := assignKeySlots(&e, t.usedSlots(), func(slot int64) error {
+			return t.checkSlot(slot, e.Username)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = checkDuplicate(t, &e, 0)
+		if err != nil {
+			return err
+		}
+
+		if e.Number == 0 {
+			var numbers []int64
+			for _, entry := range t.Entries {
+				numbers = append(numbers, entry.Number)
+			}
+
+			e.Number, err = nextNumber(numbers)
+			if err != nil {
+				return err
+			}
+		} else if t.number(e.Number) != nil {
+			return ErrNumberTaken
+		}
+
+		e.ID = t.NextID
+		t.NextID++
+
+		entry := e
+		t.Entries = append(t.Entries, &entry)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+/*
+	Returns all the Entries of currentTable.
+*/
+
+func (r *JSONRepository) All() (all []*Entry, err error) {
+	t := r.book.table(r.currentTable)
+	if t == nil {
+		return nil, ErrTableDoesNotExist
+	}
+
+	for _, e := range t.Entries {
+		entry := *e
+		all = append(all, &entry)
+	}
+
+	return all, nil
+}
+
+/*
+	Returns a reference to the Entry of currentTable with the given username.
+*/
+
+func (r *JSONRepository) GetByUsername(username string) (*Entry, error) {
+	err := validateField(username, usernamePattern, ErrInvalidUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	t := r.book.table(r.currentTable)
+	if t == nil {
+		return nil, ErrTableDoesNotExist
+	}
+
+	for _, e := range t.Entries {
+		if e.Username == username {
+			entry := *e
+			return &entry, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+/*
+	Updates an Entry in the currentTable given it's username with the newly
+	updated Entry. Returns the updated entry if there are no issues. The
+	OneTouchKeys of the Entry keep their slots, a destination that is added
+	is given a slot like Insert.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) Update(username string, u *Entry) (*Entry, error) {
+	err := validateField(username, usernamePattern, ErrInvalidUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateEntry(u)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *u
+	err = r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		for i, e := range t.Entries {
+			if e.Username != username {
+				continue
+			}
+
+			err := checkDuplicate(t, u, e.ID)
+			if err != nil {
+				return err
+			}
+
+			entry := *u
+			entry.ID = e.ID
+			entry.Slot, entry.SMBSlot, entry.FTPSlot = e.Slot, e.SMBSlot, e.FTPSlot
+			entry.Number = e.Number
+			err = assignKeySlots(&entry, t.usedSlots(), nil)
+			if err != nil {
+				return err
+			}
+
+			t.Entries[i] = &entry
+			updated.Slot, updated.SMBSlot, updated.FTPSlot =
+				entry.Slot, entry.SMBSlot, entry.FTPSlot
+			return nil
+		}
+
+		return ErrUpdateFailed
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+/*
+	Deletes an Entry in the currentTable given a valid username.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) Delete(username string) error {
+	err := validateField(username, usernamePattern, ErrInvalidUsername)
+	if err != nil {
+		return err
+	}
+
+	return r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		for i, e := range t.Entries {
+			if e.Username == username {
+				t.Entries = append(t.Entries[:i], t.Entries[i+1:]...)
+				t.removeMember(e.ID)
+				return nil
+			}
+		}
+
+		return ErrDeleteFailed
+	})
+}
+
+/*
+	Creates a new table. currentTable is updated if the table is valid and
+	does not already exist.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) NewTable(tableName string) error {
+	exists, err := r.TableExists(tableName)
+	if err != nil && !errors.Is(err, ErrTableDoesNotExist) {
+		return err
+	}
+
+	if exists {
+		return ErrTableExists
+	}
+
+	err = r.commit(func(b *jsonBook) error {
+		b.Tables = append(b.Tables, &jsonTable{Name: tableName, NextID: 1})
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	r.currentTable = tableName
+	return nil
+}
+
+/*
+	Updates the currentTable variable to to the tableName parameter given the
+	table exists and has a valid name.
+*/
+
+func (r *JSONRepository) SwitchTable(tableName string) error {
+	exists, err := r.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrTableDoesNotExist
+	}
+
+	r.currentTable = tableName
+	return nil
+}
+
+/*
+	Wipes the current table.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) ClearTable() error {
+	return r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		for _, e := range t.Entries {
+			t.removeMember(e.ID)
+		}
+
+		t.Entries = nil
+		return nil
+	})
+}
+
+/*
+	Checks for the existence of a the given tableName and will return a bool
+	for the table's existence. If the table does not exist an error will be
+	returned to the caller as to why not.
+*/
+
+func (r *JSONRepository) TableExists(tableName string) (bool, error) {
+	err := validateTableName(tableName)
+	if err != nil {
+		return false, ErrInvalidTableName
+	}
+
+	if r.book.table(tableName) != nil {
+		return true, nil
+	}
+
+	return false, ErrTableDoesNotExist
+}
+
+/*
+	Delete the table that is passed. This function cannot and will not delete
+	the DEFAULT_TABLE.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) DeleteTable(tableName string) error {
+	_, err := r.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+
+	if tableName == DEFAULT_TABLE {
+		return ErrTableCannotBeDeleted
+	}
+
+	err = r.commit(func(b *jsonBook) error {
+		for i, t := range b.Tables {
+			if t.Name == tableName {
+				b.Tables = append(b.Tables[:i], b.Tables[i+1:]...)
+				break
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if r.currentTable == tableName {
+		r.currentTable = DEFAULT_TABLE
+	}
+
+	return nil
+}
+
+/*
+	Creates a new table that is a copy of an existing table, with its Entries,
+	Groups and reserved slots. The Entries keep their IDs, slots and numbers.
+	currentTable is updated to the copy like NewTable.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) CopyTable(tableName, newName string) error {
+	_, err := r.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+
+	exists, err := r.TableExists(newName)
+	if err != nil && !errors.Is(err, ErrTableDoesNotExist) {
+		return err
+	}
+
+	if exists {
+		return ErrTableExists
+	}
+
+	err = r.commit(func(b *jsonBook) error {
+		t := b.table(tableName).clone()
+		t.Name = newName
+		b.Tables = append(b.Tables, t)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	r.currentTable = newName
+	return nil
+}
+
+/*
+	Renames a table along with its Groups and reserved slots. currentTable is
+	updated if it is the table being renamed. The DEFAULT_TABLE cannot be
+	renamed.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) RenameTable(tableName, newName string) error {
+	_, err := r.TableExists(tableName)
+	if err != nil {
+		return err
+	}
+
+	if tableName == DEFAULT_TABLE {
+		return ErrTableCannotBeRenamed
+	}
+
+	exists, err := r.TableExists(newName)
+	if err != nil && !errors.Is(err, ErrTableDoesNotExist) {
+		return err
+	}
+
+	if exists {
+		return ErrTableExists
+	}
+
+	err = r.commit(func(b *jsonBook) error {
+		b.table(tableName).Name = newName
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if r.currentTable == tableName {
+		r.currentTable = newName
+	}
+
+	return nil
+}
+
+/*
+	List all tables in the order they were created.
+*/
+
+func (r *JSONRepository) ListTables() (tables []string, err error) {
+	for _, t := range r.book.Tables {
+		tables = append(tables, t.Name)
+	}
+
+	return tables, nil
+}
+
+/*
+	Creates a new Group in the currentTable and returns it.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) NewGroup(name string) (*Group, error) {
+	return r.InsertGroup(Group{Name: name})
+}
+
+/*
+	Creates a Group in the currentTable with the name, slot and number of g
+	and returns it, its members are added with AddToGroup. A slot of 0 is
+	given the lowest slot that is not taken or reserved, any other slot is
+	checked to not be taken or reserved. A number of 0 is given the number
+	after the highest number of the table's Groups, any other number is
+	checked to not be taken.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) InsertGroup(g Group) (*Group, error) {
+	err := validateGroupName(g.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if g.Slot != 0 {
+		err = validateSlot(g.Slot)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if g.Number < 0 || g.Number > MaxNumber {
+		return nil, ErrInvalidNumber
+	}
+
+	var created *Group
+	err = r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		if t.group(g.Name) != nil {
+			return ErrGroupExists
+		}
+
+		slot := g.Slot
+		if slot == 0 {
+			slot = nextSlot(t.usedSlots())
+		} else if err := t.checkSlot(slot, ""); err != nil {
+			return err
+		}
+
+		number := g.Number
+		if number == 0 {
+			n, err := nextNumber(t.groupNumbers())
+			if err != nil {
+				return err
+			}
+
+			number = n
+		} else if t.groupNumberTaken(number) {
+			return ErrNumberTaken
+		}
+
+		t.NextGroupID++
+		created = &Group{ID: t.NextGroupID, Name: g.Name, Slot: slot,
+			Number: number}
+		t.Groups = append(t.Groups, created)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Group{ID: created.ID, Name: created.Name, Slot: created.Slot,
+		Number: created.Number}, nil
+}
+
+/*
+	Adds the Entry with the given username to the Group of the currentTable
+	with the given name.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) AddToGroup(name, username string) error {
+	err := validateGroupName(name)
+	if err != nil {
+		return err
+	}
+
+	e, err := r.GetByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	return r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		g := t.group(name)
+		if g == nil {
+			return ErrGroupDoesNotExist
+		}
+
+		if g.HasMember(e.ID) {
+			return ErrAlreadyInGroup
+		}
+
+		g.Members = append(g.Members, e.ID)
+		return nil
+	})
+}
+
+/*
+	Returns the Groups of the currentTable in the order they were created.
+*/
+
+func (r *JSONRepository) Groups() ([]*Group, error) {
+	t := r.book.table(r.currentTable)
+	if t == nil {
+		return nil, ErrTableDoesNotExist
+	}
+
+	var groups []*Group
+	for _, g := range t.Groups {
+		members := append([]int64(nil), g.Members...)
+		groups = append(groups, &Group{ID: g.ID, Name: g.Name, Slot: g.Slot,
+			Number: g.Number, Members: members})
+	}
+
+	return groups, nil
+}
+
+/*
+	Moves the OneTouchKey of the Entry with the given username to a slot of
+	the currentTable, NoSlot removes the Entry's OneTouchKey.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) SetSlot(username string, slot int64) error {
+	err := validateField(username, usernamePattern, ErrInvalidUsername)
+	if err != nil {
+		return err
+	}
+
+	err = validateSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	return r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		e := t.entry(username)
+		if e == nil {
+			return ErrNotFound
+		}
+
+		err := t.checkSlot(slot, username)
+		if err != nil {
+			return err
+		}
+
+		e.Slot = slot
+		return nil
+	})
+}
+
+/*
+	Swaps the slots of the OneTouchKeys of two Entries of the currentTable.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) SwapSlots(username, other string) error {
+	for _, u := range []string{username, other} {
+		err := validateField(u, usernamePattern, ErrInvalidUsername)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		e, o := t.entry(username), t.entry(other)
+		if e == nil || o == nil {
+			return ErrNotFound
+		}
+
+		e.Slot, o.Slot = o.Slot, e.Slot
+		return nil
+	})
+}
+
+/*
+	Reserves a slot of the currentTable so that it is not given to inserted
+	Entries and left empty when exported.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) ReserveSlot(slot int64) error {
+	if slot == NoSlot {
+		return ErrInvalidSlot
+	}
+
+	err := validateSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	return r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		err := t.checkSlot(slot, "")
+		if err != nil {
+			return err
+		}
+
+		t.Reserved = append(t.Reserved, slot)
+		sort.Slice(t.Reserved, func(i, j int) bool {
+			return t.Reserved[i] < t.Reserved[j]
+		})
+
+		return nil
+	})
+}
+
+/*
+	Releases a reserved slot of the currentTable.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) ReleaseSlot(slot int64) error {
+	return r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		for i, s := range t.Reserved {
+			if s == slot {
+				t.Reserved = append(t.Reserved[:i], t.Reserved[i+1:]...)
+				return nil
+			}
+		}
+
+		return ErrSlotNotReserved
+	})
+}
+
+/*
+	Returns the reserved slots of the currentTable in order.
+*/
+
+func (r *JSONRepository) ReservedSlots() ([]int64, error) {
+	t := r.book.table(r.currentTable)
+	if t == nil {
+		return nil, ErrTableDoesNotExist
+	}
+
+	return append([]int64(nil), t.Reserved...), nil
+}
+
+/*
+	Renumbers the Entries of the currentTable from 1 without gaps, keeping
+	their order. Returns the number of Entries that were given a new number.
+
+	Returns a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) CompactNumbers() (int, error) {
+	var n int
+	err := r.commit(func(b *jsonBook) error {
+		t := b.table(r.currentTable)
+		if t == nil {
+			return ErrTableDoesNotExist
+		}
+
+		entries := append([]*Entry(nil), t.Entries...)
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Number < entries[j].Number
+		})
+
+		numbers := compactNumbers(entries)
+		for _, e := range t.Entries {
+			if number, ok := numbers[e.ID]; ok {
+				e.Number = number
+			}
+		}
+
+		n = len(numbers)
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/*
+	Function to create a JSONRepository in a temporary directory and return
+	the clean up function. Unlike setup the test is not made parallel so it
+	can be used along side it.
+*/
+
+func setupJSON(t *testing.T) (*JSONRepository, string, func()) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "address_book.json")
+	repo, err := NewJSONRepository(path)
+	assertError(t, err, nil)
+	assertError(t, repo.Initialize(), nil)
+
+	return repo, path, func() { os.RemoveAll(dir) }
+}
+
+/*
+	Function to create a JSONRepository and load Entries into it and return
+	the clean up function.
+*/
+
+func setupJSONWithInserts(t *testing.T) (*JSONRepository, string, func()) {
+	repo, path, teardown := setupJSON(t)
+
+	for _, e := range []*Entry{e1, e2, e3} {
+		_, err := repo.Insert(*e)
+		assertError(t, err, nil)
+	}
+
+	return repo, path, teardown
+}
+
+func TestJSONEntries(t *testing.T) {
+	t.Parallel()
+
+	repo, _, teardown := setupJSONWithInserts(t)
+	defer teardown()
+
+	updated := &Entry{Name: "Test Four", Username: "username4",
+		Email: "test4@test.com"}
+
+	tt := []struct {
+		description string
+		run         func() (*Entry, error)
+		expected    entryInfo
+	}{
+		{
+			description: "get existing entry",
+			run:         func() (*Entry, error) { return repo.GetByUsername(e2.Username) },
+			expected:    entryInfo{entry: e2},
+		},
+		{
+			description: "get non-existing entry",
+			run:         func() (*Entry, error) { return repo.GetByUsername("missing") },
+			expected:    entryInfo{err: ErrNotFound},
+		},
+		{
+			description: "insert duplicate username",
+			run: func() (*Entry, error) {
+				return repo.Insert(Entry{Name: "Test", Username: e1.Username,
+					Email: "test@test.com"})
+			},
+			expected: entryInfo{err: ErrDuplicate},
+		},
+		{
+			description: "insert duplicate email",
+			run: func() (*Entry, error) {
+				return repo.Insert(Entry{Name: "Test", Username: "test",
+					Email: e1.Email})
+			},
+			expected: entryInfo{err: ErrDuplicate},
+		},
+		{
+			description: "update entry to an existing email",
+			run: func() (*Entry, error) {
+				return repo.Update(e3.Username, &Entry{Name: e3.Name,
+					Username: e3.Username, Email: e1.Email})
+			},
+			expected: entryInfo{err: ErrDuplicate},
+		},
+		{
+			description: "update non-existing entry",
+			run:         func() (*Entry, error) { return repo.Update("missing", updated) },
+			expected:    entryInfo{err: ErrUpdateFailed},
+		},
+		{
+			description: "update entry",
+			run: func() (*Entry, error) {
+				_, err := repo.Update(e3.Username, updated)
+				if err != nil {
+					return nil, err
+				}
+
+				return repo.GetByUsername(updated.Username)
+			},
+			expected: entryInfo{entry: &Entry{ID: 3, Name: "Test Four",
+				Username: "username4", Email: "test4@test.com", Slot: 3, Number: 3}},
+		},
+		{
+			description: "delete non-existing entry",
+			run:         func() (*Entry, error) { return nil, repo.Delete("missing") },
+			expected:    entryInfo{err: ErrDeleteFailed},
+		},
+		{
+			description: "delete then insert does not reuse id",
+			run: func() (*Entry, error) {
+				err := repo.Delete(e1.Username)
+				if err != nil {
+					return nil, err
+				}
+
+				return repo.Insert(*e1)
+			},
+			expected: entryInfo{entry: &Entry{ID: 4, Name: e1.Name,
+				Username: e1.Username, Email: e1.Email, Slot: e1.Slot,
+				Number: e1.Number}},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			e, err := tc.run()
+			assertEntryInfo(t, entryInfo{entry: e, err: err}, tc.expected)
+		})
+	}
+}
+
+func TestJSONTables(t *testing.T) {
+	t.Parallel()
+
+	repo, path, teardown := setupJSONWithInserts(t)
+	defer teardown()
+
+	assertError(t, repo.NewTable("new_table"), nil)
+	assertError(t, repo.NewTable("new_table"), ErrTableExists)
+	assertError(t, repo.NewTable("sql_table"), ErrInvalidTableName)
+	assertError(t, repo.SwitchTable("missing"), ErrTableDoesNotExist)
+	assertError(t, repo.DeleteTable(DEFAULT_TABLE), ErrTableCannotBeDeleted)
+
+	_, err := repo.Insert(*e1)
+	assertError(t, err, nil)
+
+	t.Run("tables are kept in the file", func(t *testing.T) {
+		reopened, err := NewJSONRepository(path)
+		assertError(t, err, nil)
+		assertError(t, reopened.Initialize(), nil)
+
+		got, err := reopened.ListTables()
+		assertError(t, err, nil)
+
+		expected := []string{DEFAULT_TABLE, "new_table"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("got: %v, expected: %v", got, expected)
+		}
+
+		assertError(t, reopened.SwitchTable("new_table"), nil)
+		e, err := reopened.GetByUsername(e1.Username)
+		assertEntryInfo(t, entryInfo{entry: e, err: err}, entryInfo{entry: e1})
+	})
+
+	t.Run("clear and delete table", func(t *testing.T) {
+		assertError(t, repo.ClearTable(), nil)
+
+		all, err := repo.All()
+		assertError(t, err, nil)
+		if len(all) != 0 {
+			t.Fatalf("got: %v, expected: %v", len(all), 0)
+		}
+
+		assertError(t, repo.DeleteTable("new_table"), nil)
+		if repo.CurrentTable() != DEFAULT_TABLE {
+			t.Fatalf("got: %v, expected: %v", repo.CurrentTable(),
+				DEFAULT_TABLE)
+		}
+	})
+}
+
+func TestJSONWriteFailure(t *testing.T) {
+	t.Parallel()
+
+	repo, path, teardown := setupJSONWithInserts(t)
+	defer teardown()
+
+	// removing the directory makes every write to the file fail
+	os.RemoveAll(filepath.Dir(path))
+
+	_, err := repo.Insert(Entry{Name: "Jane Doe", Username: "jdoe",
+		Email: "jdoe@email.com"})
+	if !errors.Is(err, ErrStorage) {
+		t.Fatalf("got: %v, expected: %v", err, ErrStorage)
+	}
+
+	all, err := repo.All()
+	assertError(t, err, nil)
+	if len(all) != 3 {
+		t.Fatalf("got: %v, expected: %v", len(all), 3)
+	}
+}
+
+func TestJSONNumberBackfill(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the first Entry was written before numbers were given out
+	path := filepath.Join(dir, "address_book.json")
+	err = ioutil.WriteFile(path, []byte(`{"tables":[{"name":"`+
+		DEFAULT_TABLE+`","next_id":3,"entries":[`+
+		`{"id":1,"name":"Jane Doe","username":"janedoe","email":"jane@test.com"},`+
+		`{"id":2,"name":"John Doe","username":"johndoe","email":"john@test.com",`+
+		`"slot":2,"number":1}]}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewJSONRepository(path)
+	assertError(t, err, nil)
+	assertError(t, repo.Initialize(), nil)
+
+	all, err := repo.All()
+	assertError(t, err, nil)
+
+	got := make(map[string]int64)
+	for _, e := range all {
+		got[e.Username] = e.Number
+	}
+
+	expected := map[string]int64{"janedoe": 2, "johndoe": 1}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+}
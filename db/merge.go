@@ -0,0 +1,224 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Strategies that MergeTable uses for an Entry of the source table whose
+	username or email is already in the current table.
+
+	MergeSkip: the Entry is not merged
+	MergeOverwrite: the Entry of the current table is replaced, keeping its
+	OneTouchKey slot and number
+	MergeRename: the Entry is inserted with a new username, ie jdoe_2. Entries
+	whose email is taken are skipped as an email cannot be renamed
+*/
+
+type MergeStrategy string
+
+const (
+	MergeSkip      MergeStrategy = "skip"
+	MergeOverwrite MergeStrategy = "overwrite"
+	MergeRename    MergeStrategy = "rename"
+)
+
+/*
+	Parses a MergeStrategy given by the user, ignoring case.
+*/
+
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	strategy := MergeStrategy(strings.ToLower(strings.TrimSpace(s)))
+	switch strategy {
+	case MergeSkip, MergeOverwrite, MergeRename:
+		return strategy, nil
+	}
+
+	return "", ErrInvalidMergeStrategy
+}
+
+/*
+	MergeRenamed describes an Entry that was merged under a new username.
+
+	From: username of the Entry in the source table
+	To: username the Entry was inserted with
+*/
+
+type MergeRenamed struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+/*
+	MergeReport holds the outcome of MergeTable for every Entry of the source
+	table, by username in the source table.
+
+	Added: Entries that did not collide and were inserted
+	Skipped: Entries that collided and were not merged
+	Overwritten: Entries that replaced an Entry of the current table
+	Renamed: Entries that were inserted under a new username
+*/
+
+type MergeReport struct {
+	Added       []string       `json:"added,omitempty"`
+	Skipped     []string       `json:"skipped,omitempty"`
+	Overwritten []string       `json:"overwritten,omitempty"`
+	Renamed     []MergeRenamed `json:"renamed,omitempty"`
+}
+
+/*
+	Returns the number of Entries that were inserted into or replaced in the
+	current table.
+*/
+
+func (m *MergeReport) Count() int {
+	return len(m.Added) + len(m.Overwritten) + len(m.Renamed)
+}
+
+/*
+	Returns the Entries of current that have the username or the email of e,
+	at most one for each.
+*/
+
+func collisions(current []*Entry, e *Entry) (byUsername, byEmail *Entry) {
+	for _, c := range current {
+		if c.Username == e.Username {
+			byUsername = c
+		}
+
+		if strings.EqualFold(c.Email, e.Email) {
+			byEmail = c
+		}
+	}
+
+	return byUsername, byEmail
+}
+
+/*
+	Returns the first username of the form username_N that is not in current.
+*/
+
+func renameUsername(current []*Entry, username string) string {
+	taken := make(map[string]bool)
+	for _, c := range current {
+		taken[c.Username] = true
+	}
+
+	for n := 2; ; n++ {
+		s := fmt.Sprintf("%v_%d", username, n)
+		if !taken[s] {
+			return s
+		}
+	}
+}
+
+/*
+	Inserts the Entries of the source table into the current table. Entries
+	whose username or email is already in the current table are handled by
+	the strategy. Merged Entries are given new OneTouchKey slots and numbers
+	as those of the source table belong to it, and Groups and reserved slots
+	are not merged. The Entries are merged in a single transaction and the
+	current table is left as it was.
+
+	Returns a report of what happened to each Entry of the source table, or
+	an empty report and the error if any Entry could not be merged, in which
+	case none are.
+*/
+
+func MergeTable(r AddressBookRepository, source string, strategy MergeStrategy) (*MergeReport, error) {
+	report := &MergeReport{}
+	target := r.CurrentTable()
+	if source == target {
+		return report, ErrMergeSameTable
+	}
+
+	err := r.SwitchTable(source)
+	if err != nil {
+		return report, err
+	}
+
+	entries, err := r.All()
+	if err != nil {
+		switchErr := r.SwitchTable(target)
+		if switchErr != nil {
+			return report, fmt.Errorf("%w: switching back to %v: %v", err,
+				target, switchErr)
+		}
+
+		return report, err
+	}
+
+	err = r.SwitchTable(target)
+	if err != nil {
+		return report, err
+	}
+
+	err = r.Transaction(func(tx AddressBookRepository) error {
+		return mergeEntries(tx, entries, strategy, report)
+	})
+
+	if err != nil {
+		return &MergeReport{}, err
+	}
+
+	return report, nil
+}
+
+/*
+	Merges entries into the current table of r by the strategy, recording
+	what happened to each of them in report.
+*/
+
+func mergeEntries(r AddressBookRepository, entries []*Entry, strategy MergeStrategy, report *MergeReport) error {
+	current, err := r.All()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		merged := *e
+		merged.ID, merged.Slot, merged.Number = 0, 0, 0
+		merged.SMBSlot, merged.FTPSlot = 0, 0
+
+		byUsername, byEmail := collisions(current, &merged)
+		switch {
+		case byUsername == nil && byEmail == nil:
+		case strategy == MergeOverwrite &&
+			(byUsername == nil || byEmail == nil || byUsername == byEmail):
+			old := byUsername
+			if old == nil {
+				old = byEmail
+			}
+
+			_, err := r.Update(old.Username, &merged)
+			if err != nil {
+				return fmt.Errorf("%w: %v", err, e.Username)
+			}
+
+			old.Username, old.Email = merged.Username, merged.Email
+			report.Overwritten = append(report.Overwritten, e.Username)
+			continue
+		case strategy == MergeRename && byEmail == nil:
+			merged.Username = renameUsername(current, e.Username)
+		default:
+			report.Skipped = append(report.Skipped, e.Username)
+			continue
+		}
+
+		inserted, err := r.Insert(merged)
+		if err != nil {
+			return fmt.Errorf("%w: %v", err, e.Username)
+		}
+
+		current = append(current, inserted)
+		if merged.Username != e.Username {
+			report.Renamed = append(report.Renamed,
+				MergeRenamed{From: e.Username, To: merged.Username})
+		} else {
+			report.Added = append(report.Added, e.Username)
+		}
+	}
+
+	return nil
+}
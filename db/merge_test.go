@@ -0,0 +1,225 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMergeStrategy(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		strategy    MergeStrategy
+		err         error
+	}{
+		{description: "skip", input: "skip", strategy: MergeSkip},
+		{description: "overwrite in capitals", input: " OVERWRITE ", strategy: MergeOverwrite},
+		{description: "rename", input: "Rename", strategy: MergeRename},
+		{description: "unknown strategy", input: "replace", err: ErrInvalidMergeStrategy},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			strategy, err := ParseMergeStrategy(tc.input)
+			assertError(t, err, tc.err)
+			if strategy != tc.strategy {
+				t.Fatalf("got: %v, expected: %v", strategy, tc.strategy)
+			}
+		})
+	}
+}
+
+/*
+	Creates a site table in repo whose Entries collide with e1, e2 and e3 and
+	switches back to the DEFAULT_TABLE.
+*/
+
+func setupSiteTable(t *testing.T, repo AddressBookRepository) {
+	assertError(t, repo.NewTable("site"), nil)
+
+	site := []Entry{
+		// username of e3 and email of e1
+		{Name: "Site Three", Username: "username3", Email: "test1@test.com"},
+		// username of e1
+		{Name: "Site One", Username: "username1", Email: "site1@test.com"},
+		// email of e2
+		{Name: "Site Two", Username: "siteuser2", Email: "test2@test.com"},
+		{Name: "Test Four", Username: "username4", Email: "test4@test.com"},
+	}
+
+	for _, e := range site {
+		_, err := repo.Insert(e)
+		assertError(t, err, nil)
+	}
+
+	assertError(t, repo.SwitchTable(DEFAULT_TABLE), nil)
+}
+
+/*
+	failingRepository wraps an AddressBookRepository so that inserting the
+	Entry with the given username fails, including within a Transaction.
+*/
+
+type failingRepository struct {
+	AddressBookRepository
+	username string
+	err      error
+}
+
+func (f failingRepository) Insert(e Entry) (*Entry, error) {
+	if e.Username == f.username {
+		return nil, f.err
+	}
+
+	return f.AddressBookRepository.Insert(e)
+}
+
+func (f failingRepository) Transaction(fn func(tx AddressBookRepository) error) error {
+	return f.AddressBookRepository.Transaction(func(tx AddressBookRepository) error {
+		return fn(failingRepository{tx, f.username, f.err})
+	})
+}
+
+func TestMergeTable(t *testing.T) {
+	tt := []struct {
+		description string
+		strategy    MergeStrategy
+		report      *MergeReport
+		usernames   []string
+	}{
+		{
+			description: "skip",
+			strategy:    MergeSkip,
+			report: &MergeReport{
+				Added:   []string{"username4"},
+				Skipped: []string{"username3", "username1", "siteuser2"},
+			},
+			usernames: []string{"username1", "username2", "username3",
+				"username4"},
+		},
+		{
+			description: "overwrite",
+			strategy:    MergeOverwrite,
+			report: &MergeReport{
+				Added:       []string{"username4"},
+				Skipped:     []string{"username3"},
+				Overwritten: []string{"username1", "siteuser2"},
+			},
+			usernames: []string{"username1", "siteuser2", "username3",
+				"username4"},
+		},
+		{
+			description: "rename",
+			strategy:    MergeRename,
+			report: &MergeReport{
+				Added:   []string{"username4"},
+				Skipped: []string{"username3", "siteuser2"},
+				Renamed: []MergeRenamed{{From: "username1", To: "username1_2"}},
+			},
+			usernames: []string{"username1", "username2", "username3",
+				"username1_2", "username4"},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			sqliteRepo, teardown := SetupWithInserts(t)
+			defer teardown()
+
+			jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+			defer teardownJSON()
+
+			for _, repo := range []AddressBookRepository{sqliteRepo, jsonRepo} {
+				setupSiteTable(t, repo)
+
+				report, err := MergeTable(repo, "site", tc.strategy)
+				assertError(t, err, nil)
+				if !reflect.DeepEqual(report, tc.report) {
+					t.Fatalf("got: %+v, expected: %+v", report, tc.report)
+				}
+
+				if repo.CurrentTable() != DEFAULT_TABLE {
+					t.Fatalf("got: %v, expected: %v", repo.CurrentTable(),
+						DEFAULT_TABLE)
+				}
+
+				all, err := repo.All()
+				assertError(t, err, nil)
+
+				var usernames []string
+				for _, e := range all {
+					usernames = append(usernames, e.Username)
+				}
+
+				if !reflect.DeepEqual(usernames, tc.usernames) {
+					t.Fatalf("got: %v, expected: %v", usernames, tc.usernames)
+				}
+
+				// merged users are given slots of the current table
+				if all[len(all)-1].Slot != int64(len(all)) {
+					t.Fatalf("got: %v, expected: %v", all[len(all)-1].Slot,
+						len(all))
+				}
+			}
+		})
+	}
+
+	t.Run("overwritten users keep their slot", func(t *testing.T) {
+		repo, teardown := SetupWithInserts(t)
+		defer teardown()
+
+		setupSiteTable(t, repo)
+		_, err := MergeTable(repo, "site", MergeOverwrite)
+		assertError(t, err, nil)
+
+		e, err := repo.GetByUsername("siteuser2")
+		assertError(t, err, nil)
+		if e.Name != "Site Two" || e.Slot != e2.Slot || e.Number != e2.Number {
+			t.Fatalf("got: %v, expected: %v", e, "Site Two in slot 2")
+		}
+	})
+
+	t.Run("failed merges are rolled back", func(t *testing.T) {
+		sqliteRepo, teardown := SetupWithInserts(t)
+		defer teardown()
+
+		jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+		defer teardownJSON()
+
+		errInsert := errors.New("insert failed")
+		for _, repo := range []AddressBookRepository{sqliteRepo, jsonRepo} {
+			setupSiteTable(t, repo)
+
+			failing := failingRepository{repo, "username4", errInsert}
+			report, err := MergeTable(failing, "site", MergeOverwrite)
+			if !errors.Is(err, errInsert) {
+				t.Fatalf("got: %v, expected: %v", err, errInsert)
+			}
+			if report.Count() != 0 {
+				t.Fatalf("got: %+v, expected: %v", report, "an empty report")
+			}
+
+			e, err := repo.GetByUsername("username1")
+			assertError(t, err, nil)
+			if e.Name != e1.Name {
+				t.Fatalf("got: %v, expected: %v", e.Name, e1.Name)
+			}
+		}
+	})
+
+	t.Run("merge errors", func(t *testing.T) {
+		repo, teardown := SetupWithInserts(t)
+		defer teardown()
+
+		_, err := MergeTable(repo, DEFAULT_TABLE, MergeSkip)
+		assertError(t, err, ErrMergeSameTable)
+
+		_, err = MergeTable(repo, "missing", MergeSkip)
+		assertError(t, err, ErrTableDoesNotExist)
+	})
+}
@@ -0,0 +1,422 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/*
+	migration describes a change to the schema of the user tables.
+
+	version: schema version of the tables once the migration is applied
+	description: summary of the change
+	columns: definitions of the columns added to every table
+	statements: statements ran once, ie to create the application's tables
+	updates: statements ran on every table once its columns are added, the
+	table's name is given to them as %v
+	backfill: ran on every table after updates, for changes that cannot be
+	written as a statement
+*/
+
+type migration struct {
+	version     int
+	description string
+	columns     []string
+	statements  []string
+	updates     []string
+	backfill    func(db execer, tableName string) error
+}
+
+/*
+	Ordered list of the migrations applied on top of the createTable schema,
+	which is version 1. New migrations are appended with the next version.
+*/
+
+var migrations = []migration{
+	{
+		version:     2,
+		description: "smb scan destinations",
+		columns: []string{
+			"smb_host text NOT NULL DEFAULT ''",
+			"smb_path text NOT NULL DEFAULT ''",
+			"smb_login text NOT NULL DEFAULT ''",
+			"smb_password text NOT NULL DEFAULT ''",
+			"smb_port INTEGER NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		version:     3,
+		description: "ftp scan destinations",
+		columns: []string{
+			"ftp_host text NOT NULL DEFAULT ''",
+			"ftp_path text NOT NULL DEFAULT ''",
+			"ftp_login text NOT NULL DEFAULT ''",
+			"ftp_password text NOT NULL DEFAULT ''",
+			"ftp_port INTEGER NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		version:     4,
+		description: "fax and internet fax details",
+		columns: []string{
+			"fax_number text NOT NULL DEFAULT ''",
+			"fax_subaddress text NOT NULL DEFAULT ''",
+			"ifax_address text NOT NULL DEFAULT ''",
+			"fax_speed text NOT NULL DEFAULT ''",
+			"fax_ecm text NOT NULL DEFAULT ''",
+			"fax_encryption text NOT NULL DEFAULT ''",
+			"fax_encryption_key INTEGER NOT NULL DEFAULT 0",
+		},
+	},
+	{
+		version:     5,
+		description: "address book groups",
+		statements:  []string{createGroupsTable, createMembersTable},
+	},
+	{
+		version:     6,
+		description: "one touch key slots",
+		columns:     []string{"otk_slot INTEGER NOT NULL DEFAULT 0"},
+		statements:  []string{createReservedTable},
+		updates:     []string{assignSlots},
+	},
+	{
+		version:     7,
+		description: "address book numbers",
+		columns:     []string{"number INTEGER NOT NULL DEFAULT 0"},
+		updates:     []string{assignNumbers},
+	},
+	{
+		version:     8,
+		description: "smb, ftp and group one touch key slots",
+		columns: []string{
+			"smb_slot INTEGER NOT NULL DEFAULT 0",
+			"ftp_slot INTEGER NOT NULL DEFAULT 0",
+		},
+		statements: []string{addGroupSlot},
+		backfill:   backfillTableSlots,
+	},
+	{
+		version:     9,
+		description: "group numbers",
+		statements:  []string{addGroupNumber, assignGroupNumbers},
+	},
+}
+
+/*
+	Returns the schema version that the tables are at once every migration
+	is applied.
+*/
+
+func latestVersion() int {
+	if len(migrations) == 0 {
+		return 1
+	}
+
+	return migrations[len(migrations)-1].version
+}
+
+/*
+	execer is satisfied by both *sql.DB and *sql.Tx so that migrations can be
+	applied inside or outside of a transaction.
+*/
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+/*
+	Returns the names of the columns of a table.
+*/
+
+func tableColumns(db execer, tableName string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf(selectColumns, tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, ctype      string
+			dflt             sql.NullString
+		)
+
+		err = rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk)
+		if err != nil {
+			return nil, err
+		}
+
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
+/*
+	Applies a migration to a table. Columns that the table already has are
+	skipped and updates must only change rows they have not changed before so
+	a migration can be applied to a table more than once.
+*/
+
+func (m migration) apply(db execer, tableName string) error {
+	existing, err := tableColumns(db, tableName)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range m.columns {
+		name := strings.Fields(c)[0]
+		if existing[name] {
+			continue
+		}
+
+		_, err = db.Exec(fmt.Sprintf(addColumn, tableName, c))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, u := range m.updates {
+		_, err = db.Exec(fmt.Sprintf(u, tableName))
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.backfill != nil {
+		return m.backfill(db, tableName)
+	}
+
+	return nil
+}
+
+/*
+	Gives the SMB, FTP and group OneTouchKeys of a table the slots they were
+	exported with before their slots were stored, see backfillKeySlots. Only
+	keys without a slot are changed.
+*/
+
+func backfillTableSlots(db execer, tableName string) error {
+	rows, err := db.Query(fmt.Sprintf(selectKeyOwners, tableName))
+	if err != nil {
+		return err
+	}
+
+	var entries []*Entry
+	for rows.Next() {
+		e := new(Entry)
+		err = rows.Scan(&e.ID, &e.Slot, &e.SMBSlot, &e.FTPSlot,
+			&e.SMB.Host, &e.SMB.Path, &e.SMB.Login, &e.SMB.Password, &e.SMB.Port,
+			&e.FTP.Host, &e.FTP.Path, &e.FTP.Login, &e.FTP.Password, &e.FTP.Port)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		entries = append(entries, e)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query(selectGroupSlots, tableName)
+	if err != nil {
+		return err
+	}
+
+	var groups []*Group
+	for rows.Next() {
+		g := new(Group)
+		err = rows.Scan(&g.ID, &g.Slot)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		groups = append(groups, g)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	reserved, err := queryColumn(db, selectReserve, tableName)
+	if err != nil {
+		return err
+	}
+
+	before := make(map[*Entry][2]int64)
+	for _, e := range entries {
+		before[e] = [2]int64{e.SMBSlot, e.FTPSlot}
+	}
+
+	unassigned := make(map[*Group]bool)
+	for _, g := range groups {
+		unassigned[g] = g.Slot == 0
+	}
+
+	backfillKeySlots(entries, groups, reserved)
+
+	for _, e := range entries {
+		if before[e] == [2]int64{e.SMBSlot, e.FTPSlot} {
+			continue
+		}
+
+		_, err = db.Exec(fmt.Sprintf(updateOwnerSlots, tableName),
+			e.SMBSlot, e.FTPSlot, e.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, g := range groups {
+		if !unassigned[g] {
+			continue
+		}
+
+		_, err = db.Exec(updateGroupSlot, g.Slot, g.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Creates a table at the latest schema version by creating it with the
+	createTable schema and applying every migration to it.
+*/
+
+func createUserTable(db execer, tableName string) error {
+	_, err := db.Exec(fmt.Sprintf(createTable, tableName))
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		err = m.apply(db, tableName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the schema version recorded in the database, 1 if the database
+	was created before versions were recorded.
+*/
+
+func (r *SQLiteRepository) SchemaVersion() (int, error) {
+	var name string
+	err := r.db.QueryRow(selectTable, versionTable).Scan(&name)
+	if err == sql.ErrNoRows {
+		return 1, nil
+	}
+
+	if err != nil {
+		return 0, storageError("query schema version", err)
+	}
+
+	var version int
+	err = r.db.QueryRow(selectVersion).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 1, nil
+	}
+
+	if err != nil {
+		return 0, storageError("query schema version", err)
+	}
+
+	return version, nil
+}
+
+/*
+	Applies the migrations newer than the recorded schema version to every
+	user table and records the latest version. Every migration is applied in
+	its own transaction so a failure leaves the database at the last version
+	that was applied.
+
+	Returns a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) migrate() error {
+	_, err := r.db.Exec(createVersionTable)
+	if err != nil {
+		return storageError("create schema version table", err)
+	}
+
+	version, err := r.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	tables, err := r.ListTables()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+
+		err = r.applyMigration(m, tables)
+		if err != nil {
+			op := fmt.Sprintf("migrate to version %d (%v)", m.version,
+				m.description)
+			return storageError(op, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Applies a migration to the given tables and records its version in a
+	single transaction.
+*/
+
+func (r *SQLiteRepository) applyMigration(m migration, tables []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.statements {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, t := range tables {
+		err = m.apply(tx, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(deleteVersion)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(insertVersion, m.version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
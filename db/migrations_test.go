@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*
+	Function to create a database from a fixture in testdata and return a
+	repository for it along with the clean up function. The repository is not
+	initialized.
+*/
+
+func setupFixture(t *testing.T, fixture string) (*SQLiteRepository, func()) {
+	t.Helper()
+
+	schema, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	sqlite, err := sql.Open("sqlite3", f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sqlite.Exec(string(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewSQLiteRepository(sqlite)
+	assertError(t, err, nil)
+
+	teardown := func() {
+		sqlite.Close()
+		os.Remove(f.Name())
+	}
+
+	return repo, teardown
+}
+
+func TestMigrateFixtures(t *testing.T) {
+	smb := SMBDestination{Host: "fileserver", Path: "scans"}
+
+	tt := []struct {
+		description string
+		fixture     string
+		version     int
+		tables      []string
+		expected    map[string][]*Entry
+		slots       [3]int64
+	}{
+		{
+			description: "database without a schema version",
+			fixture:     "schema_v1.sql",
+			version:     1,
+			tables:      []string{DEFAULT_TABLE, "sales"},
+			expected: map[string][]*Entry{
+				DEFAULT_TABLE: {e1, e2},
+				"sales": {{ID: 1, Name: e3.Name, Username: e3.Username,
+					Email: e3.Email, Slot: 1, Number: 1}},
+			},
+			slots: [3]int64{3, 4, 5},
+		},
+		{
+			description: "database with destination columns but no schema version",
+			fixture:     "schema_v4_unversioned.sql",
+			version:     1,
+			tables:      []string{DEFAULT_TABLE},
+			expected: map[string][]*Entry{
+				DEFAULT_TABLE: {{ID: 1, Name: e1.Name, Username: e1.Username,
+					Email: e1.Email, SMB: smb,
+					Fax: FaxDestination{Number: "5550199"}, Slot: 1, SMBSlot: 2,
+					Number: 1}},
+			},
+			slots: [3]int64{3, 4, 5},
+		},
+		{
+			description: "database at schema version 2",
+			fixture:     "schema_v2.sql",
+			version:     2,
+			tables:      []string{DEFAULT_TABLE},
+			expected: map[string][]*Entry{
+				DEFAULT_TABLE: {{ID: 1, Name: e1.Name, Username: e1.Username,
+					Email: e1.Email, SMB: smb, Slot: 1, SMBSlot: 2, Number: 1}},
+			},
+			slots: [3]int64{3, 4, 5},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			repo, teardown := setupFixture(t, tc.fixture)
+			defer teardown()
+
+			version, err := repo.SchemaVersion()
+			assertError(t, err, nil)
+			if version != tc.version {
+				t.Fatalf("got: %v, expected: %v", version, tc.version)
+			}
+
+			assertError(t, repo.Initialize(), nil)
+
+			version, err = repo.SchemaVersion()
+			assertError(t, err, nil)
+			if version != latestVersion() {
+				t.Fatalf("got: %v, expected: %v", version, latestVersion())
+			}
+
+			tables, err := repo.ListTables()
+			assertError(t, err, nil)
+			if !reflect.DeepEqual(tables, tc.tables) {
+				t.Fatalf("got: %v, expected: %v", tables, tc.tables)
+			}
+
+			for _, table := range tc.tables {
+				assertError(t, repo.SwitchTable(table), nil)
+
+				all, err := repo.All()
+				assertError(t, err, nil)
+				if !reflect.DeepEqual(all, tc.expected[table]) {
+					t.Fatalf("got: %v, expected: %v", all, tc.expected[table])
+				}
+			}
+
+			e := &Entry{Name: "Jane Doe", Username: "jdoe",
+				Email: "jdoe@email.com", SMB: smb,
+				FTP: FTPDestination{Host: "ftpserver", Path: "/scans"},
+				Fax: FaxDestination{Number: "5550100"}}
+
+			assertError(t, repo.SwitchTable(DEFAULT_TABLE), nil)
+			_, err = repo.Insert(*e)
+			assertError(t, err, nil)
+
+			got, err := repo.GetByUsername(e.Username)
+			assertError(t, err, nil)
+			e.ID, e.Number = got.ID, got.ID
+			e.Slot, e.SMBSlot, e.FTPSlot = tc.slots[0], tc.slots[1], tc.slots[2]
+			assertEntry(t, got, e)
+
+			// initializing an up to date database changes nothing
+			assertError(t, repo.Initialize(), nil)
+			version, err = repo.SchemaVersion()
+			assertError(t, err, nil)
+			if version != latestVersion() {
+				t.Fatalf("got: %v, expected: %v", version, latestVersion())
+			}
+		})
+	}
+}
+
+func TestNewDatabaseVersion(t *testing.T) {
+	repo, teardown := setup(t)
+	defer teardown()
+
+	version, err := repo.SchemaVersion()
+	assertError(t, err, nil)
+	if version != latestVersion() {
+		t.Fatalf("got: %v, expected: %v", version, latestVersion())
+	}
+
+	assertError(t, repo.NewTable(versionTable), ErrInvalidTableName)
+	assertError(t, repo.NewTable("new_table"), nil)
+
+	columns, err := tableColumns(repo.db, "new_table")
+	assertError(t, err, nil)
+
+	for _, m := range migrations {
+		for _, c := range m.columns {
+			name := strings.Fields(c)[0]
+			if !columns[name] {
+				t.Fatalf("got: %v, expected: %v", columns, name)
+			}
+		}
+	}
+}
+
+func TestMigrateKeySlots(t *testing.T) {
+	repo, teardown := setupFixture(t, "schema_v7.sql")
+	defer teardown()
+
+	assertError(t, repo.Initialize(), nil)
+
+	all, err := repo.All()
+	assertError(t, err, nil)
+
+	// the keys keep the slots they were exported with, after the highest
+	// slot in the order of the users' slots
+	got := make(map[string][2]int64)
+	for _, e := range all {
+		got[e.Username] = [2]int64{e.SMBSlot, e.FTPSlot}
+	}
+
+	expected := map[string][2]int64{"username1": {7, 0}, "username2": {6, 8},
+		"username3": {NoSlot, 0}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+
+	groups, err := repo.Groups()
+	assertError(t, err, nil)
+
+	expectedGroups := []*Group{
+		{ID: 1, Name: "Sales", Slot: 9, Number: 1},
+		{ID: 2, Name: "Support", Slot: 10, Number: 2},
+	}
+	if !reflect.DeepEqual(groups, expectedGroups) {
+		t.Fatalf("got: %v, expected: %v", groups, expectedGroups)
+	}
+}
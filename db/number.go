@@ -0,0 +1,59 @@
+package db
+
+/*
+	Highest address book number of an Entry, the number of contacts that a
+	Kyocera address book holds.
+*/
+
+const MaxNumber int64 = 2000
+
+/*
+	Returns the address book number of a new Entry given the numbers of the
+	Entries in its table. New Entries are numbered after the highest number
+	so that the numbers of deleted Entries, which the scanner may still refer
+	to, are not reused. Gaps are only filled once MaxNumber has been given.
+
+	Returns ErrAddressBookFull if every number is in use
+*/
+
+func nextNumber(numbers []int64) (int64, error) {
+	used := make(map[int64]bool)
+	highest := int64(0)
+	for _, n := range numbers {
+		used[n] = true
+		if n > highest {
+			highest = n
+		}
+	}
+
+	if highest < MaxNumber {
+		return highest + 1, nil
+	}
+
+	for n := int64(1); n <= MaxNumber; n++ {
+		if !used[n] {
+			return n, nil
+		}
+	}
+
+	return 0, ErrAddressBookFull
+}
+
+/*
+	Returns the address book numbers that a table is renumbered to by
+	CompactNumbers, keyed by Entry ID. Entries keep their order and are
+	numbered from 1 without gaps.
+
+	entries: the Entries of the table ordered by number
+*/
+
+func compactNumbers(entries []*Entry) map[int64]int64 {
+	numbers := make(map[int64]int64)
+	for i, e := range entries {
+		if e.Number != int64(i+1) {
+			numbers[e.ID] = int64(i + 1)
+		}
+	}
+
+	return numbers
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNextNumber(t *testing.T) {
+	full := make([]int64, MaxNumber)
+	for i := range full {
+		full[i] = int64(i + 1)
+	}
+
+	tt := []struct {
+		description string
+		numbers     []int64
+		expected    int64
+		err         error
+	}{
+		{
+			description: "empty table",
+			expected:    1,
+		},
+		{
+			description: "gaps are not reused",
+			numbers:     []int64{1, 4, 2},
+			expected:    5,
+		},
+		{
+			description: "gaps are filled after the highest number",
+			numbers:     []int64{1, 2, MaxNumber},
+			expected:    3,
+		},
+		{
+			description: "full table",
+			numbers:     full,
+			err:         ErrAddressBookFull,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := nextNumber(tc.numbers)
+			assertError(t, err, tc.err)
+			if got != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCompactNumbers(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	repos := []struct {
+		description string
+		repo        AddressBookRepository
+	}{
+		{description: "sqlite", repo: sqliteRepo},
+		{description: "json", repo: jsonRepo},
+	}
+
+	for _, rc := range repos {
+		repo := rc.repo
+		t.Run(rc.description, func(t *testing.T) {
+			assertError(t, repo.Delete(e2.Username), nil)
+
+			e, err := repo.Insert(Entry{Name: "Test Four", Username: "username4",
+				Email: "test4@test.com"})
+			assertError(t, err, nil)
+			if e.Number != 4 {
+				t.Fatalf("got: %v, expected: %v", e.Number, 4)
+			}
+
+			_, err = repo.Insert(Entry{Name: "Test Five", Username: "username5",
+				Email: "test5@test.com", Number: 4})
+			assertError(t, err, ErrNumberTaken)
+
+			n, err := repo.CompactNumbers()
+			assertError(t, err, nil)
+			if n != 2 {
+				t.Fatalf("got: %v, expected: %v", n, 2)
+			}
+
+			all, err := repo.All()
+			assertError(t, err, nil)
+
+			numbers := make(map[string]int64)
+			for _, e := range all {
+				numbers[e.Username] = e.Number
+			}
+
+			expected := map[string]int64{e1.Username: 1, e3.Username: 2,
+				"username4": 3}
+			if !reflect.DeepEqual(numbers, expected) {
+				t.Fatalf("got: %v, expected: %v", numbers, expected)
+			}
+
+			n, err = repo.CompactNumbers()
+			assertError(t, err, nil)
+			if n != 0 {
+				t.Fatalf("got: %v, expected: %v", n, 0)
+			}
+		})
+	}
+}
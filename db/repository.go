@@ -0,0 +1,73 @@
+package db
+
+/*
+	AddressBookRepository is the set of operations that a storage backend
+	provides for the address book. Every Entry operation is ran against the
+	backend's current table.
+
+	CurrentTable: name of the table that Entry operations are ran against
+	Initialize: prepares the backend and creates the DEFAULT_TABLE
+	Insert: adds an Entry and returns it with its ID
+	All: returns every Entry
+	GetByUsername: returns the Entry with the given username
+	Update: replaces the Entry with the given username
+	Delete: removes the Entry with the given username
+	NewTable: creates a table and makes it the current table
+	SwitchTable: makes an existing table the current table
+	ClearTable: removes every Entry
+	TableExists: reports whether a table exists
+	DeleteTable: removes a table, the DEFAULT_TABLE cannot be removed
+	CopyTable: creates a table with the content of another and makes it the
+	current table
+	RenameTable: renames a table, the DEFAULT_TABLE cannot be renamed
+	ListTables: returns the name of every table
+	NewGroup: creates a Group
+	InsertGroup: creates a Group keeping its slot and number, ie when it is
+	copied
+	AddToGroup: adds the Entry with the given username to a Group
+	Groups: returns every Group
+	SetSlot: moves the OneTouchKey of the Entry with the given username
+	SwapSlots: swaps the OneTouchKeys of two Entries
+	ReserveSlot: keeps a slot free of OneTouchKeys
+	ReleaseSlot: frees a reserved slot
+	ReservedSlots: returns every reserved slot
+	CompactNumbers: renumbers the Entries from 1 without gaps
+	Transaction: runs operations that are all kept or all undone
+*/
+
+type AddressBookRepository interface {
+	CurrentTable() string
+	Initialize() error
+
+	Insert(e Entry) (*Entry, error)
+	All() ([]*Entry, error)
+	GetByUsername(username string) (*Entry, error)
+	Update(username string, u *Entry) (*Entry, error)
+	Delete(username string) error
+
+	NewTable(tableName string) error
+	SwitchTable(tableName string) error
+	ClearTable() error
+	TableExists(tableName string) (bool, error)
+	DeleteTable(tableName string) error
+	CopyTable(tableName, newName string) error
+	RenameTable(tableName, newName string) error
+	ListTables() ([]string, error)
+
+	NewGroup(name string) (*Group, error)
+	InsertGroup(g Group) (*Group, error)
+	AddToGroup(name, username string) error
+	Groups() ([]*Group, error)
+
+	SetSlot(username string, slot int64) error
+	SwapSlots(username, other string) error
+	ReserveSlot(slot int64) error
+	ReleaseSlot(slot int64) error
+	ReservedSlots() ([]int64, error)
+
+	CompactNumbers() (int, error)
+
+	Transaction(fn func(tx AddressBookRepository) error) error
+}
+
+var _ AddressBookRepository = (*SQLiteRepository)(nil)
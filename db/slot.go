@@ -0,0 +1,300 @@
+package db
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Limits of the OneTouchKey slot of an Entry, the position of its key on the
+	scanner's panel.
+
+	NoSlot: the Entry is exported without a OneTouchKey
+	MaxSlot: the highest slot a Kyocera panel has
+
+	A slot of 0 given to Insert assigns the lowest slot that is not taken by
+	another OneTouchKey or reserved. The SMB and FTP destinations of an Entry
+	and every Group have OneTouchKeys of their own that take slots the same
+	way.
+*/
+
+const (
+	NoSlot  int64 = -1
+	MaxSlot int64 = 1000
+)
+
+/*
+	SlotCollision describes a slot that is given to more than one Entry, or to
+	an Entry while being reserved.
+
+	Slot: the slot that collides
+	Usernames: usernames of the Entries with the slot, followed by (SMB) or
+	(FTP) when it is the slot of one of their destinations
+	Groups: names of the Groups with the slot
+	Reserved: whether the slot is also reserved
+*/
+
+type SlotCollision struct {
+	Slot      int64    `json:"slot"`
+	Usernames []string `json:"usernames,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+	Reserved  bool     `json:"reserved,omitempty"`
+}
+
+/*
+	Function that checks a slot that an Entry is moved to or that is reserved.
+*/
+
+func validateSlot(slot int64) error {
+	if slot == NoSlot || (slot > 0 && slot <= MaxSlot) {
+		return nil
+	}
+
+	return ErrInvalidSlot
+}
+
+/*
+	Parses a slot given by the user, 'none' is parsed as NoSlot.
+*/
+
+func ParseSlot(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "none") {
+		return NoSlot, nil
+	}
+
+	slot, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || slot == NoSlot {
+		return 0, ErrInvalidSlot
+	}
+
+	err = validateSlot(slot)
+	if err != nil {
+		return 0, err
+	}
+
+	return slot, nil
+}
+
+/*
+	Checks the slots of the OneTouchKeys of an Entry, which cannot share a
+	slot with each other.
+*/
+
+func validateKeySlots(e *Entry) error {
+	seen := make(map[int64]bool)
+	for _, slot := range []int64{e.Slot, e.SMBSlot, e.FTPSlot} {
+		if slot == 0 {
+			continue
+		}
+
+		err := validateSlot(slot)
+		if err != nil {
+			return err
+		}
+
+		if slot > 0 && seen[slot] {
+			return ErrSlotCollision
+		}
+
+		seen[slot] = true
+	}
+
+	return nil
+}
+
+/*
+	Returns the slots of the OneTouchKeys of an Entry, the email key first
+	followed by the keys of the destinations it has.
+*/
+
+func keySlots(e *Entry) []*int64 {
+	slots := []*int64{&e.Slot}
+	if e.SMB.IsSet() {
+		slots = append(slots, &e.SMBSlot)
+	}
+
+	if e.FTP.IsSet() {
+		slots = append(slots, &e.FTPSlot)
+	}
+
+	return slots
+}
+
+/*
+	Gives the OneTouchKeys of an Entry being inserted or updated their slots.
+	The slots of destinations the Entry does not have are cleared, slots that
+	are given are checked with check, which can be nil for slots the Entry
+	already had, and slots of 0 are given the lowest slot that is not in used
+	or given to another of the Entry's keys.
+*/
+
+func assignKeySlots(e *Entry, used map[int64]bool, check func(slot int64) error) error {
+	if !e.SMB.IsSet() {
+		e.SMBSlot = 0
+	}
+
+	if !e.FTP.IsSet() {
+		e.FTPSlot = 0
+	}
+
+	slots := keySlots(e)
+	for _, slot := range slots {
+		if *slot <= 0 {
+			continue
+		}
+
+		if check != nil {
+			err := check(*slot)
+			if err != nil {
+				return err
+			}
+		}
+
+		used[*slot] = true
+	}
+
+	for _, slot := range slots {
+		if *slot == 0 {
+			*slot = nextSlot(used)
+			used[*slot] = true
+		}
+	}
+
+	return nil
+}
+
+/*
+	Gives the SMB and FTP OneTouchKeys of the entries, and the OneTouchKeys of
+	the groups, that do not have a slot the slots the exporter gave them
+	before their slots were stored: after the highest slot that is used or
+	reserved, the SMB keys and then the FTP keys in the order of their
+	entries' slots, then the group keys in the order of groups. Entries
+	without a OneTouchKey were exported without SMB and FTP keys and are
+	given NoSlot, as are keys that would be past MaxSlot.
+*/
+
+func backfillKeySlots(entries []*Entry, groups []*Group, reserved []int64) {
+	last := int64(0)
+	for _, e := range entries {
+		for _, slot := range []int64{e.Slot, e.SMBSlot, e.FTPSlot} {
+			if slot > last {
+				last = slot
+			}
+		}
+	}
+
+	for _, g := range groups {
+		if g.Slot > last {
+			last = g.Slot
+		}
+	}
+
+	for _, slot := range reserved {
+		if slot > last {
+			last = slot
+		}
+	}
+
+	next := func() int64 {
+		if last >= MaxSlot {
+			return NoSlot
+		}
+
+		last++
+		return last
+	}
+
+	ordered := append([]*Entry(nil), entries...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Slot < ordered[j].Slot
+	})
+
+	for _, e := range ordered {
+		if e.SMB.IsSet() && e.SMBSlot == 0 {
+			e.SMBSlot = NoSlot
+			if e.Slot != NoSlot {
+				e.SMBSlot = next()
+			}
+		}
+	}
+
+	for _, e := range ordered {
+		if e.FTP.IsSet() && e.FTPSlot == 0 {
+			e.FTPSlot = NoSlot
+			if e.Slot != NoSlot {
+				e.FTPSlot = next()
+			}
+		}
+	}
+
+	for _, g := range groups {
+		if g.Slot == 0 {
+			g.Slot = next()
+		}
+	}
+}
+
+/*
+	Returns the lowest slot that is not used, or NoSlot if every slot up to
+	MaxSlot is.
+*/
+
+func nextSlot(used map[int64]bool) int64 {
+	for slot := int64(1); slot <= MaxSlot; slot++ {
+		if !used[slot] {
+			return slot
+		}
+	}
+
+	return NoSlot
+}
+
+/*
+	Returns the slots that are given to more than one OneTouchKey of the
+	entries and groups, or that are given to a key and reserved, in slot
+	order.
+*/
+
+func SlotCollisions(entries []*Entry, groups []*Group, reserved []int64) []SlotCollision {
+	isReserved := make(map[int64]bool)
+	for _, slot := range reserved {
+		isReserved[slot] = true
+	}
+
+	usernames := make(map[int64][]string)
+	add := func(slot int64, name string) {
+		if slot > 0 {
+			usernames[slot] = append(usernames[slot], name)
+		}
+	}
+
+	for _, e := range entries {
+		add(e.Slot, e.Username)
+		add(e.SMBSlot, e.Username+" (SMB)")
+		add(e.FTPSlot, e.Username+" (FTP)")
+	}
+
+	names := make(map[int64][]string)
+	for _, g := range groups {
+		if g.Slot > 0 {
+			names[g.Slot] = append(names[g.Slot], g.Name)
+		}
+	}
+
+	var collisions []SlotCollision
+	for slot := int64(1); slot <= MaxSlot; slot++ {
+		n := len(usernames[slot]) + len(names[slot])
+		if n > 1 || (n == 1 && isReserved[slot]) {
+			collisions = append(collisions, SlotCollision{
+				Slot:      slot,
+				Usernames: usernames[slot],
+				Groups:    names[slot],
+				Reserved:  isReserved[slot],
+			})
+		}
+	}
+
+	return collisions
+}
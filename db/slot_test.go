@@ -0,0 +1,295 @@
+package db
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestParseSlot(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		slot        int64
+		err         error
+	}{
+		{description: "valid slot", input: " 12 ", slot: 12},
+		{description: "no slot", input: "None", slot: NoSlot},
+		{description: "highest slot", input: "1000", slot: MaxSlot},
+		{description: "zero slot", input: "0", err: ErrInvalidSlot},
+		{description: "negative slot", input: "-1", err: ErrInvalidSlot},
+		{description: "slot past the panel", input: "1001", err: ErrInvalidSlot},
+		{description: "slot that is not a number", input: "first", err: ErrInvalidSlot},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			slot, err := ParseSlot(tc.input)
+			assertError(t, err, tc.err)
+			if slot != tc.slot {
+				t.Fatalf("got: %v, expected: %v", slot, tc.slot)
+			}
+		})
+	}
+}
+
+func TestSlotCollisions(t *testing.T) {
+	entries := []*Entry{
+		{Username: "a", Slot: 2},
+		{Username: "b", Slot: 1},
+		{Username: "c", Slot: 2},
+		{Username: "d", Slot: 5},
+		{Username: "e", Slot: NoSlot},
+		{Username: "f", Slot: NoSlot, SMBSlot: 7},
+		{Username: "g", Slot: 8, FTPSlot: 6},
+	}
+	groups := []*Group{{Name: "Sales", Slot: 7}, {Name: "Support", Slot: 6}}
+
+	got := SlotCollisions(entries, groups, []int64{3, 5})
+	expected := []SlotCollision{
+		{Slot: 2, Usernames: []string{"a", "c"}},
+		{Slot: 5, Usernames: []string{"d"}, Reserved: true},
+		{Slot: 6, Usernames: []string{"g (FTP)"}, Groups: []string{"Support"}},
+		{Slot: 7, Usernames: []string{"f (SMB)"}, Groups: []string{"Sales"}},
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+}
+
+func TestSlots(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	repos := []struct {
+		description string
+		repo        AddressBookRepository
+	}{
+		{description: "sqlite", repo: sqliteRepo},
+		{description: "json", repo: jsonRepo},
+	}
+
+	for _, rc := range repos {
+		repo := rc.repo
+		t.Run(rc.description, func(t *testing.T) {
+			assertError(t, repo.ReserveSlot(4), nil)
+			assertError(t, repo.ReserveSlot(4), ErrSlotReserved)
+			assertError(t, repo.ReserveSlot(1), ErrSlotTaken)
+			assertError(t, repo.ReserveSlot(NoSlot), ErrInvalidSlot)
+
+			// inserted users skip the reserved slot
+			e, err := repo.Insert(Entry{Name: "Test Four", Username: "username4",
+				Email: "test4@test.com"})
+			assertError(t, err, nil)
+			if e.Slot != 5 {
+				t.Fatalf("got: %v, expected: %v", e.Slot, 5)
+			}
+
+			_, err = repo.Insert(Entry{Name: "Test Five", Username: "username5",
+				Email: "test5@test.com", Slot: 5})
+			assertError(t, err, ErrSlotTaken)
+
+			assertError(t, repo.SetSlot(e1.Username, 3), ErrSlotTaken)
+			assertError(t, repo.SetSlot(e1.Username, 4), ErrSlotReserved)
+			assertError(t, repo.SetSlot(e1.Username, 0), ErrInvalidSlot)
+			assertError(t, repo.SetSlot("missing", 10), ErrNotFound)
+			assertError(t, repo.SetSlot(e1.Username, 10), nil)
+			assertError(t, repo.SetSlot(e1.Username, 10), nil)
+			assertError(t, repo.SetSlot(e2.Username, NoSlot), nil)
+			assertError(t, repo.SwapSlots(e3.Username, "username4"), nil)
+			assertError(t, repo.SwapSlots(e3.Username, "missing"), ErrNotFound)
+
+			// updating a user keeps their slot
+			_, err = repo.Update(e3.Username, &Entry{Name: e3.Name,
+				Username: e3.Username, Email: e3.Email})
+			assertError(t, err, nil)
+
+			all, err := repo.All()
+			assertError(t, err, nil)
+
+			slots := make(map[string]int64)
+			for _, e := range all {
+				slots[e.Username] = e.Slot
+			}
+
+			expected := map[string]int64{e1.Username: 10, e2.Username: NoSlot,
+				e3.Username: 5, "username4": 3}
+			if !reflect.DeepEqual(slots, expected) {
+				t.Fatalf("got: %v, expected: %v", slots, expected)
+			}
+
+			assertError(t, repo.ReleaseSlot(4), nil)
+			assertError(t, repo.ReleaseSlot(4), ErrSlotNotReserved)
+			assertError(t, repo.ReserveSlot(7), nil)
+			assertError(t, repo.ReserveSlot(6), nil)
+
+			reserved, err := repo.ReservedSlots()
+			assertError(t, err, nil)
+			if !reflect.DeepEqual(reserved, []int64{6, 7}) {
+				t.Fatalf("got: %v, expected: %v", reserved, []int64{6, 7})
+			}
+
+			// reserved slots belong to their table
+			assertError(t, repo.NewTable("new_table"), nil)
+			assertError(t, repo.ReserveSlot(1), nil)
+			assertError(t, repo.DeleteTable("new_table"), nil)
+			assertError(t, repo.NewTable("new_table"), nil)
+
+			reserved, err = repo.ReservedSlots()
+			assertError(t, err, nil)
+			if len(reserved) != 0 {
+				t.Fatalf("got: %v, expected: %v", reserved, nil)
+			}
+		})
+	}
+}
+
+func TestJSONAssignsSlots(t *testing.T) {
+	repo, path, teardown := setupJSON(t)
+	defer teardown()
+
+	// a file written before slots were stored
+	data := `{"tables": [{"name": "default_table", "next_id": 3, "entries": [
+		{"id": 1, "name": "Test One", "username": "username1", "email": "test1@test.com"},
+		{"id": 2, "name": "Test Two", "username": "username2", "email": "test2@test.com"}
+	]}]}`
+
+	err := ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertError(t, repo.Initialize(), nil)
+
+	all, err := repo.All()
+	assertError(t, err, nil)
+
+	expected := []*Entry{e1, e2}
+	if !reflect.DeepEqual(all, expected) {
+		t.Fatalf("got: %v, expected: %v", all, expected)
+	}
+}
+
+func TestKeySlots(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	repos := []struct {
+		description string
+		repo        AddressBookRepository
+	}{
+		{description: "sqlite", repo: sqliteRepo},
+		{description: "json", repo: jsonRepo},
+	}
+
+	smb := SMBDestination{Host: "fileserver", Path: "scans"}
+	ftp := FTPDestination{Host: "ftpserver", Path: "/scans"}
+
+	for _, rc := range repos {
+		repo := rc.repo
+		t.Run(rc.description, func(t *testing.T) {
+			e, err := repo.Insert(Entry{Name: "Test Four", Username: "username4",
+				Email: "test4@test.com", SMB: smb})
+			assertError(t, err, nil)
+			if e.Slot != 4 || e.SMBSlot != 5 || e.FTPSlot != 0 {
+				t.Fatalf("got: %v, expected: %v", e, "slots 4, 5 and 0")
+			}
+
+			g, err := repo.NewGroup("Sales")
+			assertError(t, err, nil)
+			if g.Slot != 6 {
+				t.Fatalf("got: %v, expected: %v", g.Slot, 6)
+			}
+
+			// the keys of destinations and groups cannot be moved onto
+			assertError(t, repo.SetSlot(e1.Username, 5), ErrSlotTaken)
+			assertError(t, repo.SetSlot(e.Username, 5), ErrSlotTaken)
+			assertError(t, repo.SetSlot(e1.Username, 6), ErrSlotTaken)
+			assertError(t, repo.ReserveSlot(5), ErrSlotTaken)
+			assertError(t, repo.ReserveSlot(6), ErrSlotTaken)
+
+			_, err = repo.Insert(Entry{Name: "Test Five", Username: "username5",
+				Email: "test5@test.com", SMB: smb, SMBSlot: 6})
+			assertError(t, err, ErrSlotTaken)
+
+			_, err = repo.Insert(Entry{Name: "Test Five", Username: "username5",
+				Email: "test5@test.com", SMB: smb, Slot: 8, SMBSlot: 8})
+			assertError(t, err, ErrSlotCollision)
+
+			_, err = repo.InsertGroup(Group{Name: "Support", Slot: 4})
+			assertError(t, err, ErrSlotTaken)
+
+			// adding a destination gives it a slot and keeps the others
+			u := *e
+			u.FTP = ftp
+			e, err = repo.Update(u.Username, &u)
+			assertError(t, err, nil)
+			if e.Slot != 4 || e.SMBSlot != 5 || e.FTPSlot != 7 {
+				t.Fatalf("got: %v, expected: %v", e, "slots 4, 5 and 7")
+			}
+
+			// removing a destination frees its slot
+			u = *e
+			u.SMB = SMBDestination{}
+			_, err = repo.Update(u.Username, &u)
+			assertError(t, err, nil)
+
+			e, err = repo.GetByUsername(u.Username)
+			assertError(t, err, nil)
+			if e.Slot != 4 || e.SMBSlot != 0 || e.FTPSlot != 7 {
+				t.Fatalf("got: %v, expected: %v", e, "slots 4, 0 and 7")
+			}
+
+			e, err = repo.Insert(Entry{Name: "Test Five", Username: "username5",
+				Email: "test5@test.com"})
+			assertError(t, err, nil)
+			if e.Slot != 5 {
+				t.Fatalf("got: %v, expected: %v", e.Slot, 5)
+			}
+		})
+	}
+}
+
+func TestJSONBackfillSkipsTakenSlots(t *testing.T) {
+	repo, path, teardown := setupJSON(t)
+	defer teardown()
+
+	// a file with a slot given to the second entry and a group, neither of
+	// whose keys were stored
+	data := `{"tables": [{"name": "default_table", "next_id": 3, "entries": [
+		{"id": 1, "name": "Test One", "username": "username1", "email": "test1@test.com",
+			"smb": {"host": "fileserver", "path": "scans"}, "number": 1},
+		{"id": 2, "name": "Test Two", "username": "username2", "email": "test2@test.com",
+			"slot": 1, "number": 2}
+	], "next_group_id": 1, "groups": [{"id": 1, "name": "Sales", "members": [2]}],
+	"reserved_slots": [3]}]}`
+
+	err := ioutil.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertError(t, repo.Initialize(), nil)
+
+	e, err := repo.GetByUsername("username1")
+	assertError(t, err, nil)
+	if e.Slot != 2 || e.SMBSlot != 4 {
+		t.Fatalf("got: %v, expected: %v", e, "slots 2 and 4")
+	}
+
+	groups, err := repo.Groups()
+	assertError(t, err, nil)
+	if groups[0].Slot != 5 || groups[0].Number != 1 {
+		t.Fatalf("got: %v, expected: %v", groups[0], "slot 5 and number 1")
+	}
+}
@@ -0,0 +1,46 @@
+//go:build cgo
+// +build cgo
+
+package db
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+/*
+	Reports whether the SQLite driver was built in, it requires CGO.
+*/
+
+const SQLiteAvailable = true
+
+/*
+	Reports whether err was returned because the database is busy or locked
+	by another connection.
+*/
+
+func isLocked(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy ||
+			sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	return false
+}
+
+/*
+	Reports whether err was caused by a unique or primary key constraint of a
+	table.
+*/
+
+func isDuplicate(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) ||
+			errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintPrimaryKey)
+	}
+
+	return false
+}
@@ -0,0 +1,28 @@
+//go:build !cgo
+// +build !cgo
+
+package db
+
+import (
+	// registers a stub driver that reports that the binary was built
+	// without CGO when a SQLite database is opened
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/*
+	Reports whether the SQLite driver was built in, it requires CGO.
+*/
+
+const SQLiteAvailable = false
+
+/*
+	Without CGO no SQLite database can be opened so no error comes from it.
+*/
+
+func isLocked(err error) bool {
+	return false
+}
+
+func isDuplicate(err error) bool {
+	return false
+}
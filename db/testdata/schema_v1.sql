@@ -0,0 +1,19 @@
+-- Database created before schema versions were recorded
+CREATE TABLE IF NOT EXISTS default_table (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	username text UNIQUE NOT NULL, 
+	email text UNIQUE NOT NULL 
+	);
+CREATE TABLE IF NOT EXISTS sales (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	username text UNIQUE NOT NULL, 
+	email text UNIQUE NOT NULL 
+	);
+INSERT INTO default_table(name, username, email) 
+	values('Test One', 'username1', 'test1@test.com');
+INSERT INTO default_table(name, username, email) 
+	values('Test Two', 'username2', 'test2@test.com');
+INSERT INTO sales(name, username, email) 
+	values('Test Three', 'username3', 'test3@test.com');
@@ -0,0 +1,16 @@
+-- Database at schema version 2, with SMB destinations
+CREATE TABLE __schema_version (version INTEGER NOT NULL);
+INSERT INTO __schema_version(version) values(2);
+CREATE TABLE IF NOT EXISTS default_table (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	username text UNIQUE NOT NULL, 
+	email text UNIQUE NOT NULL,
+	smb_host text NOT NULL DEFAULT '',
+	smb_path text NOT NULL DEFAULT '',
+	smb_login text NOT NULL DEFAULT '',
+	smb_password text NOT NULL DEFAULT '',
+	smb_port INTEGER NOT NULL DEFAULT 0
+	);
+INSERT INTO default_table(name, username, email, smb_host, smb_path) 
+	values('Test One', 'username1', 'test1@test.com', 'fileserver', 'scans');
@@ -0,0 +1,28 @@
+-- Database created by a build that added the smb, ftp and fax columns to
+-- createTable before schema versions were recorded
+CREATE TABLE IF NOT EXISTS default_table (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	username text UNIQUE NOT NULL, 
+	email text UNIQUE NOT NULL,
+	smb_host text NOT NULL DEFAULT '',
+	smb_path text NOT NULL DEFAULT '',
+	smb_login text NOT NULL DEFAULT '',
+	smb_password text NOT NULL DEFAULT '',
+	smb_port INTEGER NOT NULL DEFAULT 0,
+	ftp_host text NOT NULL DEFAULT '',
+	ftp_path text NOT NULL DEFAULT '',
+	ftp_login text NOT NULL DEFAULT '',
+	ftp_password text NOT NULL DEFAULT '',
+	ftp_port INTEGER NOT NULL DEFAULT 0,
+	fax_number text NOT NULL DEFAULT '',
+	fax_subaddress text NOT NULL DEFAULT '',
+	ifax_address text NOT NULL DEFAULT '',
+	fax_speed text NOT NULL DEFAULT '',
+	fax_ecm text NOT NULL DEFAULT '',
+	fax_encryption text NOT NULL DEFAULT '',
+	fax_encryption_key INTEGER NOT NULL DEFAULT 0
+	);
+INSERT INTO default_table(name, username, email, smb_host, smb_path,
+	fax_number) values('Test One', 'username1', 'test1@test.com', 'fileserver',
+	'scans', '5550199');
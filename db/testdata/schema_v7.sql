@@ -0,0 +1,54 @@
+-- Database at schema version 7, with SMB and FTP destinations, groups and a
+-- reserved slot but without stored slots for their one touch keys
+CREATE TABLE __schema_version (version INTEGER NOT NULL);
+INSERT INTO __schema_version(version) values(7);
+CREATE TABLE IF NOT EXISTS default_table (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name text NOT NULL,
+	username text UNIQUE NOT NULL, 
+	email text UNIQUE NOT NULL,
+	smb_host text NOT NULL DEFAULT '',
+	smb_path text NOT NULL DEFAULT '',
+	smb_login text NOT NULL DEFAULT '',
+	smb_password text NOT NULL DEFAULT '',
+	smb_port INTEGER NOT NULL DEFAULT 0,
+	ftp_host text NOT NULL DEFAULT '',
+	ftp_path text NOT NULL DEFAULT '',
+	ftp_login text NOT NULL DEFAULT '',
+	ftp_password text NOT NULL DEFAULT '',
+	ftp_port INTEGER NOT NULL DEFAULT 0,
+	fax_number text NOT NULL DEFAULT '',
+	fax_subaddress text NOT NULL DEFAULT '',
+	ifax_address text NOT NULL DEFAULT '',
+	fax_speed text NOT NULL DEFAULT '',
+	fax_ecm text NOT NULL DEFAULT '',
+	fax_encryption text NOT NULL DEFAULT '',
+	fax_encryption_key INTEGER NOT NULL DEFAULT 0,
+	otk_slot INTEGER NOT NULL DEFAULT 0,
+	number INTEGER NOT NULL DEFAULT 0
+	);
+INSERT INTO default_table(name, username, email, smb_host, smb_path, otk_slot, number) 
+	values('Test One', 'username1', 'test1@test.com', 'fileserver', 'scans', 2, 1);
+INSERT INTO default_table(name, username, email, smb_host, smb_path, ftp_host, ftp_path, otk_slot, number) 
+	values('Test Two', 'username2', 'test2@test.com', 'fileserver', 'scans', 'ftpserver', '/scans', 1, 2);
+INSERT INTO default_table(name, username, email, smb_host, smb_path, otk_slot, number) 
+	values('Test Three', 'username3', 'test3@test.com', 'fileserver', 'scans', -1, 3);
+CREATE TABLE IF NOT EXISTS __groups (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	table_name text NOT NULL,
+	name text NOT NULL COLLATE NOCASE,
+	UNIQUE(table_name, name)
+	);
+CREATE TABLE IF NOT EXISTS __group_members (
+	group_id INTEGER NOT NULL,
+	entry_id INTEGER NOT NULL,
+	PRIMARY KEY(group_id, entry_id)
+	);
+INSERT INTO __groups(table_name, name) values('default_table', 'Sales');
+INSERT INTO __groups(table_name, name) values('default_table', 'Support');
+CREATE TABLE IF NOT EXISTS __reserved_slots (
+	table_name text NOT NULL,
+	slot INTEGER NOT NULL,
+	PRIMARY KEY(table_name, slot)
+	);
+INSERT INTO __reserved_slots(table_name, slot) values('default_table', 5);
@@ -0,0 +1,161 @@
+package db
+
+import (
+	"database/sql"
+)
+
+/*
+	sqlConn is what a SQLiteRepository runs its statements on, the database
+	or the transaction of Transaction.
+*/
+
+type sqlConn interface {
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Begin() (sqlTx, error)
+	Close() error
+}
+
+/*
+	sqlTx is a transaction started by a sqlConn, *sql.Tx or a savepoint of
+	one.
+*/
+
+type sqlTx interface {
+	execer
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Commit() error
+	Rollback() error
+}
+
+/*
+	dbConn runs statements on the database, Begin starts a transaction.
+*/
+
+type dbConn struct {
+	*sql.DB
+}
+
+func (c dbConn) Begin() (sqlTx, error) {
+	return c.DB.Begin()
+}
+
+/*
+	txConn runs statements on a transaction. Begin starts a savepoint so the
+	operations that use their own transaction can be ran inside of it.
+*/
+
+type txConn struct {
+	sqlTx
+}
+
+func (c txConn) Begin() (sqlTx, error) {
+	_, err := c.Exec("SAVEPOINT nested;")
+	if err != nil {
+		return nil, err
+	}
+
+	return &savepoint{sqlTx: c.sqlTx}, nil
+}
+
+func (c txConn) Close() error {
+	return nil
+}
+
+/*
+	savepoint is a transaction nested in another. Committing it releases it
+	into the outer transaction, rolling it back only undoes its statements.
+*/
+
+type savepoint struct {
+	sqlTx
+	done bool
+}
+
+func (s *savepoint) Commit() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+
+	s.done = true
+	_, err := s.Exec("RELEASE nested;")
+	return err
+}
+
+func (s *savepoint) Rollback() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+
+	s.done = true
+	_, err := s.Exec("ROLLBACK TO nested;")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Exec("RELEASE nested;")
+	return err
+}
+
+/*
+	Runs fn against a SQLiteRepository that runs every statement inside a
+	single transaction. The transaction is committed if fn returns nil and
+	rolled back otherwise, operations of fn that fail are rolled back on
+	their own so fn can carry on after them. The current table is updated
+	to the one fn ends on if the transaction is committed.
+
+	Returns the error of fn or a StorageError if there is an issue with SQL
+*/
+
+func (r *SQLiteRepository) Transaction(fn func(tx AddressBookRepository) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return storageError("begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	t := &SQLiteRepository{db: txConn{tx}, currentTable: r.currentTable}
+	err = fn(t)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return storageError("commit transaction", err)
+	}
+
+	r.currentTable = t.currentTable
+	return nil
+}
+
+/*
+	Runs fn against a copy of the JSONRepository whose changes are kept in
+	memory. The changes are written to the file at once if fn returns nil and
+	discarded otherwise. The current table is updated to the one fn ends on
+	if the changes are written.
+
+	Returns the error of fn or a StorageError if the file cannot be written
+*/
+
+func (r *JSONRepository) Transaction(fn func(tx AddressBookRepository) error) error {
+	t := &JSONRepository{path: r.path, book: r.book.clone(),
+		currentTable: r.currentTable, inTransaction: true}
+
+	err := fn(t)
+	if err != nil {
+		return err
+	}
+
+	err = r.commit(func(b *jsonBook) error {
+		b.Tables = t.book.Tables
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	r.currentTable = t.currentTable
+	return nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	errAbort := errors.New("abort")
+	e4 := Entry{Name: "Test Four", Username: "username4", Email: "test4@test.com"}
+
+	tt := []struct {
+		description string
+		fn          func(tx AddressBookRepository) error
+		expected    error
+		usernames   []string
+	}{
+		{
+			description: "commit",
+			fn: func(tx AddressBookRepository) error {
+				_, err := tx.Insert(e4)
+				return err
+			},
+			usernames: []string{"username1", "username2", "username3",
+				"username4"},
+		},
+		{
+			description: "roll back",
+			fn: func(tx AddressBookRepository) error {
+				_, err := tx.Insert(e4)
+				if err != nil {
+					return err
+				}
+
+				err = tx.Delete("username1")
+				if err != nil {
+					return err
+				}
+
+				return errAbort
+			},
+			expected:  errAbort,
+			usernames: []string{"username1", "username2", "username3"},
+		},
+		{
+			description: "carry on after a failed operation",
+			fn: func(tx AddressBookRepository) error {
+				_, err := tx.Insert(*e1)
+				if err == nil {
+					return errors.New("duplicate was inserted")
+				}
+
+				_, err = tx.Insert(e4)
+				return err
+			},
+			usernames: []string{"username1", "username2", "username3",
+				"username4"},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			sqliteRepo, teardown := SetupWithInserts(t)
+			defer teardown()
+
+			jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+			defer teardownJSON()
+
+			for _, repo := range []AddressBookRepository{sqliteRepo, jsonRepo} {
+				err := repo.Transaction(tc.fn)
+				assertError(t, err, tc.expected)
+
+				all, err := repo.All()
+				assertError(t, err, nil)
+
+				var usernames []string
+				for _, e := range all {
+					usernames = append(usernames, e.Username)
+				}
+
+				if !reflect.DeepEqual(usernames, tc.usernames) {
+					t.Fatalf("got: %v, expected: %v", usernames, tc.usernames)
+				}
+			}
+		})
+	}
+
+	t.Run("json changes are written on commit", func(t *testing.T) {
+		repo, path, teardown := setupJSONWithInserts(t)
+		defer teardown()
+
+		err := repo.Transaction(func(tx AddressBookRepository) error {
+			_, err := tx.Insert(e4)
+			return err
+		})
+		assertError(t, err, nil)
+
+		reopened, err := NewJSONRepository(path)
+		assertError(t, err, nil)
+		assertError(t, reopened.Initialize(), nil)
+
+		_, err = reopened.GetByUsername("username4")
+		assertError(t, err, nil)
+	})
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+	Copies every table of src, and their Entries, Groups and reserved slots,
+	into dst. Tables that do not exist in dst are created, Entries are
+	inserted into the tables that do and keep their OneTouchKey slots.
+	Everything is copied in a single transaction of dst, so a copy that fails
+	leaves dst as it was and can be ran again. The current table of both
+	repositories is left as it was.
+
+	Returns the number of Entries that were copied. The table and username of
+	the Entry being copied is added to the error if the copy fails.
+*/
+
+func CopyTables(dst, src AddressBookRepository) (n int, err error) {
+	srcTable, dstTable := src.CurrentTable(), dst.CurrentTable()
+	defer func() {
+		srcErr := src.SwitchTable(srcTable)
+		dstErr := dst.SwitchTable(dstTable)
+		if err == nil && srcErr != nil {
+			err = srcErr
+		}
+
+		if err == nil && dstErr != nil {
+			err = dstErr
+		}
+	}()
+
+	tables, err := src.ListTables()
+	if err != nil {
+		return 0, err
+	}
+
+	err = dst.Transaction(func(tx AddressBookRepository) error {
+		n = 0
+		for _, t := range tables {
+			copied, err := copyTableInto(tx, src, t)
+			n += copied
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+/*
+	Copies a table of src, its Entries, Groups and reserved slots, into dst
+	and returns the number of Entries that were copied.
+*/
+
+func copyTableInto(dst, src AddressBookRepository, t string) (int, error) {
+	err := src.SwitchTable(t)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", err, t)
+	}
+
+	entries, err := src.All()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", err, t)
+	}
+
+	err = dst.NewTable(t)
+	if errors.Is(err, ErrTableExists) {
+		err = dst.SwitchTable(t)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", err, t)
+	}
+
+	err = copyReservedSlots(dst, src)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", err, t)
+	}
+
+	n := 0
+	usernames := make(map[int64]string)
+	for _, e := range entries {
+		_, err = dst.Insert(*e)
+		if err != nil {
+			return n, fmt.Errorf("%w: %v %v", err, t, e.Username)
+		}
+
+		usernames[e.ID] = e.Username
+		n++
+	}
+
+	err = copyGroups(dst, src, usernames)
+	if err != nil {
+		return n, fmt.Errorf("%w: %v", err, t)
+	}
+
+	return n, nil
+}
+
+/*
+	Copies the Groups of the current table of src into the current table of
+	dst, keeping their slots and numbers. Members are matched by username as
+	the Entries are given new IDs when they are copied.
+*/
+
+func copyGroups(dst, src AddressBookRepository, usernames map[int64]string) error {
+	groups, err := src.Groups()
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		_, err = dst.InsertGroup(Group{Name: g.Name, Slot: g.Slot,
+			Number: g.Number})
+		if err != nil && !errors.Is(err, ErrGroupExists) {
+			return err
+		}
+
+		for _, id := range g.Members {
+			err = dst.AddToGroup(g.Name, usernames[id])
+			if err != nil && !errors.Is(err, ErrAlreadyInGroup) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+	Reserves the reserved slots of the current table of src in the current
+	table of dst.
+*/
+
+func copyReservedSlots(dst, src AddressBookRepository) error {
+	reserved, err := src.ReservedSlots()
+	if err != nil {
+		return err
+	}
+
+	existing, err := dst.ReservedSlots()
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range reserved {
+		if containsSlot(existing, slot) {
+			continue
+		}
+
+		err = dst.ReserveSlot(slot)
+		if err != nil {
+			return fmt.Errorf("%w: slot %d", err, slot)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Reports whether slot is one of slots.
+*/
+
+func containsSlot(slots []int64, slot int64) bool {
+	for _, s := range slots {
+		if s == slot {
+			return true
+		}
+	}
+
+	return false
+}
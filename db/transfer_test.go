@@ -0,0 +1,151 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCopyTables(t *testing.T) {
+	src, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	assertError(t, src.NewTable("new_table"), nil)
+	_, err := src.Insert(*e1)
+	assertError(t, err, nil)
+	_, err = src.NewGroup("Sales")
+	assertError(t, err, nil)
+	assertError(t, src.AddToGroup("Sales", e1.Username), nil)
+	assertError(t, src.SetSlot(e1.Username, 7), nil)
+	assertError(t, src.ReserveSlot(1), nil)
+
+	dst, _, teardownJSON := setupJSON(t)
+	defer teardownJSON()
+
+	n, err := CopyTables(dst, src)
+	assertError(t, err, nil)
+	if n != 4 {
+		t.Fatalf("got: %v, expected: %v", n, 4)
+	}
+
+	if src.CurrentTable() != "new_table" {
+		t.Fatalf("got: %v, expected: %v", src.CurrentTable(), "new_table")
+	}
+
+	tables, err := dst.ListTables()
+	assertError(t, err, nil)
+
+	expected := []string{DEFAULT_TABLE, "new_table"}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Fatalf("got: %v, expected: %v", tables, expected)
+	}
+
+	for _, table := range expected {
+		assertError(t, src.SwitchTable(table), nil)
+		assertError(t, dst.SwitchTable(table), nil)
+
+		want, err := src.All()
+		assertError(t, err, nil)
+
+		got, err := dst.All()
+		assertError(t, err, nil)
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got: %v, expected: %v", got, want)
+		}
+
+		wantGroups, err := src.Groups()
+		assertError(t, err, nil)
+
+		gotGroups, err := dst.Groups()
+		assertError(t, err, nil)
+
+		if !reflect.DeepEqual(gotGroups, wantGroups) {
+			t.Fatalf("got: %v, expected: %v", gotGroups, wantGroups)
+		}
+
+		wantReserved, err := src.ReservedSlots()
+		assertError(t, err, nil)
+
+		gotReserved, err := dst.ReservedSlots()
+		assertError(t, err, nil)
+
+		if !reflect.DeepEqual(gotReserved, wantReserved) {
+			t.Fatalf("got: %v, expected: %v", gotReserved, wantReserved)
+		}
+	}
+
+	t.Run("copying again reports the duplicate", func(t *testing.T) {
+		_, err := CopyTables(dst, src)
+		if !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("got: %v, expected: %v", err, ErrDuplicate)
+		}
+	})
+}
+
+func TestCopyTablesRollsBack(t *testing.T) {
+	src, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	assertError(t, src.NewTable("new_table"), nil)
+	_, err := src.Insert(*e1)
+	assertError(t, err, nil)
+
+	tt := []struct {
+		description string
+		setup       func(t *testing.T) (AddressBookRepository, func())
+	}{
+		{
+			description: "sqlite",
+			setup: func(t *testing.T) (AddressBookRepository, func()) {
+				return setup(t)
+			},
+		},
+		{
+			description: "json",
+			setup: func(t *testing.T) (AddressBookRepository, func()) {
+				dst, _, teardown := setupJSON(t)
+				return dst, teardown
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			dst, teardown := tc.setup(t)
+			defer teardown()
+
+			// e1 is already in the new_table of dst so the copy fails on it
+			assertError(t, dst.NewTable("new_table"), nil)
+			_, err := dst.Insert(*e1)
+			assertError(t, err, nil)
+			assertError(t, dst.SwitchTable(DEFAULT_TABLE), nil)
+
+			n, err := CopyTables(dst, src)
+			if !errors.Is(err, ErrDuplicate) || n != 0 {
+				t.Fatalf("got: %v %v, expected: %v", n, err, ErrDuplicate)
+			}
+
+			all, err := dst.All()
+			assertError(t, err, nil)
+			if len(all) != 0 {
+				t.Fatalf("got: %v, expected: %v", all, "no entries")
+			}
+
+			assertError(t, dst.SwitchTable("new_table"), nil)
+			assertError(t, dst.Delete(e1.Username), nil)
+
+			n, err = CopyTables(dst, src)
+			assertError(t, err, nil)
+			if n != 4 {
+				t.Fatalf("got: %v, expected: %v", n, 4)
+			}
+
+			if dst.CurrentTable() != "new_table" {
+				t.Fatalf("got: %v, expected: %v", dst.CurrentTable(),
+					"new_table")
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"errors"
+)
+
+/*
+	Outcomes of Upsert for an Entry.
+		UpsertInserted the username was not in the current table
+		UpsertUpdated the Entry of the username was replaced
+		UpsertUnchanged the Entry of the username already had the same fields
+*/
+
+type UpsertResult string
+
+const (
+	UpsertInserted  UpsertResult = "inserted"
+	UpsertUpdated   UpsertResult = "updated"
+	UpsertUnchanged UpsertResult = "unchanged"
+)
+
+/*
+	Reports whether two versions of an Entry have the same fields. The
+	OneTouchKey slot and number are not compared as Update keeps those of the
+	old Entry.
+*/
+
+func sameFields(old, u *Entry) bool {
+	for _, key := range diffFields {
+		if key == "number" {
+			continue
+		}
+
+		if fieldValue(old, key) != fieldValue(u, key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	Inserts an Entry into the current table or, if its username is already
+	in the table, updates the given fields of the Entry of the username with
+	those of e and leaves the others as they are. Entries whose fields have
+	not changed are left alone.
+
+	e: the Entry to insert or update with
+	fields: keys of the fields of e to update, ie the columns of a csv file.
+	Every field is replaced when none are given
+
+	Returns what happened to the Entry or the error of Insert or Update
+*/
+
+func Upsert(r AddressBookRepository, e Entry, fields ...string) (UpsertResult, error) {
+	old, err := r.GetByUsername(e.Username)
+	if errors.Is(err, ErrNotFound) {
+		_, err = r.Insert(e)
+		if err != nil {
+			return "", err
+		}
+
+		return UpsertInserted, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	u := e
+	if len(fields) > 0 {
+		u = *old
+		err = u.MergeFields(&e, fields...)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if sameFields(old, &u) {
+		return UpsertUnchanged, nil
+	}
+
+	_, err = r.Update(e.Username, &u)
+	if err != nil {
+		return "", err
+	}
+
+	return UpsertUpdated, nil
+}
+
+/*
+	Deletes the Entries of the current table whose username is not one of
+	the given usernames, ie to sync the table with a roster.
+
+	Returns the usernames of the deleted Entries, with those deleted before
+	an error if one occurs.
+*/
+
+func RemoveAbsent(r AddressBookRepository, usernames []string) ([]string, error) {
+	keep := make(map[string]bool)
+	for _, u := range usernames {
+		keep[u] = true
+	}
+
+	all, err := r.All()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, e := range all {
+		if keep[e.Username] {
+			continue
+		}
+
+		err = r.Delete(e.Username)
+		if err != nil {
+			return removed, err
+		}
+
+		removed = append(removed, e.Username)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUpsert(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	tt := []struct {
+		description string
+		entry       Entry
+		result      UpsertResult
+		err         error
+	}{
+		{
+			description: "new username",
+			entry: Entry{Name: "Test Four", Username: "username4",
+				Email: "test4@test.com"},
+			result: UpsertInserted,
+		},
+		{
+			description: "same fields",
+			entry: Entry{Name: e1.Name, Username: e1.Username,
+				Email: e1.Email},
+			result: UpsertUnchanged,
+		},
+		{
+			description: "changed name",
+			entry: Entry{Name: "Test Too", Username: e2.Username,
+				Email: e2.Email},
+			result: UpsertUpdated,
+		},
+		{
+			description: "email of another user",
+			entry: Entry{Name: e3.Name, Username: e3.Username,
+				Email: e1.Email},
+			err: ErrDuplicate,
+		},
+	}
+
+	for _, repo := range []AddressBookRepository{sqliteRepo, jsonRepo} {
+		for _, tc := range tt {
+			result, err := Upsert(repo, tc.entry)
+			assertError(t, err, tc.err)
+			if result != tc.result {
+				t.Fatalf("%v got: %v, expected: %v", tc.description, result,
+					tc.result)
+			}
+		}
+
+		e, err := repo.GetByUsername(e2.Username)
+		assertError(t, err, nil)
+		if e.Name != "Test Too" || e.Slot != e2.Slot || e.Number != e2.Number {
+			t.Fatalf("got: %v, expected: %v", e, "Test Too in slot 2")
+		}
+	}
+}
+
+func TestUpsertFields(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	roster := []string{"name", "username", "email"}
+	for _, repo := range []AddressBookRepository{sqliteRepo, jsonRepo} {
+		u := *e1
+		u.SMB = SMBDestination{Host: "fileserver", Path: "scans", Port: 4450}
+		u.FTP = FTPDestination{Host: "ftpserver", Path: "/scans"}
+		u.Fax = FaxDestination{Number: "555-0100"}
+		_, err := repo.Update(e1.Username, &u)
+		assertError(t, err, nil)
+
+		result, err := Upsert(repo, Entry{Name: e1.Name, Username: e1.Username,
+			Email: e1.Email}, roster...)
+		assertError(t, err, nil)
+		if result != UpsertUnchanged {
+			t.Fatalf("got: %v, expected: %v", result, UpsertUnchanged)
+		}
+
+		result, err = Upsert(repo, Entry{Name: "Test Uno", Username: e1.Username,
+			Email: e1.Email}, roster...)
+		assertError(t, err, nil)
+		if result != UpsertUpdated {
+			t.Fatalf("got: %v, expected: %v", result, UpsertUpdated)
+		}
+
+		got, err := repo.GetByUsername(e1.Username)
+		assertError(t, err, nil)
+		if got.Name != "Test Uno" || got.SMB != u.SMB || got.FTP != u.FTP ||
+			got.Fax != u.Fax {
+			t.Fatalf("got: %v, expected: %v", got, "Test Uno with its destinations")
+		}
+	}
+}
+
+func TestRemoveAbsent(t *testing.T) {
+	sqliteRepo, teardown := SetupWithInserts(t)
+	defer teardown()
+
+	jsonRepo, _, teardownJSON := setupJSONWithInserts(t)
+	defer teardownJSON()
+
+	for _, repo := range []AddressBookRepository{sqliteRepo, jsonRepo} {
+		removed, err := RemoveAbsent(repo, []string{"username2", "username4"})
+		assertError(t, err, nil)
+
+		expected := []string{"username1", "username3"}
+		if !reflect.DeepEqual(removed, expected) {
+			t.Fatalf("got: %v, expected: %v", removed, expected)
+		}
+
+		all, err := repo.All()
+		assertError(t, err, nil)
+		if len(all) != 1 || all[0].Username != "username2" {
+			t.Fatalf("got: %v, expected: %v", all, "only username2")
+		}
+	}
+}
@@ -33,17 +33,54 @@ type tableInfo struct {
 
 const DEFAULT_TABLE = "default_table"
 
+/*
+	Name of the table that records the schema version of the database. Names
+	starting with two underscores cannot be given to user tables.
+*/
+
+const versionTable = "__schema_version"
+
+/*
+	Names of the tables that hold the groups of every user table and their
+	members.
+*/
+
+const (
+	groupsTable  = "__groups"
+	membersTable = "__group_members"
+)
+
+/*
+	Name of the table that holds the reserved OneTouchKey slots of every user
+	table.
+*/
+
+const reservedTable = "__reserved_slots"
+
 /*
 	SQLite queries
 */
 
 const (
-	insert          = "INSERT INTO %v(name, username, email) values(?,?,?);"
-	update          = "UPDATE %v SET name=?, username=?, email=? WHERE username=?;"
+	entryColumns = `id, name, username, email, smb_host, smb_path, smb_login,
+	smb_password, smb_port, ftp_host, ftp_path, ftp_login, ftp_password,
+	ftp_port, fax_number, fax_subaddress, ifax_address, fax_speed, fax_ecm,
+	fax_encryption, fax_encryption_key, otk_slot, smb_slot, ftp_slot, number`
+	insert = `INSERT INTO %v(name, username, email, smb_host, smb_path,
+	smb_login, smb_password, smb_port, ftp_host, ftp_path, ftp_login,
+	ftp_password, ftp_port, fax_number, fax_subaddress, ifax_address,
+	fax_speed, fax_ecm, fax_encryption, fax_encryption_key, otk_slot,
+	smb_slot, ftp_slot, number)
+	values(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);`
+	update = `UPDATE %v SET name=?, username=?, email=?, smb_host=?, smb_path=?,
+	smb_login=?, smb_password=?, smb_port=?, ftp_host=?, ftp_path=?,
+	ftp_login=?, ftp_password=?, ftp_port=?, fax_number=?, fax_subaddress=?,
+	ifax_address=?, fax_speed=?, fax_ecm=?, fax_encryption=?,
+	fax_encryption_key=? WHERE username=?;`
 	delete          = "DELETE FROM %v WHERE username=?;"
-	selectAll       = "SELECT * FROM %v;"
+	selectAll       = "SELECT " + entryColumns + " FROM %v;"
 	selectTable     = "SELECT name FROM sqlite_master WHERE type='table' AND name=?;"
-	selectByUername = "SELECT * FROM %v WHERE username=?;"
+	selectByUername = "SELECT " + entryColumns + " FROM %v WHERE username=?;"
 	createTable     = `CREATE TABLE IF NOT EXISTS %v (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name text NOT NULL,
@@ -53,7 +90,91 @@ const (
 	clearTable  = "DELETE FROM %v"
 	deleteTable = "DROP TABLE %v"
 	listTables  = `SELECT name from sqlite_master WHERE TYPE="table" AND name 
-	NOT LIKE '%sql%';`
+	NOT LIKE '%sql%' AND substr(name, 1, 2) != '__';`
+	selectColumns      = "PRAGMA table_info(%v);"
+	addColumn          = "ALTER TABLE %v ADD COLUMN %v;"
+	createVersionTable = "CREATE TABLE IF NOT EXISTS " + versionTable + " (version INTEGER NOT NULL);"
+	selectVersion      = "SELECT version FROM " + versionTable + ";"
+	deleteVersion      = "DELETE FROM " + versionTable + ";"
+	insertVersion      = "INSERT INTO " + versionTable + "(version) values(?);"
+	createGroupsTable  = "CREATE TABLE IF NOT EXISTS " + groupsTable + ` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_name text NOT NULL,
+		name text NOT NULL COLLATE NOCASE,
+		UNIQUE(table_name, name)
+		);`
+	createMembersTable = "CREATE TABLE IF NOT EXISTS " + membersTable + ` (
+		group_id INTEGER NOT NULL,
+		entry_id INTEGER NOT NULL,
+		PRIMARY KEY(group_id, entry_id)
+		);`
+	tableGroups   = "SELECT id FROM " + groupsTable + " WHERE table_name=?"
+	insertGroup   = "INSERT INTO " + groupsTable + "(table_name, name, slot, number) values(?,?,?,?);"
+	selectGroup   = "SELECT id FROM " + groupsTable + " WHERE table_name=? AND name=?;"
+	selectGroups  = "SELECT id, name, slot, number FROM " + groupsTable + " WHERE table_name=? ORDER BY id;"
+	selectMembers = "SELECT group_id, entry_id FROM " + membersTable +
+		" WHERE group_id IN (" + tableGroups + ") ORDER BY rowid;"
+	insertMember       = "INSERT INTO " + membersTable + "(group_id, entry_id) values(?,?);"
+	deleteEntryMembers = "DELETE FROM " + membersTable + " WHERE group_id IN (" +
+		tableGroups + ") AND entry_id IN (SELECT id FROM %v WHERE username=?);"
+	clearMembers = "DELETE FROM " + membersTable + " WHERE group_id IN (" +
+		tableGroups + ");"
+	deleteGroups = "DELETE FROM " + groupsTable + " WHERE table_name=?;"
+	assignSlots  = `UPDATE %[1]v SET otk_slot = (SELECT CASE WHEN COUNT(*) > 1000
+	THEN -1 ELSE COUNT(*) END FROM %[1]v AS o WHERE o.id <= %[1]v.id)
+	WHERE otk_slot = 0;`
+	createReservedTable = "CREATE TABLE IF NOT EXISTS " + reservedTable + ` (
+		table_name text NOT NULL,
+		slot INTEGER NOT NULL,
+		PRIMARY KEY(table_name, slot)
+		);`
+	usedSlots = `SELECT otk_slot FROM %[1]v WHERE otk_slot > 0
+	UNION ALL SELECT smb_slot FROM %[1]v WHERE smb_slot > 0
+	UNION ALL SELECT ftp_slot FROM %[1]v WHERE ftp_slot > 0
+	UNION ALL SELECT slot FROM ` + reservedTable + ` WHERE table_name=?1
+	UNION ALL SELECT slot FROM ` + groupsTable + ` WHERE table_name=?1 AND slot > 0;`
+	slotHolder = `SELECT username FROM %[1]v WHERE (otk_slot=?1 AND username!=?2)
+	OR smb_slot=?1 OR ftp_slot=?1
+	UNION ALL SELECT name FROM ` + groupsTable + ` WHERE table_name=?3 AND slot=?1;`
+	updateSlot    = "UPDATE %v SET otk_slot=? WHERE username=?;"
+	selectSlot    = "SELECT otk_slot FROM %v WHERE username=?;"
+	isReserved    = "SELECT slot FROM " + reservedTable + " WHERE table_name=? AND slot=?;"
+	insertReserve = "INSERT INTO " + reservedTable + "(table_name, slot) values(?,?);"
+	deleteReserve = "DELETE FROM " + reservedTable + " WHERE table_name=? AND slot=?;"
+	selectReserve = "SELECT slot FROM " + reservedTable + " WHERE table_name=? ORDER BY slot;"
+	clearReserve  = "DELETE FROM " + reservedTable + " WHERE table_name=?;"
+	assignNumbers = `UPDATE %[1]v SET number = (SELECT COUNT(*) FROM %[1]v AS o
+	WHERE o.id <= %[1]v.id) WHERE number = 0;`
+	selectNumbers = "SELECT number FROM %v WHERE number > 0;"
+	numberHolder  = "SELECT username FROM %v WHERE number=? AND username!=?;"
+	numberOrder   = "SELECT id, number FROM %v ORDER BY number, id;"
+	updateNumber  = "UPDATE %v SET number=? WHERE id=?;"
+	copyEntries   = "INSERT INTO %v(" + entryColumns + ") SELECT " + entryColumns +
+		" FROM %v;"
+	copyReserve = "INSERT INTO " + reservedTable + "(table_name, slot) SELECT ?, slot FROM " +
+		reservedTable + " WHERE table_name=?;"
+	copyMembers = "INSERT INTO " + membersTable + "(group_id, entry_id) SELECT ?, entry_id FROM " +
+		membersTable + " WHERE group_id=?;"
+	renameTable   = "ALTER TABLE %v RENAME TO %v;"
+	renameGroups  = "UPDATE " + groupsTable + " SET table_name=? WHERE table_name=?;"
+	renameReserve = "UPDATE " + reservedTable + " SET table_name=? WHERE table_name=?;"
+
+	selectKeySlots  = "SELECT otk_slot, smb_slot, ftp_slot FROM %v WHERE username=?;"
+	updateKeySlots  = "UPDATE %v SET smb_slot=?, ftp_slot=? WHERE username=?;"
+	addGroupSlot    = "ALTER TABLE " + groupsTable + " ADD COLUMN slot INTEGER NOT NULL DEFAULT 0;"
+	selectKeyOwners = `SELECT id, otk_slot, smb_slot, ftp_slot, smb_host, smb_path,
+	smb_login, smb_password, smb_port, ftp_host, ftp_path, ftp_login,
+	ftp_password, ftp_port FROM %v;`
+	updateOwnerSlots = "UPDATE %v SET smb_slot=?, ftp_slot=? WHERE id=?;"
+	selectGroupSlots = "SELECT id, slot FROM " + groupsTable + " WHERE table_name=? ORDER BY id;"
+	updateGroupSlot  = "UPDATE " + groupsTable + " SET slot=? WHERE id=?;"
+
+	addGroupNumber     = "ALTER TABLE " + groupsTable + " ADD COLUMN number INTEGER NOT NULL DEFAULT 0;"
+	assignGroupNumbers = "UPDATE " + groupsTable + ` SET number = (SELECT COUNT(*)
+	FROM ` + groupsTable + ` AS o WHERE o.table_name = ` + groupsTable + `.table_name
+	AND o.id <= ` + groupsTable + `.id) WHERE number = 0;`
+	groupNumbers      = "SELECT number FROM " + groupsTable + " WHERE table_name=? AND number > 0;"
+	groupNumberHolder = "SELECT id FROM " + groupsTable + " WHERE table_name=? AND number=?;"
 )
 
 /*
@@ -66,6 +187,12 @@ const (
 	emailPattern        = `^[a-zA-Z]+([\._-]?[a-zA-Z0-9])+@[a-zA-Z]+(\.[a-zA-Z]+)+$`
 	tablePattern        = `^[a-zA-Z_]{1}([a-zA-Z0-9]+[_]?)*$`
 	bracketTablePattern = `^[\[][a-zA-Z0-9]+([ +!?._\-a-zA-Z0-9])*[\]]$`
+	hostPattern         = `^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`
+	pathPattern         = `^[^\x00-\x1f"*:<>?|]+$`
+	loginPattern        = `^([a-zA-Z0-9._-]+\\)?[a-zA-Z0-9._@-]+$`
+	faxPattern          = `^\+?[0-9]([0-9 *#P-]{0,30}[0-9*#])?$`
+	subaddressPattern   = `^[0-9*#]{1,20}$`
+	groupPattern        = `^[a-zA-Z0-9]+([-._ ]?[a-zA-Z0-9]+)*$`
 )
 
 var (
@@ -73,19 +200,25 @@ var (
 		ID:       1,
 		Name:     "Test One",
 		Username: "username1",
-		Email:    "test1@test.com"}
+		Email:    "test1@test.com",
+		Slot:     1,
+		Number:   1}
 
 	e2 = &Entry{
 		ID:       2,
 		Name:     "Test Two",
 		Username: "username2",
-		Email:    "test2@test.com"}
+		Email:    "test2@test.com",
+		Slot:     2,
+		Number:   2}
 
 	e3 = &Entry{
 		ID:       3,
 		Name:     "Test Three",
 		Username: "username3",
-		Email:    "test3@test.com"}
+		Email:    "test3@test.com",
+		Slot:     3,
+		Number:   3}
 )
 
 /*
@@ -105,6 +238,40 @@ var (
 	ErrTableExists          = errors.New("table already exists")
 	ErrTableDoesNotExist    = errors.New("table does not exist")
 	ErrTableCannotBeDeleted = errors.New("table cannot be deleted")
+	ErrTableCannotBeRenamed = errors.New("table cannot be renamed")
+	ErrDatabaseLocked       = errors.New("database is locked by another process")
+	ErrInvalidGroupName     = errors.New("group name is not valid")
+	ErrGroupExists          = errors.New("group already exists")
+	ErrGroupDoesNotExist    = errors.New("group does not exist")
+	ErrAlreadyInGroup       = errors.New("user is already in the group")
+	ErrInvalidSlot          = errors.New("one touch key slot is not valid")
+	ErrSlotTaken            = errors.New("one touch key slot is already in use")
+	ErrSlotReserved         = errors.New("one touch key slot is reserved")
+	ErrSlotNotReserved      = errors.New("one touch key slot is not reserved")
+	ErrSlotCollision        = errors.New("one touch key slot is used more than once")
+	ErrInvalidNumber        = errors.New("address book number is not valid")
+	ErrNumberTaken          = errors.New("address book number is already in use")
+	ErrNumberCollision      = errors.New("address book number is used more than once")
+	ErrAddressBookFull      = errors.New("address book is full")
+	ErrInvalidMergeStrategy = errors.New("merge strategy must be skip, overwrite or rename")
+	ErrMergeSameTable       = errors.New("table cannot be merged into itself")
+	ErrStorage              = errors.New("database storage error")
+	ErrUnknownField         = errors.New("field is not valid")
+	ErrInvalidSMBHost       = errors.New("smb host is not valid")
+	ErrInvalidSMBPath       = errors.New("smb path is not valid")
+	ErrInvalidSMBLogin      = errors.New("smb login is not valid")
+	ErrInvalidFTPHost       = errors.New("ftp host is not valid")
+	ErrInvalidFTPPath       = errors.New("ftp path is not valid")
+	ErrInvalidFTPLogin      = errors.New("ftp login is not valid")
+	ErrInvalidPort          = errors.New("port is not valid")
+	ErrInvalidFaxNumber     = errors.New("fax number is not valid")
+	ErrInvalidFaxSubaddress = errors.New("fax sub-address is not valid")
+	ErrInvalidIFaxAddress   = errors.New("internet fax address is not valid")
+	ErrInvalidFaxSpeed      = errors.New("fax speed is not valid")
+	ErrInvalidFaxECM        = errors.New("fax ecm must be on or off")
+	ErrInvalidFaxEncryption = errors.New("fax encryption must be on or off")
+
+	ErrInvalidFaxEncryptionKey = errors.New("fax encryption key is not valid")
 )
 
 func assertError(t testing.TB, got, expected error) {
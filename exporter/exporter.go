@@ -3,8 +3,12 @@ package exporter
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"log"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	db "github.com/tweekes0/kyocera-ab-tool/db"
 )
@@ -14,7 +18,10 @@ var (
 )
 
 const (
-	xmlPattern = "></Item>"
+	xmlPattern     = "></(Item|Member)>"
+	defaultSMBPort = "445"
+	unsetSMBPort   = "9999"
+	defaultFTPPort = "21"
 )
 
 /*
@@ -24,7 +31,7 @@ const (
 	the each of the XML elements.
 
 	XMLName: name of the XML element
-	Id: the position of the contact within the address book
+	Id: the address book number of the contact
 	Type: defines the element as a contact
 	DisplayName/Kana: the name for the contact
 	MailAddress: the email for the contact
@@ -34,6 +41,9 @@ const (
 	Fax*: attributes for scanning via Fax
 	InetFax*:  attributes for scanning via InternetFax
 
+	Attributes with omitempty are always set by newContactElement and are
+	only left empty when the Schema being exported does not have them.
+
 */
 
 type contactElement struct {
@@ -56,24 +66,25 @@ type contactElement struct {
 	FtpLoginName          string   `xml:"FtpLoginName,attr"`
 	FtpLoginPasswd        string   `xml:"FtpLoginPasswd,attr"`
 	FtpPort               string   `xml:"FtpPort,attr"`
+	FtpEncryption         string   `xml:"FtpEncryption,attr,omitempty"`
 	FaxNumber             string   `xml:"FaxNumber,attr"`
 	FaxSubaddress         string   `xml:"FaxSubaddress,attr"`
 	FaxPassword           string   `xml:"FaxPassword,attr"`
 	FaxCommSpeed          string   `xml:"FaxCommSpeed,attr"`
 	FaxECM                string   `xml:"FaxECM,attr"`
-	FaxEncryptKeyNumber   string   `xml:"FaxEncryptKeyNumber,attr"`
-	FaxEncryption         string   `xml:"FaxEncryption,attr"`
-	FaxEncryptBoxEnabled  string   `xml:"FaxEncryptBoxEnabled,attr"`
-	FaxEncryptBoxID       string   `xml:"FaxEncryptBoxID,attr"`
+	FaxEncryptKeyNumber   string   `xml:"FaxEncryptKeyNumber,attr,omitempty"`
+	FaxEncryption         string   `xml:"FaxEncryption,attr,omitempty"`
+	FaxEncryptBoxEnabled  string   `xml:"FaxEncryptBoxEnabled,attr,omitempty"`
+	FaxEncryptBoxID       string   `xml:"FaxEncryptBoxID,attr,omitempty"`
 	InetFAXAddr           string   `xml:"InetFAXAddr,attr"`
 	InetFAXMode           string   `xml:"InetFAXMode,attr"`
 	InetFAXResolution     string   `xml:"InetFAXResolution,attr"`
 	InetFAXFileType       string   `xml:"InetFAXFileType,attr"`
-	IFaxSendModeType      string   `xml:"IFaxSendModeType,attr"`
+	IFaxSendModeType      string   `xml:"IFaxSendModeType,attr,omitempty"`
 	InetFAXDataSize       string   `xml:"InetFAXDataSize,attr"`
 	InetFAXPaperSize      string   `xml:"InetFAXPaperSize,attr"`
-	InetFAXResolutionEnum string   `xml:"InetFAXResolutionEnum,attr"`
-	InetFAXPaperSizeEnum  string   `xml:"InetFAXPaperSizeEnum,attr"`
+	InetFAXResolutionEnum string   `xml:"InetFAXResolutionEnum,attr,omitempty"`
+	InetFAXPaperSizeEnum  string   `xml:"InetFAXPaperSizeEnum,attr,omitempty"`
 }
 
 /*
@@ -95,26 +106,26 @@ func newContactElement(id int64, e *db.Entry) (*contactElement, error) {
 	p.SendKeisyou = "0"
 	p.SendCorpName = ""
 	p.SendPostName = ""
-	p.SmbHostName = ""
-	p.SmbPath = ""
-	p.SmbLoginPasswd = ""
-	p.SmbLoginName = ""
-	p.SmbPort = "9999"
-	p.FtpPath = ""
-	p.FtpHostName = ""
-	p.FtpLoginName = ""
-	p.FtpLoginPasswd = ""
-	p.FtpPort = "21"
-	p.FaxNumber = ""
-	p.FaxSubaddress = ""
+	p.SmbHostName = e.SMB.Host
+	p.SmbPath = e.SMB.Path
+	p.SmbLoginPasswd = e.SMB.Password
+	p.SmbLoginName = e.SMB.Login
+	p.SmbPort = smbPort(e.SMB)
+	p.FtpPath = e.FTP.Path
+	p.FtpHostName = e.FTP.Host
+	p.FtpLoginName = e.FTP.Login
+	p.FtpLoginPasswd = e.FTP.Password
+	p.FtpPort = ftpPort(e.FTP)
+	p.FaxNumber = e.Fax.Number
+	p.FaxSubaddress = e.Fax.Subaddress
 	p.FaxPassword = ""
-	p.FaxCommSpeed = "BPS_33600"
-	p.FaxECM = "On"
-	p.FaxEncryptKeyNumber = "0"
-	p.FaxEncryption = "Off"
+	p.FaxCommSpeed = withDefault(e.Fax.CommSpeed, db.DefaultFaxCommSpeed)
+	p.FaxECM = withDefault(e.Fax.ECM, db.DefaultFaxECM)
+	p.FaxEncryptKeyNumber = strconv.FormatInt(e.Fax.EncryptionKey, 10)
+	p.FaxEncryption = withDefault(e.Fax.Encryption, db.DefaultFaxEncryption)
 	p.FaxEncryptBoxEnabled = "Off"
 	p.FaxEncryptBoxID = "0000"
-	p.InetFAXAddr = ""
+	p.InetFAXAddr = e.Fax.IFaxAddress
 	p.InetFAXMode = "Simple"
 	p.InetFAXResolution = "3"
 	p.InetFAXFileType = "TIFF_MH"
@@ -127,6 +138,46 @@ func newContactElement(id int64, e *db.Entry) (*contactElement, error) {
 	return p, nil
 }
 
+/*
+	Returns the SmbPort attribute for a SMBDestination. Contacts without a
+	SMB destination keep the placeholder port Net Viewer expects.
+*/
+
+func smbPort(d db.SMBDestination) string {
+	switch {
+	case !d.IsSet():
+		return unsetSMBPort
+	case d.Port == 0:
+		return defaultSMBPort
+	default:
+		return strconv.FormatInt(d.Port, 10)
+	}
+}
+
+/*
+	Returns value, or def when value is empty.
+*/
+
+func withDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+
+	return value
+}
+
+/*
+	Returns the FtpPort attribute for a FTPDestination.
+*/
+
+func ftpPort(d db.FTPDestination) string {
+	if d.Port == 0 {
+		return defaultFTPPort
+	}
+
+	return strconv.FormatInt(d.Port, 10)
+}
+
 /*
 	oneTouchKeyElement models how Kyocera's abstract OneTouchKeys (otk) or
 	scanner shortcuts within an XML file.
@@ -166,47 +217,361 @@ func newOneTouchKeyElement(id, addressId int64, displayName,
 	return p, nil
 }
 
+/*
+	groupMemberElement models a reference from a Kyocera group to one of the
+	contacts of the address book.
+
+	XMLName: name of the XML element
+	ContactId: the Id of the contactElement in the group
+	AddressType: the address of the contact the group sends to ie EMAIL
+*/
+
+type groupMemberElement struct {
+	XMLName     xml.Name `xml:"Member"`
+	ContactId   int64    `xml:"ContactId,attr"`
+	AddressType string   `xml:"AddressType,attr"`
+}
+
+/*
+	groupElement models how Kyocera's see groups within their address books,
+	a named list of contacts that a single OneTouchKey can send to.
+
+	XMLName: name of the XML element
+	Id: the position of the group within the address book
+	Type: defines the element as a group
+	DisplayName/Kana: the name for the group
+	Members: the contacts of the group
+*/
+
+type groupElement struct {
+	XMLName         xml.Name `xml:"Item"`
+	Id              int64    `xml:"Id,attr"`
+	Type            string   `xml:"Type,attr"`
+	DisplayName     string   `xml:"DisplayName,attr"`
+	DisplayNameKana string   `xml:"DisplayNameKana,attr"`
+	Members         []groupMemberElement
+}
+
+/*
+	groupElement constructor that returns a new groupElement when given a
+	valid Group, id and the contact Ids of the group's members.
+*/
+
+func newGroupElement(id int64, g *db.Group, contactIds []int64) (*groupElement, error) {
+	if g == nil {
+		return nil, ErrCannotCreateElement
+	}
+
+	p := new(groupElement)
+	p.Id = id
+	p.Type = "Group"
+	p.DisplayName = g.Name
+	p.DisplayNameKana = g.Name
+
+	for _, cid := range contactIds {
+		p.Members = append(p.Members, groupMemberElement{
+			ContactId:   cid,
+			AddressType: "EMAIL",
+		})
+	}
+
+	return p, nil
+}
+
 /*
 	AddressBookExport abstracts the data that will be stored in the XML address
 	book file.
 
-	XMLName: name of the XML element
+	XMLName: name of the XML element, set by the Schema that is exported
 	ContactComment: xml comment describing contact list
 	ContactList: slice of contactElements
+	GroupComment: xml comment describing group list
+	GroupList: slice of groupElements
 	EmailComment: xml comment describing email one touch key list
 	EmailOTK: slice of oneTouchKeyElements
+	SMBComment: xml comment describing smb one touch key list
+	SMBOTK: slice of oneTouchKeyElements for contacts with a SMB destination
+	FTPComment: xml comment describing ftp one touch key list
+	FTPOTK: slice of oneTouchKeyElements for contacts with a FTP destination
+	GroupOTKComment: xml comment describing group one touch key list
+	GroupOTK: slice of oneTouchKeyElements for groups
 */
 
 type AddressBookExport struct {
-	XMLName        xml.Name `xml:"DeviceAddressBook_v5_2"`
-	ContactComment string   `xml:",comment"`
+	XMLName        xml.Name
+	ContactComment string `xml:",comment"`
 	ContactList    []contactElement
+	GroupComment   string `xml:",comment"`
+	GroupList      []groupElement
 	EmailComment   string `xml:",comment"`
 	EmailOTK       []oneTouchKeyElement
+	SMBComment     string `xml:",comment"`
+	SMBOTK         []oneTouchKeyElement
+	FTPComment     string `xml:",comment"`
+	FTPOTK         []oneTouchKeyElement
+
+	GroupOTKComment string `xml:",comment"`
+	GroupOTK        []oneTouchKeyElement
+}
+
+/*
+	Returns the slot of each of the entries' OneTouchKeys. Entries that have
+	not been given a slot are given the lowest slot that is not taken or
+	reserved, in the order of entries.
+*/
+
+func keySlots(entries []*db.Entry, reserved []int64) []int64 {
+	used := make(map[int64]bool)
+	for _, slot := range reserved {
+		used[slot] = true
+	}
+
+	for _, e := range entries {
+		used[e.Slot] = true
+	}
+
+	slots := make([]int64, len(entries))
+	next := int64(1)
+	for i, e := range entries {
+		slots[i] = e.Slot
+		if e.Slot != 0 {
+			continue
+		}
+
+		for used[next] {
+			next++
+		}
+
+		slots[i] = next
+		used[next] = true
+	}
+
+	return slots
+}
+
+/*
+	Returns the slot of the OneTouchKey of a destination of each of the
+	entries, db.NoSlot for entries without one. dest reports whether an Entry
+	has the destination and the slot stored for it. Destinations without a
+	stored slot are given the slots returned by next in the order of their
+	entries' slots, order, and have no key if their Entry has none.
+*/
+
+func destinationSlots(entries []*db.Entry, order []int, next func() int64,
+	dest func(e *db.Entry) (bool, int64)) []int64 {
+	slots := make([]int64, len(entries))
+	for i, e := range entries {
+		slots[i] = db.NoSlot
+		if set, slot := dest(e); set && slot != 0 {
+			slots[i] = slot
+		}
+	}
+
+	for _, i := range order {
+		if set, slot := dest(entries[i]); set && slot == 0 {
+			slots[i] = next()
+		}
+	}
+
+	return slots
+}
+
+/*
+	Returns the indexes of the slots that are not db.NoSlot, in slot order.
+*/
+
+func keyOrder(slots []int64) []int {
+	var order []int
+	for i, slot := range slots {
+		if slot != db.NoSlot {
+			order = append(order, i)
+		}
+	}
+
+	sort.Slice(order, func(a, b int) bool {
+		return slots[order[a]] < slots[order[b]]
+	})
+
+	return order
+}
+
+/*
+	Returns the contact Id of each of the entries, their address book number.
+	Entries that have not been given a number are numbered after the highest
+	number, in the order of entries.
+
+	Returns an error wrapping db.ErrNumberCollision if a number is given to
+	more than one entry
+*/
+
+func contactNumbers(entries []*db.Entry) ([]int64, error) {
+	usernames := make(map[int64]string)
+	highest := int64(0)
+	for _, e := range entries {
+		if e.Number == 0 {
+			continue
+		}
+
+		if u, ok := usernames[e.Number]; ok {
+			return nil, fmt.Errorf("%w: number %d %v, %v",
+				db.ErrNumberCollision, e.Number, u, e.Username)
+		}
+
+		usernames[e.Number] = e.Username
+		if e.Number > highest {
+			highest = e.Number
+		}
+	}
+
+	numbers := make([]int64, len(entries))
+	for i, e := range entries {
+		numbers[i] = e.Number
+		if e.Number == 0 {
+			highest++
+			numbers[i] = highest
+		}
+	}
+
+	return numbers, nil
+}
+
+/*
+	Returns the Id of each of the groups, their stored number. Groups that
+	have not been given a number are numbered after the highest number, in
+	the order of groups.
+
+	Returns an error wrapping db.ErrNumberCollision if a number is given to
+	more than one group
+*/
+
+func groupNumbers(groups []*db.Group) ([]int64, error) {
+	names := make(map[int64]string)
+	highest := int64(0)
+	for _, g := range groups {
+		if g.Number == 0 {
+			continue
+		}
+
+		if name, ok := names[g.Number]; ok {
+			return nil, fmt.Errorf("%w: group %d %v, %v",
+				db.ErrNumberCollision, g.Number, name, g.Name)
+		}
+
+		names[g.Number] = g.Name
+		if g.Number > highest {
+			highest = g.Number
+		}
+	}
+
+	numbers := make([]int64, len(groups))
+	for i, g := range groups {
+		numbers[i] = g.Number
+		if g.Number == 0 {
+			highest++
+			numbers[i] = highest
+		}
+	}
+
+	return numbers, nil
 }
 
 /*
 	A function that will return XML struct when given a list of db.Entry
-	pointers. More OTKs type can be exported you would have to change the
-	ID of the newOneTouchkeyElement to be len(entries) + i + 1 and so on.
+	pointers. Contacts and groups are given their stored number as their Id
+	so that they keep it when others are removed. Email OTKs are numbered by
+	the slot of their entry and entries with db.NoSlot are not given any
+	OTKs. The SMB and FTP OTKs of entries and the group OTKs are numbered by
+	their stored slots. Those without one are numbered after the highest
+	slot, including reserved slots, the SMB OTKs first in the order of their
+	entries' slots, then the FTP OTKs and the group OTKs.
+
+	Returns an error wrapping db.ErrSlotCollision if a slot is given to more
+	than one OTK or is reserved, or db.ErrNumberCollision if a number is
+	given to more than one entry or group.
 
 	entries: a slice of db.Entry references
+	groups: a slice of db.Group references whose members are in entries
+	reserved: slots that are left without an OTK
+	schema: version of the address book format, nil exports the DefaultSchema
 */
 
-func ExportAddressBook(entries []*db.Entry) (*AddressBookExport, error) {
+func ExportAddressBook(entries []*db.Entry, groups []*db.Group, reserved []int64,
+	schema *Schema) (*AddressBookExport, error) {
+	if schema == nil {
+		var err error
+		schema, err = ParseSchema(DefaultSchema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	collisions := db.SlotCollisions(entries, groups, reserved)
+	if len(collisions) > 0 {
+		c := collisions[0]
+		return nil, fmt.Errorf("%w: slot %d %v", db.ErrSlotCollision, c.Slot,
+			strings.Join(c.Usernames, ", "))
+	}
+
 	contacts := []contactElement{}
 	emailOTK := []oneTouchKeyElement{}
+	smbOTK := []oneTouchKeyElement{}
+	ftpOTK := []oneTouchKeyElement{}
+
+	numbers, err := contactNumbers(entries)
+	if err != nil {
+		return nil, err
+	}
 
 	for i, e := range entries {
-		ce, err := newContactElement(int64(i+1), e)
+		ce, err := newContactElement(numbers[i], e)
 		if err != nil {
 			return nil, err
 		}
 
+		schema.apply(ce)
+
 		contacts = append(contacts, *ce)
+	}
 
-		eotk, err := newOneTouchKeyElement(int64(i+1), ce.Id, ce.DisplayName,
-			"EMAIL")
+	slots := keySlots(entries, reserved)
+	var order []int
+	last := int64(0)
+	for i, slot := range slots {
+		if slot != db.NoSlot {
+			order = append(order, i)
+		}
+
+		for _, s := range []int64{slot, entries[i].SMBSlot, entries[i].FTPSlot} {
+			if s > last {
+				last = s
+			}
+		}
+	}
+
+	for _, g := range groups {
+		if g.Slot > last {
+			last = g.Slot
+		}
+	}
+
+	for _, slot := range reserved {
+		if slot > last {
+			last = slot
+		}
+	}
+
+	next := func() int64 {
+		last++
+		return last
+	}
+
+	sort.Slice(order, func(a, b int) bool {
+		return slots[order[a]] < slots[order[b]]
+	})
+
+	for _, i := range order {
+		eotk, err := newOneTouchKeyElement(slots[i], contacts[i].Id,
+			contacts[i].DisplayName, "EMAIL")
 
 		if err != nil {
 			return nil, err
@@ -215,12 +580,105 @@ func ExportAddressBook(entries []*db.Entry) (*AddressBookExport, error) {
 		emailOTK = append(emailOTK, *eotk)
 	}
 
-	return &AddressBookExport{
+	smbSlots := destinationSlots(entries, order, next,
+		func(e *db.Entry) (bool, int64) { return e.SMB.IsSet(), e.SMBSlot })
+	ftpSlots := destinationSlots(entries, order, next,
+		func(e *db.Entry) (bool, int64) { return e.FTP.IsSet(), e.FTPSlot })
+
+	for _, i := range keyOrder(smbSlots) {
+		sotk, err := newOneTouchKeyElement(smbSlots[i], contacts[i].Id,
+			contacts[i].DisplayName, "SMB")
+		if err != nil {
+			return nil, err
+		}
+
+		smbOTK = append(smbOTK, *sotk)
+	}
+
+	for _, i := range keyOrder(ftpSlots) {
+		fotk, err := newOneTouchKeyElement(ftpSlots[i], contacts[i].Id,
+			contacts[i].DisplayName, "FTP")
+		if err != nil {
+			return nil, err
+		}
+
+		ftpOTK = append(ftpOTK, *fotk)
+	}
+
+	contactIds := make(map[int64]int64)
+	for i, e := range entries {
+		contactIds[e.ID] = contacts[i].Id
+	}
+
+	groupList := []groupElement{}
+	groupOTK := []oneTouchKeyElement{}
+	groupIds, err := groupNumbers(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, g := range groups {
+		var members []int64
+		for _, m := range g.Members {
+			if cid, ok := contactIds[m]; ok {
+				members = append(members, cid)
+			}
+		}
+
+		ge, err := newGroupElement(groupIds[i], g, members)
+		if err != nil {
+			return nil, err
+		}
+
+		groupList = append(groupList, *ge)
+
+		slot := g.Slot
+		if slot == 0 {
+			slot = next()
+		}
+
+		if slot == db.NoSlot {
+			continue
+		}
+
+		gotk, err := newOneTouchKeyElement(slot, ge.Id, ge.DisplayName, "GROUP")
+		if err != nil {
+			return nil, err
+		}
+
+		groupOTK = append(groupOTK, *gotk)
+	}
+
+	sort.SliceStable(groupOTK, func(a, b int) bool {
+		return groupOTK[a].Id < groupOTK[b].Id
+	})
+
+	book := &AddressBookExport{
+		XMLName:        xml.Name{Local: schema.rootElement()},
 		ContactComment: "Contact List",
 		ContactList:    contacts,
+		GroupList:      groupList,
 		EmailComment:   "Email One Touch Keys",
 		EmailOTK:       emailOTK,
-	}, nil
+		SMBOTK:         smbOTK,
+		FTPOTK:         ftpOTK,
+		GroupOTK:       groupOTK,
+	}
+
+	if len(groupList) > 0 {
+		book.GroupComment = "Group List"
+		book.GroupOTKComment = "Group One Touch Keys"
+	}
+
+	if len(smbOTK) > 0 {
+		book.SMBComment = "SMB One Touch Keys"
+	}
+
+	if len(ftpOTK) > 0 {
+		book.FTPComment = "FTP One Touch Keys"
+	}
+
+	return book, nil
 }
 
 /*
@@ -0,0 +1,374 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+/*
+	Flag to rewrite the golden files in testdata with the current output,
+	ie go test ./exporter -update
+*/
+
+var update = flag.Bool("update", false, "update the golden files")
+
+/*
+	Entries and groups exported by the golden file tests, covering every kind
+	of OneTouchKey.
+*/
+
+var (
+	goldenEntries = []*db.Entry{
+		{ID: 1, Name: "Jane Doe", Username: "jdoe", Email: "jdoe@email.com",
+			Number: 1, Slot: 2,
+			SMB: db.SMBDestination{Host: "fileserver", Path: `scans\jdoe`,
+				Login: `CORP\jdoe`, Password: "secret"},
+			Fax: db.FaxDestination{Number: "5550100", EncryptionKey: 3,
+				Encryption: "On"}},
+		{ID: 3, Name: "John Smith", Username: "jsmith", Email: "jsmith@email.com",
+			Number: 3, Slot: 1,
+			FTP: db.FTPDestination{Host: "ftpserver", Path: "/scans/jsmith",
+				Port: 2121},
+			Fax: db.FaxDestination{IFaxAddress: "ifax@email.com"}},
+		{ID: 4, Name: "No Key", Username: "nokey", Email: "nokey@email.com",
+			Number: 4, Slot: db.NoSlot},
+	}
+
+	goldenGroups = []*db.Group{{ID: 1, Name: "Sales", Members: []int64{1, 3}}}
+)
+
+func TestExportSchemas(t *testing.T) {
+	for _, schema := range Schemas {
+		schema := schema
+		t.Run(schema.Version, func(t *testing.T) {
+			t.Parallel()
+
+			book, err := ExportAddressBook(goldenEntries, goldenGroups,
+				[]int64{5}, schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ElementToString(book)
+			golden := filepath.Join("testdata", schema.Version+".xml")
+			if *update {
+				err = ioutil.WriteFile(golden, []byte(got), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(expected) {
+				t.Fatalf("got: %v, expected: %v", got, string(expected))
+			}
+		})
+	}
+}
+
+/*
+	Function that returns the name of the root element of an address book and
+	the names of the attributes of its first contact, in order.
+*/
+
+func contactAttributes(t *testing.T, data []byte) (string, []string) {
+	t.Helper()
+
+	var root string
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			t.Fatalf("got: %v, expected: %v", root, "an address book with a contact")
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if root == "" {
+			root = start.Name.Local
+			continue
+		}
+
+		var names []string
+		contact := false
+		for _, attr := range start.Attr {
+			names = append(names, attr.Name.Local)
+			if attr.Name.Local == "Type" && attr.Value == "Contact" {
+				contact = true
+			}
+		}
+
+		if contact {
+			return root, names
+		}
+	}
+}
+
+/*
+	Compares the contacts of the address books exported from devices in
+	testdata/device, named after their schema version ie v4_1.xml, with the
+	contacts exported in their schema version. Only the names and order of
+	the attributes are compared so the exports can be anonymized.
+*/
+
+func TestDeviceExports(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "device", "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) == 0 {
+		t.Skip("no address books exported from a device in testdata/device")
+	}
+
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			root, expected := contactAttributes(t, data)
+			schema, err := ParseSchema(strings.TrimPrefix(root, "DeviceAddressBook_"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			book, err := ExportAddressBook(goldenEntries, nil, nil, schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, got := contactAttributes(t, []byte(ElementToString(book)))
+			if !reflect.DeepEqual(got, expected) {
+				t.Fatalf("got: %v, expected: %v", got, expected)
+			}
+		})
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		expected    string
+		err         error
+	}{
+		{description: "default schema", input: "", expected: DefaultSchema},
+		{description: "older schema", input: "V4_1", expected: "v4_1"},
+		{description: "unknown schema", input: "v9_9", err: ErrUnknownSchema},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseSchema(tc.input)
+			if err != tc.err {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if err == nil && got.Version != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got.Version, tc.expected)
+			}
+		})
+	}
+}
+
+/*
+	Function that exports the current table of a repository and returns the
+	contact Ids keyed by email.
+*/
+
+func contactIds(t *testing.T, repo db.AddressBookRepository) map[string]int64 {
+	t.Helper()
+
+	entries, err := repo.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := ExportAddressBook(entries, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[string]int64)
+	for _, c := range book.ContactList {
+		ids[c.MailAddress] = c.Id
+	}
+
+	return ids
+}
+
+func TestStableContactIds(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	before := contactIds(t, repo)
+	expected := map[string]int64{"test1@test.com": 1, "test2@test.com": 2,
+		"test3@test.com": 3}
+	if !reflect.DeepEqual(before, expected) {
+		t.Fatalf("got: %v, expected: %v", before, expected)
+	}
+
+	err := repo.Delete("username2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Insert(db.Entry{Name: "Test Four", Username: "username4",
+		Email: "test4@test.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deleting username2 leaves the other Ids untouched and its Id is not
+	// given to the next user
+	expected = map[string]int64{"test1@test.com": 1, "test3@test.com": 3,
+		"test4@test.com": 4}
+	if got := contactIds(t, repo); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+
+	_, err = repo.CompactNumbers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = map[string]int64{"test1@test.com": 1, "test3@test.com": 2,
+		"test4@test.com": 3}
+	if got := contactIds(t, repo); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+}
+
+func TestContactNumbers(t *testing.T) {
+	tt := []struct {
+		description string
+		entries     []*db.Entry
+		expected    []int64
+		err         error
+	}{
+		{
+			description: "entries without numbers are numbered in order",
+			entries: []*db.Entry{{Username: "a"}, {Username: "b", Number: 5},
+				{Username: "c"}},
+			expected: []int64{6, 5, 7},
+		},
+		{
+			description: "number given to two entries",
+			entries: []*db.Entry{{Username: "a", Number: 2},
+				{Username: "b", Number: 2}},
+			err: db.ErrNumberCollision,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := contactNumbers(tc.entries)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestStoredKeySlots(t *testing.T) {
+	jdoe := *goldenEntries[0]
+	jdoe.SMBSlot = 9
+	jsmith := *goldenEntries[1]
+	entries := []*db.Entry{&jdoe, &jsmith}
+	groups := []*db.Group{{ID: 1, Name: "Sales", Slot: 7, Members: []int64{1}}}
+
+	book, err := ExportAddressBook(entries, groups, []int64{5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys without a stored slot are numbered after the highest slot
+	got := []int64{book.SMBOTK[0].Id, book.FTPOTK[0].Id, book.GroupOTK[0].Id}
+	expected := []int64{9, 10, 7}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %v, expected: %v", got, expected)
+	}
+
+	groups[0].Slot = 9
+	_, err = ExportAddressBook(entries, groups, nil, nil)
+	if !errors.Is(err, db.ErrSlotCollision) {
+		t.Fatalf("got: %v, expected: %v", err, db.ErrSlotCollision)
+	}
+}
+
+func TestGroupNumbers(t *testing.T) {
+	tt := []struct {
+		description string
+		groups      []*db.Group
+		expected    []int64
+		err         error
+	}{
+		{
+			description: "groups keep their numbers when others are removed",
+			groups:      []*db.Group{{Name: "Support", Number: 2}},
+			expected:    []int64{2},
+		},
+		{
+			description: "groups without numbers are numbered in order",
+			groups: []*db.Group{{Name: "a"}, {Name: "b", Number: 5},
+				{Name: "c"}},
+			expected: []int64{6, 5, 7},
+		},
+		{
+			description: "number given to two groups",
+			groups: []*db.Group{{Name: "a", Number: 2},
+				{Name: "b", Number: 2}},
+			err: db.ErrNumberCollision,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := groupNumbers(tc.groups)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
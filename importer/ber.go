@@ -0,0 +1,241 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrInvalidBER = errors.New("ldap message is not valid ber")
+
+/*
+	Tags of the universal BER types used by LDAP messages.
+*/
+
+const (
+	berBoolean     = 0x01
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+	berSet         = 0x31
+)
+
+/*
+	Largest element that is read from a server, so that a bad length does not
+	allocate the memory of the machine.
+*/
+
+const berMaxLength = 16 << 20
+
+/*
+	berElement is a BER encoded value with its tag, the value of an element
+	that is constructed is the encoding of its children.
+*/
+
+type berElement struct {
+	Tag   byte
+	Value []byte
+}
+
+/*
+	Returns the length octets of a value of n bytes, in the short form below
+	128 and the long form otherwise.
+*/
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+/*
+	Encodes a value with its tag and length.
+*/
+
+func berEncode(tag byte, value []byte) []byte {
+	b := append([]byte{tag}, berLength(len(value))...)
+	return append(b, value...)
+}
+
+/*
+	Encodes an element made of other encoded elements, ie a SEQUENCE.
+*/
+
+func berConstructed(tag byte, children ...[]byte) []byte {
+	var value []byte
+	for _, c := range children {
+		value = append(value, c...)
+	}
+
+	return berEncode(tag, value)
+}
+
+/*
+	Encodes an INTEGER or ENUMERATED in the fewest bytes of two's
+	complement.
+*/
+
+func berInt(tag byte, n int) []byte {
+	var b []byte
+	for {
+		b = append([]byte{byte(n)}, b...)
+		if (n < 0x80 && n >= -0x80) || len(b) == 8 {
+			break
+		}
+
+		n >>= 8
+	}
+
+	return berEncode(tag, b)
+}
+
+/*
+	Encodes an OCTET STRING or a string with a context tag.
+*/
+
+func berString(tag byte, s string) []byte {
+	return berEncode(tag, []byte(s))
+}
+
+/*
+	Encodes a BOOLEAN.
+*/
+
+func berBool(tag byte, v bool) []byte {
+	if v {
+		return berEncode(tag, []byte{0xff})
+	}
+
+	return berEncode(tag, []byte{0x00})
+}
+
+/*
+	Reads the length octets of an element from r.
+
+	Returns an ErrInvalidBER if the length is indefinite or too long
+*/
+
+func readBERLength(r io.ByteReader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if b < 0x80 {
+		return int(b), nil
+	}
+
+	octets := int(b & 0x7f)
+	if octets == 0 || octets > 4 {
+		return 0, fmt.Errorf("%w: length of %d octets", ErrInvalidBER, octets)
+	}
+
+	n := 0
+	for i := 0; i < octets; i++ {
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		n = n<<8 | int(b)
+	}
+
+	if n > berMaxLength {
+		return 0, fmt.Errorf("%w: length of %d bytes", ErrInvalidBER, n)
+	}
+
+	return n, nil
+}
+
+/*
+	berReader is what an element can be read from, a connection or the value
+	of another element.
+*/
+
+type berReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+/*
+	Reads the next element from r. Only the tags of LDAP, which fit in one
+	byte, are supported.
+*/
+
+func readBER(r berReader) (berElement, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+
+	if tag&0x1f == 0x1f {
+		return berElement{}, fmt.Errorf("%w: tag %#x", ErrInvalidBER, tag)
+	}
+
+	n, err := readBERLength(r)
+	if err != nil {
+		return berElement{}, err
+	}
+
+	value := make([]byte, n)
+	_, err = io.ReadFull(r, value)
+	if err != nil {
+		return berElement{}, err
+	}
+
+	return berElement{Tag: tag, Value: value}, nil
+}
+
+/*
+	Decodes the children of a constructed element.
+*/
+
+func (e berElement) children() ([]berElement, error) {
+	var children []berElement
+	r := bytes.NewReader(e.Value)
+	for r.Len() > 0 {
+		c, err := readBER(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBER, err)
+		}
+
+		children = append(children, c)
+	}
+
+	return children, nil
+}
+
+/*
+	Decodes the value of an INTEGER or ENUMERATED.
+*/
+
+func (e berElement) int() (int, error) {
+	if len(e.Value) == 0 || len(e.Value) > 8 {
+		return 0, fmt.Errorf("%w: integer of %d bytes", ErrInvalidBER,
+			len(e.Value))
+	}
+
+	n := int(int8(e.Value[0]))
+	for _, b := range e.Value[1:] {
+		n = n<<8 | int(b)
+	}
+
+	return n, nil
+}
+
+/*
+	Decodes the value of a BOOLEAN, any byte but 0 is true.
+*/
+
+func (e berElement) bool() bool {
+	return len(e.Value) > 0 && e.Value[0] != 0
+}
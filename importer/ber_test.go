@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBerInt(t *testing.T) {
+	tt := []struct {
+		input    int
+		expected []byte
+	}{
+		{input: 0, expected: []byte{0x02, 0x01, 0x00}},
+		{input: 127, expected: []byte{0x02, 0x01, 0x7f}},
+		{input: 128, expected: []byte{0x02, 0x02, 0x00, 0x80}},
+		{input: 500, expected: []byte{0x02, 0x02, 0x01, 0xf4}},
+		{input: -1, expected: []byte{0x02, 0x01, 0xff}},
+		{input: -129, expected: []byte{0x02, 0x02, 0xff, 0x7f}},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(fmt.Sprint(tc.input), func(t *testing.T) {
+			t.Parallel()
+
+			got := berInt(berInteger, tc.input)
+			if !bytes.Equal(got, tc.expected) {
+				t.Fatalf("got: %x, expected: %x", got, tc.expected)
+			}
+
+			e, err := readBER(bytes.NewReader(got))
+			if err != nil {
+				t.Fatalf("got: %v, expected: %v", err, nil)
+			}
+
+			n, err := e.int()
+			if err != nil || n != tc.input {
+				t.Fatalf("got: %v, expected: %v", n, tc.input)
+			}
+		})
+	}
+}
+
+func TestReadBER(t *testing.T) {
+	long := strings.Repeat("a", 300)
+
+	tt := []struct {
+		description string
+		input       []byte
+		expected    berElement
+		err         error
+	}{
+		{
+			description: "short length",
+			input:       berString(berOctetString, "mail"),
+			expected:    berElement{Tag: berOctetString, Value: []byte("mail")},
+		},
+		{
+			description: "long length",
+			input:       berString(berOctetString, long),
+			expected:    berElement{Tag: berOctetString, Value: []byte(long)},
+		},
+		{
+			description: "indefinite length",
+			input:       []byte{0x30, 0x80, 0x00, 0x00},
+			err:         ErrInvalidBER,
+		},
+		{
+			description: "length too long",
+			input:       []byte{0x04, 0x84, 0x7f, 0xff, 0xff, 0xff},
+			err:         ErrInvalidBER,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := readBER(bytes.NewReader(tc.input))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if tc.err == nil && !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestBerChildren(t *testing.T) {
+	seq := berConstructed(berSequence, berInt(berInteger, 1),
+		berString(berOctetString, "cn"), berBool(berBoolean, true))
+
+	e, err := readBER(bytes.NewReader(seq))
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+
+	children, err := e.children()
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+
+	if len(children) != 3 || string(children[1].Value) != "cn" ||
+		!children[2].bool() {
+		t.Fatalf("got: %v, expected: %v", children, "1, cn, true")
+	}
+
+	// the last child is cut short
+	e.Value = e.Value[:len(e.Value)-1]
+	_, err = e.children()
+	if !errors.Is(err, ErrInvalidBER) {
+		t.Fatalf("got: %v, expected: %v", err, ErrInvalidBER)
+	}
+}
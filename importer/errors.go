@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+	RowError is why a row of a CSV file could not be read or added. The
+	underlying error is one of the errors of this package or db, ie
+	db.ErrInvalidEmail, and can be checked with errors.Is.
+
+	Line: line number of the row in the file, the header is line 1
+	Field: field of the value that is not valid, empty if the error is with
+	the whole row
+	Column: name of the column of the field in the file
+	Value: the value that is not valid
+	Err: the underlying error
+*/
+
+type RowError struct {
+	Line   int
+	Field  string
+	Column string
+	Value  string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("line %d, column %v: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+/*
+	rowErrorJSON is how a RowError is written as JSON, with the underlying
+	error as the rule that was broken.
+*/
+
+type rowErrorJSON struct {
+	Line   int    `json:"line"`
+	Field  string `json:"field,omitempty"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Rule   string `json:"rule"`
+}
+
+func (e *RowError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rowErrorJSON{Line: e.Line, Field: e.Field,
+		Column: e.Column, Value: e.Value, Rule: e.Err.Error()})
+}
+
+func (e *RowError) UnmarshalJSON(b []byte) error {
+	var v rowErrorJSON
+	err := json.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+
+	*e = RowError{Line: v.Line, Field: v.Field, Column: v.Column,
+		Value: v.Value, Err: errors.New(v.Rule)}
+
+	return nil
+}
+
+/*
+	RowErrors is the errors of every row of a CSV file that is not valid, in
+	the order of the file. errors.Is reports whether any of them is the
+	target.
+*/
+
+type RowErrors []*RowError
+
+func (e RowErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+
+	return strings.Join(s, "; ")
+}
+
+func (e RowErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
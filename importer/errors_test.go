@@ -0,0 +1,107 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+func TestRowError(t *testing.T) {
+	tt := []struct {
+		description string
+		err         *RowError
+		expected    string
+	}{
+		{
+			description: "error of a value",
+			err: &RowError{Line: 3, Field: "email", Column: "mail",
+				Value: "jane.email.com", Err: db.ErrInvalidEmail},
+			expected: "line 3, column mail: email is not valid",
+		},
+		{
+			description: "error of a row",
+			err:         &RowError{Line: 4, Err: ErrInvalidRowLength},
+			expected:    "line 4: invalid row length",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.err.Error() != tc.expected {
+				t.Fatalf("got: %v, expected: %v", tc.err.Error(), tc.expected)
+			}
+
+			if !errors.Is(tc.err, tc.err.Err) {
+				t.Fatalf("got: %v, expected: %v", tc.err, tc.err.Err)
+			}
+		})
+	}
+
+	t.Run("json has the rule", func(t *testing.T) {
+		err := &RowError{Line: 3, Field: "email", Column: "mail",
+			Value: "jane.email.com", Err: db.ErrInvalidEmail}
+
+		b, jsonErr := json.Marshal(err)
+		if jsonErr != nil {
+			t.Fatal(jsonErr)
+		}
+
+		expected := `{"line":3,"field":"email","column":"mail",` +
+			`"value":"jane.email.com","rule":"email is not valid"}`
+		if string(b) != expected {
+			t.Fatalf("got: %v, expected: %v", string(b), expected)
+		}
+
+		var got RowError
+		jsonErr = json.Unmarshal(b, &got)
+		if jsonErr != nil {
+			t.Fatal(jsonErr)
+		}
+
+		if !reflect.DeepEqual(&got, err) {
+			t.Fatalf("got: %+v, expected: %+v", got, err)
+		}
+	})
+}
+
+func TestRowErrors(t *testing.T) {
+	errs := RowErrors{
+		{Line: 2, Field: "email", Column: "email", Value: "jane.email.com",
+			Err: db.ErrInvalidEmail},
+		{Line: 4, Err: ErrInvalidRowLength},
+	}
+
+	expected := "line 2, column email: email is not valid; " +
+		"line 4: invalid row length"
+	if errs.Error() != expected {
+		t.Fatalf("got: %v, expected: %v", errs.Error(), expected)
+	}
+
+	tt := []struct {
+		description string
+		target      error
+		expected    bool
+	}{
+		{description: "first error", target: db.ErrInvalidEmail, expected: true},
+		{description: "last error", target: ErrInvalidRowLength, expected: true},
+		{description: "other error", target: db.ErrInvalidName, expected: false},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var err error = errs
+			if errors.Is(err, tc.target) != tc.expected {
+				t.Fatalf("got: %v, expected: %v", !tc.expected, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package importer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidFilter = errors.New("ldap filter is not valid")
+
+/*
+	Context tags of the choices of an LDAP search filter.
+*/
+
+const (
+	filterAnd        = 0xa0
+	filterOr         = 0xa1
+	filterNot        = 0xa2
+	filterEquality   = 0xa3
+	filterSubstrings = 0xa4
+	filterGreater    = 0xa5
+	filterLess       = 0xa6
+	filterPresent    = 0x87
+	filterApprox     = 0xa8
+	filterExtensible = 0xa9
+)
+
+/*
+	Parses a search filter in the string form of RFC 4515, ie
+	'(&(objectClass=user)(mail=*))', and returns its BER encoding. The outer
+	parentheses can be left out of a filter of a single item.
+
+	Returns an ErrInvalidFilter if the filter cannot be parsed
+*/
+
+func parseFilter(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") {
+		s = "(" + s + ")"
+	}
+
+	b, rest, err := parseFilterComp(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if rest != "" {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidFilter, rest)
+	}
+
+	return b, nil
+}
+
+/*
+	Parses the parenthesized filter at the start of s and returns its
+	encoding with the rest of s.
+*/
+
+func parseFilterComp(s string) ([]byte, string, error) {
+	if !strings.HasPrefix(s, "(") {
+		return nil, "", fmt.Errorf("%w: expected ( at %q", ErrInvalidFilter, s)
+	}
+
+	s = s[1:]
+	if s == "" {
+		return nil, "", fmt.Errorf("%w: missing )", ErrInvalidFilter)
+	}
+
+	var b []byte
+	var err error
+	switch s[0] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+
+		var children [][]byte
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			var c []byte
+			c, s, err = parseFilterComp(s)
+			if err != nil {
+				return nil, "", err
+			}
+
+			children = append(children, c)
+		}
+
+		b = berConstructed(tag, children...)
+	case '!':
+		var c []byte
+		c, s, err = parseFilterComp(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+
+		b = berConstructed(filterNot, c)
+	default:
+		i := strings.Index(s, ")")
+		if i < 0 {
+			return nil, "", fmt.Errorf("%w: missing )", ErrInvalidFilter)
+		}
+
+		b, err = parseFilterItem(s[:i])
+		if err != nil {
+			return nil, "", err
+		}
+
+		s = s[i:]
+	}
+
+	if !strings.HasPrefix(s, ")") {
+		return nil, "", fmt.Errorf("%w: missing )", ErrInvalidFilter)
+	}
+
+	return b, s[1:], nil
+}
+
+/*
+	Parses a filter item, ie 'mail=*' or 'cn>=b', and returns its encoding.
+*/
+
+func parseFilterItem(s string) ([]byte, error) {
+	i := strings.Index(s, "=")
+	if i < 1 {
+		return nil, fmt.Errorf("%w: %q is not an item", ErrInvalidFilter, s)
+	}
+
+	attr, value := s[:i], s[i+1:]
+	tag := byte(filterEquality)
+	switch attr[len(attr)-1] {
+	case '~':
+		tag = filterApprox
+	case '>':
+		tag = filterGreater
+	case '<':
+		tag = filterLess
+	case ':':
+		return parseExtensible(attr[:len(attr)-1], value)
+	}
+
+	if tag != filterEquality {
+		attr = attr[:len(attr)-1]
+	}
+
+	if !validAttribute(attr) {
+		return nil, fmt.Errorf("%w: %q is not an attribute", ErrInvalidFilter,
+			attr)
+	}
+
+	if tag == filterEquality && value == "*" {
+		return berString(filterPresent, attr), nil
+	}
+
+	if tag == filterEquality && strings.Contains(value, "*") {
+		return parseSubstrings(attr, value)
+	}
+
+	v, err := unescapeFilterValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return berConstructed(tag, berString(berOctetString, attr),
+		berString(berOctetString, v)), nil
+}
+
+/*
+	Encodes a substrings item, ie 'cn=J*n*Doe', with the parts before the
+	first *, between the others and after the last.
+*/
+
+func parseSubstrings(attr, value string) ([]byte, error) {
+	parts := strings.Split(value, "*")
+
+	var subs [][]byte
+	for i, p := range parts {
+		if p == "" {
+			continue
+		}
+
+		v, err := unescapeFilterValue(p)
+		if err != nil {
+			return nil, err
+		}
+
+		tag := byte(0x81)
+		switch i {
+		case 0:
+			tag = 0x80
+		case len(parts) - 1:
+			tag = 0x82
+		}
+
+		subs = append(subs, berString(tag, v))
+	}
+
+	return berConstructed(filterSubstrings, berString(berOctetString, attr),
+		berConstructed(berSequence, subs...)), nil
+}
+
+/*
+	Encodes an extensible match item, ie the
+	'userAccountControl:1.2.840.113556.1.4.803:' part of an Active Directory
+	filter for disabled accounts, given the part before := and the value.
+*/
+
+func parseExtensible(left, value string) ([]byte, error) {
+	parts := strings.Split(left, ":")
+	attr, rest := parts[0], parts[1:]
+
+	dn := false
+	if len(rest) > 0 && strings.EqualFold(rest[0], "dn") {
+		dn, rest = true, rest[1:]
+	}
+
+	rule := ""
+	if len(rest) == 1 {
+		rule, rest = rest[0], rest[1:]
+	}
+
+	if len(rest) > 0 || (attr == "" && rule == "") ||
+		(attr != "" && !validAttribute(attr)) {
+		return nil, fmt.Errorf("%w: %q is not an extensible match",
+			ErrInvalidFilter, left+":="+value)
+	}
+
+	v, err := unescapeFilterValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var children [][]byte
+	if rule != "" {
+		children = append(children, berString(0x81, rule))
+	}
+
+	if attr != "" {
+		children = append(children, berString(0x82, attr))
+	}
+
+	children = append(children, berString(0x83, v))
+	if dn {
+		children = append(children, berBool(0x84, true))
+	}
+
+	return berConstructed(filterExtensible, children...), nil
+}
+
+/*
+	Reports whether s is an attribute description, a name or an OID with
+	options, ie 'mail' or 'cn;lang-en'.
+*/
+
+func validAttribute(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '-', c == '.', c == ';':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	Removes the escapes of a filter value, where a backslash is followed by
+	the two hex digits of a byte, ie \2a for *.
+*/
+
+func unescapeFilterValue(s string) (string, error) {
+	if strings.ContainsAny(s, "()") {
+		return "", fmt.Errorf("%w: %q must be escaped", ErrInvalidFilter, s)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("%w: %q has a bad escape", ErrInvalidFilter, s)
+		}
+
+		v, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("%w: %q has a bad escape", ErrInvalidFilter, s)
+		}
+
+		b.Write(v)
+		i += 2
+	}
+
+	return b.String(), nil
+}
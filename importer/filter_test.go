@@ -0,0 +1,88 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	str := func(tag byte, s string) []byte { return berString(tag, s) }
+	seq := berConstructed
+
+	tt := []struct {
+		input    string
+		expected []byte
+		err      error
+	}{
+		{
+			input:    "(mail=*)",
+			expected: str(filterPresent, "mail"),
+		},
+		{
+			input: "cn=Jane Doe",
+			expected: seq(filterEquality, str(berOctetString, "cn"),
+				str(berOctetString, "Jane Doe")),
+		},
+		{
+			input: "(&(objectClass=user)(!(cn>=m)))",
+			expected: seq(filterAnd,
+				seq(filterEquality, str(berOctetString, "objectClass"),
+					str(berOctetString, "user")),
+				seq(filterNot, seq(filterGreater, str(berOctetString, "cn"),
+					str(berOctetString, "m")))),
+		},
+		{
+			input: "(|(sn~=doe)(sn<=b))",
+			expected: seq(filterOr,
+				seq(filterApprox, str(berOctetString, "sn"),
+					str(berOctetString, "doe")),
+				seq(filterLess, str(berOctetString, "sn"),
+					str(berOctetString, "b"))),
+		},
+		{
+			input: "(cn=J*n*Doe)",
+			expected: seq(filterSubstrings, str(berOctetString, "cn"),
+				seq(berSequence, str(0x80, "J"), str(0x81, "n"),
+					str(0x82, "Doe"))),
+		},
+		{
+			input: "(cn=*\\2a\\28x\\29)",
+			expected: seq(filterSubstrings, str(berOctetString, "cn"),
+				seq(berSequence, str(0x82, "*(x)"))),
+		},
+		{
+			input: "(userAccountControl:1.2.840.113556.1.4.803:=2)",
+			expected: seq(filterExtensible,
+				str(0x81, "1.2.840.113556.1.4.803"),
+				str(0x82, "userAccountControl"), str(0x83, "2")),
+		},
+		{
+			input: "(ou:dn:=Sales)",
+			expected: seq(filterExtensible, str(0x82, "ou"), str(0x83, "Sales"),
+				berBool(0x84, true)),
+		},
+		{input: "(&(mail=*)", err: ErrInvalidFilter},
+		{input: "(mail=*))", err: ErrInvalidFilter},
+		{input: "(=jane)", err: ErrInvalidFilter},
+		{input: "(cn=a\\zz)", err: ErrInvalidFilter},
+		{input: "(c n=jane)", err: ErrInvalidFilter},
+		{input: "(cn:a:b:=jane)", err: ErrInvalidFilter},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseFilter(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if !bytes.Equal(got, tc.expected) {
+				t.Fatalf("got: %x, expected: %x", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Fields of an Entry that every CSV file must have a column for.
+*/
+
+var requiredFields = []string{"name", "username", "email"}
+
+/*
+	Other names the columns of the required fields are given by exports, ie
+	from Active Directory or an HR system, in lower case.
+*/
+
+var columnAliases = map[string]string{
+	"display name":   "name",
+	"displayname":    "name",
+	"full name":      "name",
+	"fullname":       "name",
+	"cn":             "name",
+	"samaccountname": "username",
+	"user name":      "username",
+	"user":           "username",
+	"login":          "username",
+	"uid":            "username",
+	"mail":           "email",
+	"e-mail":         "email",
+	"email address":  "email",
+	"emailaddress":   "email",
+}
+
+/*
+	ColumnMapping maps the fields of an Entry to the names of the columns of
+	a CSV file they are read from, ie name to "Preferred Name".
+*/
+
+type ColumnMapping map[string]string
+
+/*
+	Parses a ColumnMapping given by the user as comma separated FIELD=COLUMN
+	pairs, ie 'name=Preferred Name,username=login'. Fields are the required
+	fields or db.DestinationFields.
+
+	Returns an ErrInvalidMapping if a pair is not valid
+*/
+
+func ParseMapping(s string) (ColumnMapping, error) {
+	m := make(ColumnMapping)
+	if strings.TrimSpace(s) == "" {
+		return m, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMapping, pair)
+		}
+
+		field := strings.ToLower(strings.TrimSpace(kv[0]))
+		column := strings.TrimSpace(kv[1])
+		if !isField(field) || column == "" {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMapping, pair)
+		}
+
+		m[field] = column
+	}
+
+	return m, nil
+}
+
+/*
+	Column is a column of a CSV file that is read into a field of an Entry.
+
+	Field: key of the field ie name or smb_host
+	Header: name of the column in the file
+	Index: position of the column in the file, from 0
+*/
+
+type Column struct {
+	Field  string `json:"field"`
+	Header string `json:"header"`
+	Index  int    `json:"index"`
+}
+
+/*
+	CSVHeader describes how the header of a CSV file was read.
+
+	Used: the columns that are read, in the order of the file
+	Ignored: names of the columns that are not read
+	width: number of columns every row must have
+*/
+
+type CSVHeader struct {
+	Used    []Column `json:"used"`
+	Ignored []string `json:"ignored,omitempty"`
+	width   int
+}
+
+/*
+	Returns the column of a field, nil if it is not read.
+*/
+
+func (h *CSVHeader) column(field string) *Column {
+	for i := range h.Used {
+		if h.Used[i].Field == field {
+			return &h.Used[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the keys of the fields that are read, in the order of the file.
+*/
+
+func (h *CSVHeader) Fields() []string {
+	fields := make([]string, 0, len(h.Used))
+	for _, c := range h.Used {
+		fields = append(fields, c.Field)
+	}
+
+	return fields
+}
+
+/*
+	Returns the position of the column of a field, -1 if it is not read.
+*/
+
+func (h *CSVHeader) index(field string) int {
+	c := h.column(field)
+	if c == nil {
+		return -1
+	}
+
+	return c.Index
+}
+
+/*
+	Reports whether s is the key of one of the fields a column can be read
+	into.
+*/
+
+func isField(s string) bool {
+	for _, f := range requiredFields {
+		if s == f {
+			return true
+		}
+	}
+
+	return isDestinationField(s)
+}
+
+/*
+	Returns the field a column is read into by its name, or an empty string
+	if the column is unknown. Columns are matched by the name of the field,
+	ignoring case and with spaces for underscores, or by one of the
+	columnAliases.
+*/
+
+func columnField(header string) string {
+	s := strings.ToLower(strings.TrimSpace(header))
+	if f, ok := columnAliases[s]; ok {
+		return f
+	}
+
+	s = strings.ReplaceAll(s, " ", "_")
+	if isField(s) {
+		return s
+	}
+
+	return ""
+}
+
+/*
+	Reads the first line of a CSV file to find the column of each field. The
+	columns can be in any order and columns that are unknown, or for a field
+	that already has a column, are ignored. The mapping takes precedence over
+	the names of the columns.
+
+	Returns an ErrInvalidHeader if there is no column for a required field or
+	a mapped column is not in the header
+*/
+
+func parseCSVHeader(header []string, mapping ColumnMapping) (*CSVHeader, error) {
+	// spreadsheet programs can start the file with a byte order mark
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	fields := make([]string, len(header))
+	mapped := make(map[string]bool)
+	for field, column := range mapping {
+		found := false
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), column) {
+				fields[i], found = field, true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("%w: mapped column %q is missing",
+				ErrInvalidHeader, column)
+		}
+
+		mapped[field] = true
+	}
+
+	h := &CSVHeader{width: len(header)}
+	for i, name := range header {
+		field := fields[i]
+		if field == "" {
+			field = columnField(name)
+		}
+
+		if field == "" || (fields[i] == "" && mapped[field]) ||
+			h.index(field) >= 0 {
+			if strings.TrimSpace(name) != "" {
+				h.Ignored = append(h.Ignored, name)
+			}
+
+			continue
+		}
+
+		h.Used = append(h.Used, Column{Field: field, Header: name, Index: i})
+	}
+
+	for _, f := range requiredFields {
+		if h.index(f) < 0 {
+			return nil, fmt.Errorf("%w: missing %v column", ErrInvalidHeader, f)
+		}
+	}
+
+	return h, nil
+}
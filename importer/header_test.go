@@ -0,0 +1,131 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMapping(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		expected    ColumnMapping
+		err         error
+	}{
+		{
+			description: "empty mapping",
+			input:       "",
+			expected:    ColumnMapping{},
+		},
+		{
+			description: "fields in any case",
+			input:       "Name=Preferred Name, username=login,smb_host=Server",
+			expected: ColumnMapping{"name": "Preferred Name",
+				"username": "login", "smb_host": "Server"},
+		},
+		{
+			description: "unknown field",
+			input:       "phone=Phone Number",
+			err:         ErrInvalidMapping,
+		},
+		{
+			description: "pair without a column",
+			input:       "name",
+			err:         ErrInvalidMapping,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseMapping(tc.input)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if tc.err == nil && !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseCSVHeader(t *testing.T) {
+	tt := []struct {
+		description string
+		header      []string
+		mapping     ColumnMapping
+		expected    *CSVHeader
+		err         error
+	}{
+		{
+			description: "header is valid",
+			header:      []string{"name", "username", "email"},
+			expected: &CSVHeader{Used: []Column{
+				{Field: "name", Header: "name", Index: 0},
+				{Field: "username", Header: "username", Index: 1},
+				{Field: "email", Header: "email", Index: 2},
+			}, width: 3},
+		},
+		{
+			description: "header has email first",
+			header:      []string{"Email", "name", "username"},
+			expected: &CSVHeader{Used: []Column{
+				{Field: "email", Header: "Email", Index: 0},
+				{Field: "name", Header: "name", Index: 1},
+				{Field: "username", Header: "username", Index: 2},
+			}, width: 3},
+		},
+		{
+			description: "header has aliases and unknown columns",
+			header: []string{"\ufeffDisplay Name", "Department", "mail",
+				"sAMAccountName", "SMB Host", "cn"},
+			expected: &CSVHeader{Used: []Column{
+				{Field: "name", Header: "Display Name", Index: 0},
+				{Field: "email", Header: "mail", Index: 2},
+				{Field: "username", Header: "sAMAccountName", Index: 3},
+				{Field: "smb_host", Header: "SMB Host", Index: 4},
+			}, Ignored: []string{"Department", "cn"}, width: 6},
+		},
+		{
+			description: "mapping takes precedence over names",
+			header:      []string{"name", "Preferred Name", "login", "email"},
+			mapping:     ColumnMapping{"name": "preferred name"},
+			expected: &CSVHeader{Used: []Column{
+				{Field: "name", Header: "Preferred Name", Index: 1},
+				{Field: "username", Header: "login", Index: 2},
+				{Field: "email", Header: "email", Index: 3},
+			}, Ignored: []string{"name"}, width: 4},
+		},
+		{
+			description: "header has an unrecognized field",
+			header:      []string{"doesn't belong", "username", "email"},
+			err:         ErrInvalidHeader,
+		},
+		{
+			description: "mapped column is missing",
+			header:      []string{"name", "username", "email"},
+			mapping:     ColumnMapping{"name": "Preferred Name"},
+			err:         ErrInvalidHeader,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseCSVHeader(tc.header, tc.mapping)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %+v, expected: %+v", got, tc.expected)
+			}
+		})
+	}
+}
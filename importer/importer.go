@@ -3,7 +3,6 @@ package importer
 import (
 	"encoding/csv"
 	"errors"
-	"fmt"
 	"io"
 	"strings"
 
@@ -11,85 +10,236 @@ import (
 )
 
 var (
-	ErrInvalidHeader       = errors.New("invalid header")
-	ErrInvalidHeaderLength = errors.New("invalid header length")
-	ErrInvalidRowLength    = errors.New("invalid row length")
-	ErrNoRowsInFile        = errors.New("there are no rows in this file")
+	ErrInvalidHeader    = errors.New("invalid header")
+	ErrInvalidRowLength = errors.New("invalid row length")
+	ErrNoRowsInFile     = errors.New("there are no rows in this file")
+	ErrInvalidMapping   = errors.New("column mapping must be FIELD=COLUMN pairs of known fields")
+	ErrDuplicateInFile  = errors.New("already in the file")
+	ErrAlreadyInTable   = errors.New("already in the table")
 )
 
 /*
-	Checks the first line of a CSV file to ensure it has the following header:
-	name,username,email.
+	Reports whether s is the key of one of the optional Entry fields.
 */
 
-func checkCSVHeader(header []string) error {
-	if len(header) != 3 {
-		return ErrInvalidHeaderLength
+func isDestinationField(s string) bool {
+	for _, f := range db.DestinationFields {
+		if s == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Checks every value of a row of a CSV file on its own. Returns the errors
+	of the values that are not valid, or of the row if it has the wrong
+	number of columns.
+*/
+
+func checkRow(header *CSVHeader, row []string, line int) RowErrors {
+	if len(row) != header.width {
+		return RowErrors{{Line: line, Err: ErrInvalidRowLength}}
 	}
 
-	valid := []string{"name", "username", "email"}
-	for i := range header {
-		if strings.ToLower(header[i]) != valid[i] {
-			return ErrInvalidHeader
+	var errs RowErrors
+	for _, c := range header.Used {
+		v := strings.TrimSpace(row[c.Index])
+		if v == "" && isDestinationField(c.Field) {
+			continue
+		}
+
+		err := db.CheckField(c.Field, v)
+		if err != nil {
+			errs = append(errs, &RowError{Line: line, Field: c.Field,
+				Column: c.Header, Value: v, Err: err})
 		}
 	}
 
-	return nil
+	return errs
 }
 
 /*
-	Convert a string slice from a CSV file into an  Entry.
+	Convert a string slice from a CSV file into an Entry. The header is used
+	to find the value of each field, empty values are left unset. The fields
+	are then checked together. Returns a RowError for the first value that
+	is not valid.
 */
 
-func csvToEntry(row []string) (*db.Entry, error) {
-	if len(row) != 3 {
-		return nil, ErrInvalidRowLength
+func csvToEntry(header *CSVHeader, row []string, line int) (*db.Entry, error) {
+	errs := checkRow(header, row, line)
+	if len(errs) > 0 {
+		return nil, errs[0]
 	}
 
-	e, err := db.NewEntry(row[0], row[1], row[2])
+	value := func(field string) string {
+		return strings.TrimSpace(row[header.index(field)])
+	}
 
+	e, err := db.NewEntry(value("name"), value("username"), value("email"))
 	if err != nil {
-		return nil, err
+		return nil, &RowError{Line: line, Err: err}
+	}
+
+	for _, c := range header.Used {
+		v := strings.TrimSpace(row[c.Index])
+		if isDestinationField(c.Field) && v != "" {
+			e.SetField(c.Field, v)
+		}
+	}
+
+	err = e.Validate()
+	if err != nil {
+		return nil, &RowError{Line: line, Err: err}
 	}
 
 	return e, nil
 }
 
 /*
-	Reads csv lines from an io.Reader and returns a slice of entries if they are
-	all valid, if not returns an error.
+	CSVRow is a row of a CSV file after it has been converted into an Entry.
+
+	Line: line number of the row in the file, the header is line 1
+	Username: value of the username column, even if the row is not valid
+	Entry: the Entry of the row if it is valid
+	Err: a RowError of why the row could not be converted if it is not
 */
 
-func ImportCSV(rd io.Reader) ([]*db.Entry, error) {
+type CSVRow struct {
+	Line     int
+	Username string
+	Entry    *db.Entry
+	Err      error
+}
+
+/*
+	Reads csv lines from an io.Reader and converts every row into an Entry,
+	keeping the rows that are not valid with the reason why. The columns are
+	found by their names in the header or by the mapping, which can be nil.
+	Returns an error if the header is not valid, the file cannot be read or
+	has no rows.
+*/
+
+func ReadCSV(rd io.Reader, mapping ColumnMapping) (*CSVHeader, []CSVRow, error) {
+	header, records, err := readCSV(rd, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, readRows(header, records, 2), nil
+}
+
+/*
+	Reports whether every value of a row is empty, ie a blank row of a
+	spreadsheet.
+*/
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	Converts the rows that follow a header into CSVRows, skipping blank rows.
+
+	first: line number of the first row
+*/
+
+func readRows(header *CSVHeader, records [][]string, first int) []CSVRow {
+	var rows []CSVRow
+	for i, record := range records {
+		if isBlank(record) {
+			continue
+		}
+
+		var username string
+		if j := header.index("username"); j >= 0 && j < len(record) {
+			username = strings.TrimSpace(record[j])
+		}
+
+		e, err := csvToEntry(header, record, first+i)
+		rows = append(rows, CSVRow{Line: first + i, Username: username,
+			Entry: e, Err: err})
+	}
+
+	return rows
+}
+
+/*
+	Reads the header and the rows of a csv file from an io.Reader. Returns an
+	error if the header is not valid, the file cannot be read or has no rows.
+*/
+
+func readCSV(rd io.Reader, mapping ColumnMapping) (*CSVHeader, [][]string, error) {
 	csvReader := csv.NewReader(rd)
-	header, err := csvReader.Read()
+	csvReader.FieldsPerRecord = -1
+
+	record, err := csvReader.Read()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	err = checkCSVHeader(header)
+	header, err := parseCSVHeader(record, mapping)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	rows, err := csvReader.ReadAll()
+	records, err := csvReader.ReadAll()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if len(rows) == 0 {
-		return nil, ErrNoRowsInFile
+	if len(records) == 0 {
+		return nil, nil, ErrNoRowsInFile
 	}
 
+	return header, records, nil
+}
+
+/*
+	Reads csv lines from an io.Reader and returns a slice of entries if they are
+	all valid, if not returns the RowErrors of every row that is not.
+*/
+
+func ImportCSV(rd io.Reader) ([]*db.Entry, error) {
+	_, rows, err := ReadCSV(rd, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rowEntries(rows)
+}
+
+/*
+	Returns the Entries of the rows if they are all valid, if not returns
+	the RowErrors of every row that is not.
+*/
+
+func rowEntries(rows []CSVRow) ([]*db.Entry, error) {
 	var entries []*db.Entry
-	for i, row := range rows {
-		e, err := csvToEntry(row)
-		if err != nil {
-			s := fmt.Sprintf("%v on line %d", err, i+2)
-			return nil, errors.New(s)
+	var errs RowErrors
+	for _, row := range rows {
+		if row.Err != nil {
+			var rowErr *RowError
+			if !errors.As(row.Err, &rowErr) {
+				rowErr = &RowError{Line: row.Line, Err: row.Err}
+			}
+
+			errs = append(errs, rowErr)
+			continue
 		}
 
-		entries = append(entries, e)
+		entries = append(entries, row.Entry)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return entries, nil
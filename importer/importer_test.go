@@ -2,57 +2,34 @@ package importer
 
 import (
 	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/tweekes0/kyocera-ab-tool/db"
 )
 
-func TestCheckCSVHeader(t *testing.T) {
-	tt := []struct {
-		description string
-		got         error
-		expected    error
-	}{
-		{
-			description: "header is valid",
-			got:         checkCSVHeader([]string{"name", "username", "email"}),
-			expected:    nil,
-		},
-		{
-			description: "header has email first",
-			got:         checkCSVHeader([]string{"email", "name", "username"}),
-			expected:    ErrInvalidHeader,
-		},
-		{
-			description: "header has an unrecognized field",
-			got: checkCSVHeader([]string{"doesn't belong", "username",
-				"email"}),
-			expected: ErrInvalidHeader,
-		},
-		{
-			description: "header doesn't have corect number of fields",
-			got: checkCSVHeader([]string{"email", "name", "username",
-				"ssn"}),
-			expected: ErrInvalidHeaderLength,
-		},
+func TestCSVToEntry(t *testing.T) {
+	header, err := parseCSVHeader([]string{"name", "username", "email"}, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	for _, tc := range tt {
-		t.Run(tc.description, func(t *testing.T) {
-			t.Parallel()
-
-			if !errors.Is(tc.got, tc.expected) {
-				t.Fatalf("got: %v, expected: %v", tc.got, tc.expected)
-			}
-		})
+	smbHeader, err := parseCSVHeader([]string{"name", "username", "email",
+		"smb_host", "smb_path"}, nil)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestCSVToEntry(t *testing.T) {
-	_, err1 := csvToEntry([]string{"valid name", "valid_username",
-		"email@email.com"})
-	_, err2 := csvToEntry([]string{"name", "", ""})
-	_, err3 := csvToEntry([]string{"name", ""})
+	_, err1 := csvToEntry(header, []string{"valid name", "valid_username",
+		"email@email.com"}, 2)
+	_, err2 := csvToEntry(header, []string{"name", "", ""}, 2)
+	_, err3 := csvToEntry(header, []string{"name", ""}, 2)
+	_, err4 := csvToEntry(smbHeader, []string{"valid name", "valid_username",
+		"email@email.com", "fileserver", `scans\valid_username`}, 2)
+	_, err5 := csvToEntry(smbHeader, []string{"valid name", "valid_username",
+		"email@email.com", "file server", `scans\valid_username`}, 2)
+	_, err6 := csvToEntry(smbHeader, []string{"valid name", "valid_username",
+		"email@email.com", "", ""}, 2)
 
 	tt := []struct {
 		description string
@@ -74,6 +51,21 @@ func TestCSVToEntry(t *testing.T) {
 			got:         err3,
 			expected:    ErrInvalidRowLength,
 		},
+		{
+			description: "valid csv row with smb destination",
+			got:         err4,
+			expected:    nil,
+		},
+		{
+			description: "csv row with invalid smb host",
+			got:         err5,
+			expected:    db.ErrInvalidSMBHost,
+		},
+		{
+			description: "csv row with empty smb destination",
+			got:         err6,
+			expected:    nil,
+		},
 	}
 
 	for _, tc := range tt {
@@ -95,9 +87,9 @@ func TestImportCSV(t *testing.T) {
 	}
 
 	csv2 := [][]string{
-		{"name", "username", "email", "phone number"},
-		{"Jane Doe", "janedoe", "janedoe@email.com"},
-		{"John Doe", "johndoe", "johndoe@email.com"},
+		{"email", "phone number", "sAMAccountName", "Display Name"},
+		{"janedoe@email.com", "555-0100", "janedoe", "Jane Doe"},
+		{"johndoe@email.com", "555-0101", "johndoe", "John Doe"},
 	}
 
 	csv3 := [][]string{
@@ -109,6 +101,13 @@ func TestImportCSV(t *testing.T) {
 		{"name", "username", "email"},
 	}
 
+	csv5 := [][]string{
+		{"name", "username", "email"},
+		{"Jane Doe", "janedoe", "jane.email.com"},
+		{"John Doe", "johndoe", "johndoe@email.com"},
+		{"Jim Doe", "jimdoe"},
+	}
+
 	f1, td1 := SetupCSV(t, csv1)
 	_, err1 := ImportCSV(f1)
 	defer td1()
@@ -125,6 +124,10 @@ func TestImportCSV(t *testing.T) {
 	_, err4 := ImportCSV(f4)
 	defer td4()
 
+	f5, td5 := SetupCSV(t, csv5)
+	_, err5 := ImportCSV(f5)
+	defer td5()
+
 	tt := []struct {
 		description string
 		got         error
@@ -136,9 +139,9 @@ func TestImportCSV(t *testing.T) {
 			expected:    nil,
 		},
 		{
-			description: "import csv with too many fields",
+			description: "import csv with aliases and unknown columns",
 			got:         err2,
-			expected:    ErrInvalidHeaderLength,
+			expected:    nil,
 		},
 		{
 			description: "import csv with invalid header",
@@ -150,6 +153,16 @@ func TestImportCSV(t *testing.T) {
 			got:         err4,
 			expected:    ErrNoRowsInFile,
 		},
+		{
+			description: "import csv with an invalid email",
+			got:         err5,
+			expected:    db.ErrInvalidEmail,
+		},
+		{
+			description: "import csv with a short row",
+			got:         err5,
+			expected:    ErrInvalidRowLength,
+		},
 	}
 
 	for _, tc := range tt {
@@ -160,4 +173,110 @@ func TestImportCSV(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("every invalid row is returned", func(t *testing.T) {
+		var errs RowErrors
+		if !errors.As(err5, &errs) {
+			t.Fatalf("got: %T, expected: %T", err5, errs)
+		}
+
+		expected := RowErrors{
+			{Line: 2, Field: "email", Column: "email", Value: "jane.email.com",
+				Err: db.ErrInvalidEmail},
+			{Line: 4, Err: ErrInvalidRowLength},
+		}
+
+		if !reflect.DeepEqual(errs, expected) {
+			t.Fatalf("got: %v, expected: %v", errs, expected)
+		}
+	})
+}
+
+func TestReadCSV(t *testing.T) {
+	data := [][]string{
+		{"name", "username", "email"},
+		{"Jane Doe", "janedoe", "janedoe@email.com"},
+		{"John Doe", "johndoe", "not an email"},
+		{"Jim Doe", "jimdoe"},
+	}
+
+	f, teardown := SetupCSV(t, data)
+	defer teardown()
+
+	_, rows, err := ReadCSV(f, nil)
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+
+	tt := []struct {
+		description string
+		row         CSVRow
+		line        int
+		username    string
+		expected    error
+	}{
+		{description: "valid row", row: rows[0], line: 2, username: "janedoe"},
+		{description: "invalid email", row: rows[1], line: 3,
+			username: "johndoe", expected: db.ErrInvalidEmail},
+		{description: "short row", row: rows[2], line: 4, username: "jimdoe",
+			expected: ErrInvalidRowLength},
+	}
+
+	if len(rows) != len(tt) {
+		t.Fatalf("got: %v, expected: %v", len(rows), len(tt))
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.row.Line != tc.line {
+				t.Fatalf("got: %v, expected: %v", tc.row.Line, tc.line)
+			}
+
+			if !errors.Is(tc.row.Err, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", tc.row.Err, tc.expected)
+			}
+
+			if tc.row.Username != tc.username {
+				t.Fatalf("got: %v, expected: %v", tc.row.Username, tc.username)
+			}
+
+			if tc.expected == nil && tc.row.Entry.Username != tc.username {
+				t.Fatalf("got: %v, expected: %v", tc.row.Entry.Username,
+					tc.username)
+			}
+		})
+	}
+}
+
+func TestRowEntries(t *testing.T) {
+	entry := &db.Entry{Name: "Jane Doe", Username: "janedoe"}
+	rows := []CSVRow{
+		{Line: 2, Username: "janedoe", Entry: entry},
+		{Line: 3, Err: &RowError{Line: 3, Err: ErrInvalidRowLength}},
+		{Line: 4, Err: ErrNoRowsInFile},
+	}
+
+	t.Run("valid rows", func(t *testing.T) {
+		entries, err := rowEntries(rows[:1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(entries, []*db.Entry{entry}) {
+			t.Fatalf("got: %v, expected: %v", entries, []*db.Entry{entry})
+		}
+	})
+
+	t.Run("rows with errors", func(t *testing.T) {
+		_, err := rowEntries(rows)
+
+		expected := RowErrors{
+			{Line: 3, Err: ErrInvalidRowLength},
+			{Line: 4, Err: ErrNoRowsInFile},
+		}
+		if !reflect.DeepEqual(err, expected) {
+			t.Fatalf("got: %v, expected: %v", err, expected)
+		}
+	})
 }
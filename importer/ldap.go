@@ -0,0 +1,933 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+var (
+	ErrInvalidLDAPConfig = errors.New("ldap config is not valid")
+	ErrLDAPResult        = errors.New("ldap request failed")
+	ErrNoLDAPEntries     = errors.New("the ldap search returned no users")
+	ErrPlaintextBind     = errors.New("binding over ldap:// sends the password " +
+		"unencrypted, use ldaps://, start_tls or allow_plaintext")
+)
+
+const (
+	DefaultLDAPFilter   = "(&(objectCategory=person)(objectClass=user)(mail=*))"
+	DefaultLDAPPageSize = 500
+	DefaultLDAPTimeout  = 30 * time.Second
+)
+
+/*
+	Attributes the required fields of an Entry are read from by default, as
+	they are named by Active Directory.
+*/
+
+var ldapAttributes = map[string]string{
+	"name":     "displayName",
+	"username": "sAMAccountName",
+	"email":    "mail",
+}
+
+/*
+	Application tags of the LDAP operations that are sent and received, the
+	OID of the control for paged results and of the StartTLS operation.
+*/
+
+const (
+	ldapBindRequest       = 0x60
+	ldapBindResponse      = 0x61
+	ldapUnbindRequest     = 0x42
+	ldapSearchRequest     = 0x63
+	ldapSearchEntry       = 0x64
+	ldapSearchDone        = 0x65
+	ldapSearchReference   = 0x73
+	ldapExtendedRequest   = 0x77
+	ldapExtendedResponse  = 0x78
+	ldapControls          = 0xa0
+	ldapPagedResultsOID   = "1.2.840.113556.1.4.319"
+	ldapStartTLSOID       = "1.3.6.1.4.1.1466.20037"
+	ldapScopeSubtree      = 2
+	ldapNeverDerefAliases = 0
+)
+
+/*
+	Names of the LDAP result codes a search is likely to fail with.
+*/
+
+var ldapResultNames = map[int]string{
+	1:  "operations error",
+	2:  "protocol error",
+	3:  "time limit exceeded",
+	4:  "size limit exceeded",
+	12: "unavailable critical extension",
+	32: "no such object",
+	34: "invalid DN syntax",
+	48: "inappropriate authentication",
+	49: "invalid credentials",
+	50: "insufficient access rights",
+	51: "busy",
+	52: "unavailable",
+	53: "unwilling to perform",
+}
+
+/*
+	LDAPConfig is how the users of a directory are found, ie Active
+	Directory.
+
+	URL: ldap://host[:port] or ldaps://host[:port] for TLS
+	BindDN: DN or user principal name to bind as, anonymous if empty
+	Password: password of the BindDN
+	BaseDN: DN the search starts from
+	Filter: search filter in the form of RFC 4515
+	PageSize: number of users asked for at a time, 0 to not page the search
+	CAFile: PEM file of the certificate authorities trusted for LDAPS or
+	StartTLS, the system ones if empty
+	InsecureSkipVerify: do not verify the certificate of the server
+	StartTLS: upgrade an ldap connection to TLS before binding
+	AllowPlaintext: bind with a password over an ldap connection without
+	StartTLS, which sends it unencrypted
+	Timeout: how long each request can take
+	Mapping: attributes to read fields from instead of the defaults, ie
+	username=uid, or to read other fields from, ie
+	fax_number=facsimileTelephoneNumber
+*/
+
+type LDAPConfig struct {
+	URL                string
+	BindDN             string
+	Password           string
+	BaseDN             string
+	Filter             string
+	PageSize           int
+	CAFile             string
+	InsecureSkipVerify bool
+	StartTLS           bool
+	AllowPlaintext     bool
+	Timeout            time.Duration
+	Mapping            ColumnMapping
+}
+
+/*
+	Reads an LDAPConfig from a file of 'key = value' lines, with the keys
+	url, bind_dn, password, base_dn, filter, page_size, ca_file,
+	insecure_skip_verify, start_tls, allow_plaintext, timeout in seconds and
+	map. Lines starting with # are comments. url and base_dn are required.
+
+	Returns an ErrInvalidLDAPConfig if a line or value is not valid, or an
+	ErrPlaintextBind if the password would be sent unencrypted
+*/
+
+func ParseLDAPConfig(rd io.Reader) (*LDAPConfig, error) {
+	c := &LDAPConfig{Filter: DefaultLDAPFilter, PageSize: DefaultLDAPPageSize,
+		Timeout: DefaultLDAPTimeout}
+
+	s := bufio.NewScanner(rd)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%w: line %d is not 'key = value'",
+				ErrInvalidLDAPConfig, n)
+		}
+
+		key, value := strings.ToLower(strings.TrimSpace(kv[0])),
+			strings.TrimSpace(kv[1])
+
+		var err error
+		switch key {
+		case "url":
+			c.URL = value
+		case "bind_dn":
+			c.BindDN = value
+		case "password":
+			c.Password = value
+		case "base_dn":
+			c.BaseDN = value
+		case "filter":
+			c.Filter = value
+		case "ca_file":
+			c.CAFile = value
+		case "page_size":
+			c.PageSize, err = strconv.Atoi(value)
+			if err == nil && c.PageSize < 0 {
+				err = errors.New("must not be negative")
+			}
+		case "insecure_skip_verify":
+			c.InsecureSkipVerify, err = strconv.ParseBool(value)
+		case "start_tls":
+			c.StartTLS, err = strconv.ParseBool(value)
+		case "allow_plaintext":
+			c.AllowPlaintext, err = strconv.ParseBool(value)
+		case "timeout":
+			var secs int
+			secs, err = strconv.Atoi(value)
+			if err == nil && secs <= 0 {
+				err = errors.New("must be more than 0")
+			}
+
+			c.Timeout = time.Duration(secs) * time.Second
+		case "map":
+			c.Mapping, err = ParseMapping(value)
+		default:
+			return nil, fmt.Errorf("%w: unknown key %v on line %d",
+				ErrInvalidLDAPConfig, key, n)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v on line %d: %v",
+				ErrInvalidLDAPConfig, key, n, err)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+/*
+	Checks that the config has a URL of a known scheme, a base DN, a filter
+	that can be parsed and does not send a password unencrypted.
+*/
+
+func (c *LDAPConfig) check() error {
+	if c.URL == "" || c.BaseDN == "" {
+		return fmt.Errorf("%w: url and base_dn are required",
+			ErrInvalidLDAPConfig)
+	}
+
+	_, err := c.address()
+	if err != nil {
+		return err
+	}
+
+	if c.StartTLS && c.ldaps() {
+		return fmt.Errorf("%w: start_tls is for ldap:// urls, ldaps:// "+
+			"already uses TLS", ErrInvalidLDAPConfig)
+	}
+
+	err = c.checkBind()
+	if err != nil {
+		return err
+	}
+
+	_, err = parseFilter(c.Filter)
+
+	return err
+}
+
+/*
+	Reports whether the URL of the config is an ldaps URL.
+*/
+
+func (c *LDAPConfig) ldaps() bool {
+	return strings.HasPrefix(strings.ToLower(c.URL), "ldaps://")
+}
+
+/*
+	Returns an ErrPlaintextBind if the config binds as a DN over an ldap URL
+	without StartTLS, as the password would be sent unencrypted, unless
+	AllowPlaintext is set. Anonymous binds have no password to protect.
+*/
+
+func (c *LDAPConfig) checkBind() error {
+	if c.BindDN == "" || c.ldaps() || c.StartTLS || c.AllowPlaintext {
+		return nil
+	}
+
+	return ErrPlaintextBind
+}
+
+/*
+	Returns the host and port of the server of the URL, with the default
+	port of its scheme if it has none.
+
+	Returns an ErrInvalidLDAPConfig if the scheme is not ldap or ldaps
+*/
+
+func (c *LDAPConfig) address() (string, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidLDAPConfig, err)
+	}
+
+	port := "389"
+	switch strings.ToLower(u.Scheme) {
+	case "ldap":
+	case "ldaps":
+		port = "636"
+	default:
+		return "", fmt.Errorf("%w: url must start with ldap:// or ldaps://",
+			ErrInvalidLDAPConfig)
+	}
+
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("%w: url has no host", ErrInvalidLDAPConfig)
+	}
+
+	if u.Port() != "" {
+		port = u.Port()
+	}
+
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+/*
+	Returns the TLS config of an ldaps URL or of StartTLS, nil for ldap
+	without StartTLS.
+*/
+
+func (c *LDAPConfig) tlsConfig() (*tls.Config, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLDAPConfig, err)
+	}
+
+	if !c.ldaps() && !c.StartTLS {
+		return nil, nil
+	}
+
+	t := &tls.Config{ServerName: u.Hostname(),
+		InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile == "" {
+		return t, nil
+	}
+
+	pem, err := ioutil.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	t.RootCAs = x509.NewCertPool()
+	if !t.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: %v has no certificates",
+			ErrInvalidLDAPConfig, c.CAFile)
+	}
+
+	return t, nil
+}
+
+/*
+	Returns the attribute the value of a field is read from.
+*/
+
+func (c *LDAPConfig) attribute(field string) string {
+	if a, ok := c.Mapping[field]; ok {
+		return a
+	}
+
+	return ldapAttributes[field]
+}
+
+/*
+	Returns the columns that are read from each user, the required fields
+	then the mapped fields in the order of db.DestinationFields.
+*/
+
+func (c *LDAPConfig) header() *CSVHeader {
+	h := &CSVHeader{}
+	for _, f := range append(append([]string{}, requiredFields...),
+		db.DestinationFields...) {
+		a := c.attribute(f)
+		if a == "" {
+			continue
+		}
+
+		h.Used = append(h.Used, Column{Field: f, Header: a, Index: len(h.Used)})
+	}
+
+	h.width = len(h.Used)
+
+	return h
+}
+
+/*
+	LDAPEntry is a user found by a search.
+
+	DN: distinguished name of the user
+	Attributes: values of each attribute that was asked for by its name
+*/
+
+type LDAPEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+/*
+	Returns the values of an attribute, ignoring the case of its name.
+*/
+
+func (e *LDAPEntry) values(attr string) []string {
+	for name, values := range e.Attributes {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the first value of an attribute, or an empty string if the user
+	does not have it.
+*/
+
+func (e *LDAPEntry) value(attr string) string {
+	values := e.values(attr)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+/*
+	ldapConn is a connection to an LDAP server.
+
+	conn: the connection, over TLS for ldaps or after StartTLS
+	rd: buffers what is read from the connection
+	id: id of the last message that was sent
+	timeout: how long each request can take
+*/
+
+type ldapConn struct {
+	conn    net.Conn
+	rd      *bufio.Reader
+	id      int
+	timeout time.Duration
+}
+
+/*
+	Connects to the server of the config, over TLS for an ldaps URL or
+	upgraded to TLS with StartTLS if the config asks for it.
+*/
+
+func dialLDAP(c *LDAPConfig) (*ldapConn, error) {
+	addr, err := c.address()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &net.Dialer{Timeout: c.Timeout}
+
+	var conn net.Conn
+	if c.ldaps() {
+		conn, err = tls.DialWithDialer(d, "tcp", addr, t)
+	} else {
+		conn, err = d.Dial("tcp", addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	lc := &ldapConn{conn: conn, rd: bufio.NewReader(conn),
+		timeout: c.Timeout}
+	if c.StartTLS {
+		err = lc.startTLS(t)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return lc, nil
+}
+
+/*
+	Encodes an LDAPMessage of an id with an operation and its controls.
+*/
+
+func ldapMessage(id int, op []byte, controls ...[]byte) []byte {
+	parts := [][]byte{berInt(berInteger, id), op}
+	if len(controls) > 0 {
+		parts = append(parts, berConstructed(ldapControls, controls...))
+	}
+
+	return berConstructed(berSequence, parts...)
+}
+
+/*
+	Sends an operation with its controls in a new message and returns the id
+	of the message.
+*/
+
+func (c *ldapConn) send(op []byte, controls ...[]byte) (int, error) {
+	c.id++
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(ldapMessage(c.id, op, controls...))
+
+	return c.id, err
+}
+
+/*
+	Decodes an LDAPMessage into its id, operation and controls.
+*/
+
+func decodeLDAPMessage(msg berElement) (int, berElement, []berElement, error) {
+	parts, err := msg.children()
+	if err != nil {
+		return 0, berElement{}, nil, err
+	}
+
+	if msg.Tag != berSequence || len(parts) < 2 {
+		return 0, berElement{}, nil, fmt.Errorf("%w: message is not an "+
+			"LDAPMessage", ErrInvalidBER)
+	}
+
+	id, err := parts[0].int()
+	if err != nil {
+		return 0, berElement{}, nil, err
+	}
+
+	var controls []berElement
+	if len(parts) > 2 && parts[2].Tag == ldapControls {
+		controls, err = parts[2].children()
+		if err != nil {
+			return 0, berElement{}, nil, err
+		}
+	}
+
+	return id, parts[1], controls, nil
+}
+
+/*
+	Reads the next message for an id and returns its operation and controls.
+
+	Returns an error if the server ends the session, ie with a notice of
+	disconnection
+*/
+
+func (c *ldapConn) receive(id int) (berElement, []berElement, error) {
+	for {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+		msg, err := readBER(c.rd)
+		if err != nil {
+			return berElement{}, nil, err
+		}
+
+		n, op, controls, err := decodeLDAPMessage(msg)
+		if err != nil {
+			return berElement{}, nil, err
+		}
+
+		if n == 0 && op.Tag == ldapExtendedResponse {
+			return berElement{}, nil, fmt.Errorf("%w: the server ended the "+
+				"session: %v", ErrLDAPResult, resultError(op))
+		}
+
+		if n == id {
+			return op, controls, nil
+		}
+	}
+}
+
+/*
+	Decodes an LDAPResult and returns an error if its code is not success.
+*/
+
+func resultError(op berElement) error {
+	parts, err := op.children()
+	if err != nil {
+		return err
+	}
+
+	if len(parts) < 3 {
+		return fmt.Errorf("%w: result is not an LDAPResult", ErrInvalidBER)
+	}
+
+	code, err := parts[0].int()
+	if err != nil {
+		return err
+	}
+
+	if code == 0 {
+		return nil
+	}
+
+	msg := ldapResultNames[code]
+	if msg == "" {
+		msg = "result code " + strconv.Itoa(code)
+	}
+
+	if diag := string(parts[2].Value); diag != "" {
+		msg += ", " + diag
+	}
+
+	return errors.New(msg)
+}
+
+/*
+	Asks the server to start TLS, as RFC 4511 describes, and carries on the
+	session over TLS once it agrees.
+*/
+
+func (c *ldapConn) startTLS(t *tls.Config) error {
+	id, err := c.send(berConstructed(ldapExtendedRequest,
+		berString(0x80, ldapStartTLSOID)))
+	if err != nil {
+		return err
+	}
+
+	op, _, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+
+	if op.Tag != ldapExtendedResponse {
+		return fmt.Errorf("%w: start tls: unexpected response %#x",
+			ErrLDAPResult, op.Tag)
+	}
+
+	if err = resultError(op); err != nil {
+		return fmt.Errorf("%w: start tls: %v", ErrLDAPResult, err)
+	}
+
+	conn := tls.Client(c.conn, t)
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	err = conn.Handshake()
+	if err != nil {
+		return err
+	}
+
+	c.conn, c.rd = conn, bufio.NewReader(conn)
+
+	return nil
+}
+
+/*
+	Binds with a simple password, or anonymously if the DN is empty.
+*/
+
+func (c *ldapConn) bind(dn, password string) error {
+	id, err := c.send(berConstructed(ldapBindRequest,
+		berInt(berInteger, 3),
+		berString(berOctetString, dn),
+		berString(0x80, password)))
+	if err != nil {
+		return err
+	}
+
+	op, _, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+
+	if op.Tag != ldapBindResponse {
+		return fmt.Errorf("%w: bind: unexpected response %#x", ErrLDAPResult,
+			op.Tag)
+	}
+
+	if err = resultError(op); err != nil {
+		return fmt.Errorf("%w: bind: %v", ErrLDAPResult, err)
+	}
+
+	return nil
+}
+
+/*
+	Encodes the control for paged results of a page size, with the cookie
+	of the last page or empty for the first.
+*/
+
+func pagedResultsControl(size int, cookie []byte) []byte {
+	value := berConstructed(berSequence, berInt(berInteger, size),
+		berEncode(berOctetString, cookie))
+
+	return berConstructed(berSequence,
+		berString(berOctetString, ldapPagedResultsOID),
+		berBool(berBoolean, false),
+		berEncode(berOctetString, value))
+}
+
+/*
+	Returns the cookie of the paged results control of a response, empty if
+	it is the last page or the server did not page the results.
+*/
+
+func pagedResultsCookie(controls []berElement) ([]byte, error) {
+	for _, ctl := range controls {
+		parts, err := ctl.children()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(parts) < 2 || string(parts[0].Value) != ldapPagedResultsOID {
+			continue
+		}
+
+		value, err := readBER(bytes.NewReader(parts[len(parts)-1].Value))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBER, err)
+		}
+
+		fields, err := value.children()
+		if err != nil || len(fields) < 2 {
+			return nil, fmt.Errorf("%w: paged results control", ErrInvalidBER)
+		}
+
+		return fields[1].Value, nil
+	}
+
+	return nil, nil
+}
+
+/*
+	Decodes a SearchResultEntry.
+*/
+
+func decodeSearchEntry(op berElement) (*LDAPEntry, error) {
+	parts, err := op.children()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%w: search entry", ErrInvalidBER)
+	}
+
+	attrs, err := parts[1].children()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &LDAPEntry{DN: string(parts[0].Value),
+		Attributes: make(map[string][]string)}
+	for _, a := range attrs {
+		av, err := a.children()
+		if err != nil || len(av) < 2 {
+			return nil, fmt.Errorf("%w: search entry attribute", ErrInvalidBER)
+		}
+
+		values, err := av[1].children()
+		if err != nil {
+			return nil, err
+		}
+
+		name := string(av[0].Value)
+		for _, v := range values {
+			e.Attributes[name] = append(e.Attributes[name], string(v.Value))
+		}
+	}
+
+	return e, nil
+}
+
+/*
+	Searches the subtree of a base DN with a filter, asking for the given
+	attributes. The results are asked for a page at a time if the page size
+	is more than 0, so that a directory with more users than its size limit
+	can be read. Referrals are ignored.
+
+	Returns an ErrLDAPResult if the search fails or the server returns fewer
+	users than were found, ie when its size limit is exceeded
+*/
+
+func (c *ldapConn) search(base, filter string, attrs []string, pageSize int) ([]*LDAPEntry, error) {
+	f, err := parseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var list [][]byte
+	for _, a := range attrs {
+		list = append(list, berString(berOctetString, a))
+	}
+
+	req := berConstructed(ldapSearchRequest,
+		berString(berOctetString, base),
+		berInt(berEnumerated, ldapScopeSubtree),
+		berInt(berEnumerated, ldapNeverDerefAliases),
+		berInt(berInteger, 0),
+		berInt(berInteger, 0),
+		berBool(berBoolean, false),
+		f,
+		berConstructed(berSequence, list...))
+
+	var entries []*LDAPEntry
+	var cookie []byte
+	for {
+		var controls [][]byte
+		if pageSize > 0 {
+			controls = append(controls, pagedResultsControl(pageSize, cookie))
+		}
+
+		id, err := c.send(req, controls...)
+		if err != nil {
+			return nil, err
+		}
+
+		var done bool
+		var doneControls []berElement
+		for !done {
+			op, ctls, err := c.receive(id)
+			if err != nil {
+				return nil, err
+			}
+
+			switch op.Tag {
+			case ldapSearchEntry:
+				e, err := decodeSearchEntry(op)
+				if err != nil {
+					return nil, err
+				}
+
+				entries = append(entries, e)
+			case ldapSearchReference:
+			case ldapSearchDone:
+				if err = resultError(op); err != nil {
+					return nil, fmt.Errorf("%w: search: %v", ErrLDAPResult, err)
+				}
+
+				done, doneControls = true, ctls
+			default:
+				return nil, fmt.Errorf("%w: search: unexpected response %#x",
+					ErrLDAPResult, op.Tag)
+			}
+		}
+
+		cookie, err = pagedResultsCookie(doneControls)
+		if err != nil {
+			return nil, err
+		}
+
+		if pageSize == 0 || len(cookie) == 0 {
+			return entries, nil
+		}
+	}
+}
+
+/*
+	Ends the session and closes the connection.
+*/
+
+func (c *ldapConn) close() error {
+	c.send(berEncode(ldapUnbindRequest, nil))
+	return c.conn.Close()
+}
+
+/*
+	Connects to the server of the config, binds and returns the users found
+	by the search of the config with the attributes of their fields.
+
+	Returns an ErrPlaintextBind if the password would be sent unencrypted, or
+	an ErrNoLDAPEntries if no users are found, so that a filter that is wrong
+	does not remove every user of a table when syncing
+*/
+
+func SearchLDAP(c *LDAPConfig) ([]*LDAPEntry, error) {
+	err := c.checkBind()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialLDAP(c)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.close()
+
+	err = conn.bind(c.BindDN, c.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs []string
+	for _, col := range c.header().Used {
+		attrs = append(attrs, col.Header)
+	}
+
+	entries, err := conn.search(c.BaseDN, c.Filter, attrs, c.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrNoLDAPEntries
+	}
+
+	return entries, nil
+}
+
+/*
+	Searches the directory of the config and returns the users as the rows
+	of a CSV file whose columns are their attributes.
+*/
+
+func readLDAPRecords(c *LDAPConfig) (*CSVHeader, [][]string, error) {
+	entries, err := SearchLDAP(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := c.header()
+	records := make([][]string, len(entries))
+	for i, e := range entries {
+		for _, col := range header.Used {
+			records[i] = append(records[i], e.value(col.Header))
+		}
+	}
+
+	return header, records, nil
+}
+
+/*
+	Searches the directory of the config and converts every user into an
+	Entry as ReadCSV does. displayName is the name of an Entry,
+	sAMAccountName its username and mail its email, unless the Mapping of
+	the config names other attributes. The line of a row is the position of
+	its user in the results, from 1.
+*/
+
+func ReadLDAP(c *LDAPConfig) (*CSVHeader, []CSVRow, error) {
+	header, records, err := readLDAPRecords(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, readRows(header, records, 1), nil
+}
+
+/*
+	Searches the directory of the config and checks every user as
+	ValidateCSV does.
+*/
+
+func ValidateLDAP(c *LDAPConfig, existing []*db.Entry) (*ValidationReport, error) {
+	header, records, err := readLDAPRecords(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateRecords(header, records, 1, existing), nil
+}
@@ -0,0 +1,303 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+/*
+	Function to create a user of Active Directory for the stand-in LDAP
+	server.
+*/
+
+func adUser(ou, name, username, email, flags string) *LDAPEntry {
+	e := &LDAPEntry{
+		DN: "CN=" + name + ",OU=" + ou + ",DC=example,DC=com",
+		Attributes: map[string][]string{
+			"objectClass":        {"top", "person", "organizationalPerson", "user"},
+			"objectCategory":     {"person"},
+			"displayName":        {name},
+			"sAMAccountName":     {username},
+			"userAccountControl": {flags},
+		},
+	}
+
+	if email != "" {
+		e.Attributes["mail"] = []string{email}
+	}
+
+	return e
+}
+
+var adUsers = []*LDAPEntry{
+	adUser("Sales", "Jane Doe", "janedoe", "janedoe@email.com", "512"),
+	adUser("Sales", "John Doe", "johndoe", "johndoe@email.com", "512"),
+	adUser("Sales", "Jim Doe", "jimdoe", "", "512"),
+	adUser("Sales", "Joe Doe", "joedoe", "joedoe@email.com", "514"),
+	adUser("HR", "Jill Doe", "jilldoe", "jilldoe@email.com", "512"),
+	adUser("HR", "Jack Doe", "jackdoe", "jackdoe@email.com", "512"),
+}
+
+func TestParseLDAPConfig(t *testing.T) {
+	valid := `# sales users
+url = ldaps://dc1.example.com
+bind_dn = CN=svc-scan,OU=Service,DC=example,DC=com
+password = p=ss#word
+base_dn = OU=Sales,DC=example,DC=com
+page_size = 100
+timeout = 10
+map = username=uid,fax_number=facsimileTelephoneNumber
+`
+
+	tt := []struct {
+		description string
+		input       string
+		expected    *LDAPConfig
+		err         error
+	}{
+		{
+			description: "valid config",
+			input:       valid,
+			expected: &LDAPConfig{URL: "ldaps://dc1.example.com",
+				BindDN:   "CN=svc-scan,OU=Service,DC=example,DC=com",
+				Password: "p=ss#word", BaseDN: "OU=Sales,DC=example,DC=com",
+				Filter: DefaultLDAPFilter, PageSize: 100,
+				Timeout: 10 * time.Second,
+				Mapping: ColumnMapping{"username": "uid",
+					"fax_number": "facsimileTelephoneNumber"}},
+		},
+		{
+			description: "bind with start tls",
+			input: "url = ldap://dc1\nbind_dn = svc-scan@example.com\n" +
+				"base_dn = dc=example\nstart_tls = true\n",
+			expected: &LDAPConfig{URL: "ldap://dc1",
+				BindDN: "svc-scan@example.com", BaseDN: "dc=example",
+				Filter: DefaultLDAPFilter, PageSize: DefaultLDAPPageSize,
+				StartTLS: true, Timeout: DefaultLDAPTimeout},
+		},
+		{
+			description: "bind without tls",
+			input: "url = ldap://dc1\nbind_dn = svc-scan@example.com\n" +
+				"base_dn = dc=example\n",
+			err: ErrPlaintextBind,
+		},
+		{
+			description: "bind without tls allowed",
+			input: "url = ldap://dc1\nbind_dn = svc-scan@example.com\n" +
+				"base_dn = dc=example\nallow_plaintext = true\n",
+			expected: &LDAPConfig{URL: "ldap://dc1",
+				BindDN: "svc-scan@example.com", BaseDN: "dc=example",
+				Filter: DefaultLDAPFilter, PageSize: DefaultLDAPPageSize,
+				AllowPlaintext: true, Timeout: DefaultLDAPTimeout},
+		},
+		{
+			description: "start tls over ldaps",
+			input:       "url = ldaps://dc1\nbase_dn = dc=example\nstart_tls = true\n",
+			err:         ErrInvalidLDAPConfig,
+		},
+		{
+			description: "missing base dn",
+			input:       "url = ldap://dc1.example.com\n",
+			err:         ErrInvalidLDAPConfig,
+		},
+		{
+			description: "unknown scheme",
+			input:       "url = http://dc1.example.com\nbase_dn = dc=example\n",
+			err:         ErrInvalidLDAPConfig,
+		},
+		{
+			description: "unknown key",
+			input:       "url = ldap://dc1\nbase_dn = dc=example\nport = 389\n",
+			err:         ErrInvalidLDAPConfig,
+		},
+		{
+			description: "negative page size",
+			input:       "url = ldap://dc1\nbase_dn = dc=example\npage_size = -1\n",
+			err:         ErrInvalidLDAPConfig,
+		},
+		{
+			description: "invalid filter",
+			input:       "url = ldap://dc1\nbase_dn = dc=example\nfilter = (mail=*\n",
+			err:         ErrInvalidFilter,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseLDAPConfig(strings.NewReader(tc.input))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %+v, expected: %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSearchLDAP(t *testing.T) {
+	enabled := "(&(objectCategory=person)(mail=*)" +
+		"(!(userAccountControl:1.2.840.113556.1.4.803:=2)))"
+
+	tt := []struct {
+		description string
+		ldaps       bool
+		change      func(c *LDAPConfig)
+		expected    []string
+		err         error
+	}{
+		{
+			description: "default filter over start tls",
+			expected: []string{"janedoe", "johndoe", "joedoe", "jilldoe",
+				"jackdoe"},
+		},
+		{
+			description: "password over ldap without tls",
+			change:      func(c *LDAPConfig) { c.StartTLS = false },
+			err:         ErrPlaintextBind,
+		},
+		{
+			description: "password over ldap without tls allowed",
+			change: func(c *LDAPConfig) {
+				c.StartTLS, c.AllowPlaintext = false, true
+			},
+			expected: []string{"janedoe", "johndoe", "joedoe", "jilldoe",
+				"jackdoe"},
+		},
+		{
+			description: "paged over ldaps",
+			ldaps:       true,
+			change:      func(c *LDAPConfig) { c.PageSize = 2 },
+			expected: []string{"janedoe", "johndoe", "joedoe", "jilldoe",
+				"jackdoe"},
+		},
+		{
+			description: "base dn and filter",
+			change: func(c *LDAPConfig) {
+				c.BaseDN, c.Filter = "OU=Sales,DC=example,DC=com", enabled
+			},
+			expected: []string{"janedoe", "johndoe"},
+		},
+		{
+			description: "size limit exceeded without paging",
+			change:      func(c *LDAPConfig) { c.PageSize = 0 },
+			err:         ErrLDAPResult,
+		},
+		{
+			description: "wrong password",
+			change:      func(c *LDAPConfig) { c.Password = "wrong" },
+			err:         ErrLDAPResult,
+		},
+		{
+			description: "base dn that does not exist",
+			change:      func(c *LDAPConfig) { c.BaseDN = "DC=other,DC=com" },
+			err:         ErrLDAPResult,
+		},
+		{
+			description: "no users found",
+			change:      func(c *LDAPConfig) { c.Filter = "(sAMAccountName=nobody)" },
+			err:         ErrNoLDAPEntries,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			c, teardown := SetupLDAP(t, tc.ldaps, adUsers)
+			defer teardown()
+
+			if tc.change != nil {
+				tc.change(c)
+			}
+
+			entries, err := SearchLDAP(c)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.value("samaccountname"))
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		for _, ldaps := range []bool{true, false} {
+			c, teardown := SetupLDAP(t, ldaps, adUsers)
+			defer teardown()
+
+			// the certificate of the server is self-signed
+			c.CAFile = ""
+			_, err := SearchLDAP(c)
+			if err == nil {
+				t.Fatalf("got: %v, expected: %v", err, "certificate error")
+			}
+		}
+	})
+}
+
+func TestReadLDAP(t *testing.T) {
+	users := []*LDAPEntry{
+		adUser("Sales", "Jane Doe", "janedoe", "janedoe@email.com", "512"),
+		adUser("Sales", "John Doe", "johndoe", "johndoe@email.com", "512"),
+		adUser("Sales", "Doe, Jim", "jimdoe", "jimdoe@email.com", "512"),
+	}
+	users[0].Attributes["facsimileTelephoneNumber"] = []string{"5550100"}
+
+	c, teardown := SetupLDAP(t, false, users)
+	defer teardown()
+
+	c.Mapping = ColumnMapping{"fax_number": "facsimileTelephoneNumber"}
+
+	header, rows, err := ReadLDAP(c)
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+
+	columns := []Column{
+		{Field: "name", Header: "displayName", Index: 0},
+		{Field: "username", Header: "sAMAccountName", Index: 1},
+		{Field: "email", Header: "mail", Index: 2},
+		{Field: "fax_number", Header: "facsimileTelephoneNumber", Index: 3},
+	}
+
+	if !reflect.DeepEqual(header.Used, columns) {
+		t.Fatalf("got: %v, expected: %v", header.Used, columns)
+	}
+
+	if len(rows) != 3 || rows[0].Entry.Fax.Number != "5550100" {
+		t.Fatalf("got: %v, expected: %v", rows, "3 rows with a fax number")
+	}
+
+	if rows[2].Line != 3 || !errors.Is(rows[2].Err, db.ErrInvalidName) {
+		t.Fatalf("got: %v, expected: %v", rows[2].Err, db.ErrInvalidName)
+	}
+
+	t.Run("validate users", func(t *testing.T) {
+		existing := []*db.Entry{{Username: "janedoe", Email: "jane@email.com"}}
+		report, err := ValidateLDAP(c, existing)
+		if err != nil {
+			t.Fatalf("got: %v, expected: %v", err, nil)
+		}
+
+		if report.Rows != 3 || report.Valid != 1 || len(report.Errors) != 2 {
+			t.Fatalf("got: %+v, expected: %v", report.Errors, "2 errors")
+		}
+	})
+}
@@ -0,0 +1,534 @@
+package importer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+	Function to write an xlsx workbook with a sheet of each name holding the
+	rows of the matching data, and return its path and the clean up function.
+	The first row of a sheet is written with inline strings, the rest with
+	shared strings or numbers, and empty cells are left out as Excel does.
+*/
+
+func SetupXLSX(t *testing.T, sheets []string, data ...[][]string) (string, func()) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("cannot create test directory: %v", err)
+	}
+
+	name := filepath.Join(dir, "workbook.xlsx")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("cannot create test file: %v", err)
+	}
+	defer f.Close()
+
+	z := zip.NewWriter(f)
+	write := func(name, content string) {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatalf("cannot write workbook: %v", err)
+		}
+
+		io.WriteString(w, xml.Header+content)
+	}
+
+	escape := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	const (
+		mainNS = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+		relNS  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+		pkgNS  = "http://schemas.openxmlformats.org/package/2006/relationships"
+	)
+
+	write("[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`+
+		`<Default Extension="xml" ContentType="application/xml"/></Types>`)
+	write("_rels/.rels", `<Relationships xmlns="`+pkgNS+`">`+
+		`<Relationship Id="rId1" Type="`+relNS+`/officeDocument" Target="xl/workbook.xml"/>`+
+		`</Relationships>`)
+
+	var book, rels strings.Builder
+	var shared []string
+	for i, sheet := range sheets {
+		id := fmt.Sprintf("rId%d", i+1)
+		book.WriteString(fmt.Sprintf(`<sheet name="%v" sheetId="%d" r:id="%v"/>`,
+			escape(sheet), i+1, id))
+		rels.WriteString(fmt.Sprintf(`<Relationship Id="%v" Type="%v/worksheet" Target="worksheets/sheet%d.xml"/>`,
+			id, relNS, i+1))
+
+		var rows strings.Builder
+		for r, record := range data[i] {
+			var cells strings.Builder
+			for c, v := range record {
+				if v == "" {
+					continue
+				}
+
+				ref := fmt.Sprintf("%c%d", 'A'+c, r+1)
+				_, err := strconv.ParseFloat(v, 64)
+				switch {
+				case r == 0:
+					cells.WriteString(fmt.Sprintf(`<c r="%v" t="inlineStr"><is><t>%v</t></is></c>`,
+						ref, escape(v)))
+				case err == nil:
+					cells.WriteString(fmt.Sprintf(`<c r="%v"><v>%v</v></c>`, ref, v))
+				default:
+					shared = append(shared, v)
+					cells.WriteString(fmt.Sprintf(`<c r="%v" t="s"><v>%d</v></c>`,
+						ref, len(shared)-1))
+				}
+			}
+
+			if cells.Len() > 0 {
+				rows.WriteString(fmt.Sprintf(`<row r="%d">%v</row>`, r+1,
+					cells.String()))
+			}
+		}
+
+		write(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1),
+			`<worksheet xmlns="`+mainNS+`"><sheetData>`+rows.String()+
+				`</sheetData></worksheet>`)
+	}
+
+	var sst strings.Builder
+	for _, s := range shared {
+		sst.WriteString("<si><t>" + escape(s) + "</t></si>")
+	}
+
+	write("xl/workbook.xml", `<workbook xmlns="`+mainNS+`" xmlns:r="`+relNS+`">`+
+		`<sheets>`+book.String()+`</sheets></workbook>`)
+	write("xl/_rels/workbook.xml.rels", `<Relationships xmlns="`+pkgNS+`">`+
+		rels.String()+`<Relationship Id="rIdStrings" Type="`+relNS+
+		`/sharedStrings" Target="sharedStrings.xml"/></Relationships>`)
+	write("xl/sharedStrings.xml", `<sst xmlns="`+mainNS+`">`+sst.String()+`</sst>`)
+
+	err = z.Close()
+	if err != nil {
+		t.Fatalf("cannot write workbook: %v", err)
+	}
+
+	return name, func() { os.RemoveAll(dir) }
+}
+
+/*
+	Credentials and base of the directory served by SetupLDAP, and the most
+	users it returns to a search that is not paged.
+*/
+
+const (
+	ldapTestBindDN    = "cn=admin,dc=example,dc=com"
+	ldapTestPassword  = "secret"
+	ldapTestBaseDN    = "dc=example,dc=com"
+	ldapTestSizeLimit = 3
+)
+
+/*
+	Function to start a stand-in LDAP server holding the given users, over
+	TLS with a self-signed certificate if ldaps is true, and return a config
+	to search it and the clean up function. The config of a server that is
+	not ldaps uses StartTLS. The server supports StartTLS, simple binds,
+	searches with filters and paged results, and returns a referral before
+	the users of each page.
+*/
+
+func SetupLDAP(t *testing.T, ldaps bool, entries []*LDAPEntry) (*LDAPConfig, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot start ldap server: %v", err)
+	}
+
+	c := &LDAPConfig{URL: "ldap://" + l.Addr().String(),
+		BindDN: ldapTestBindDN, Password: ldapTestPassword,
+		BaseDN: ldapTestBaseDN, Filter: DefaultLDAPFilter,
+		PageSize: DefaultLDAPPageSize, Timeout: 5 * time.Second}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("cannot create test directory: %v", err)
+	}
+
+	cert, pemBytes := ldapTestCertificate(t)
+	c.CAFile = filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(c.CAFile, pemBytes, 0644)
+	if err != nil {
+		t.Fatalf("cannot write certificate: %v", err)
+	}
+
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if ldaps {
+		c.URL = "ldaps://" + l.Addr().String()
+		l = tls.NewListener(l, tc)
+	} else {
+		c.StartTLS = true
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveLDAP(conn, tc, entries)
+		}
+	}()
+
+	teardown := func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+
+	return c, teardown
+}
+
+/*
+	Function to create a self-signed certificate for 127.0.0.1 and return it
+	with its PEM encoding.
+*/
+
+func ldapTestCertificate(t *testing.T) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot create key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+/*
+	Encodes an LDAPResult with a tag, result code and diagnostic message.
+*/
+
+func ldapResult(tag byte, code int, msg string) []byte {
+	return berConstructed(tag, berInt(berEnumerated, code),
+		berString(berOctetString, ""), berString(berOctetString, msg))
+}
+
+/*
+	Answers the requests of a connection to the stand-in LDAP server until
+	it is unbound or closed, carrying on over TLS with the config t after a
+	StartTLS request.
+*/
+
+func serveLDAP(conn net.Conn, t *tls.Config, entries []*LDAPEntry) {
+	defer func() { conn.Close() }()
+
+	rd := bufio.NewReader(conn)
+	bound := false
+	for {
+		msg, err := readBER(rd)
+		if err != nil {
+			return
+		}
+
+		id, op, controls, err := decodeLDAPMessage(msg)
+		if err != nil {
+			return
+		}
+
+		fields, err := op.children()
+		if err != nil && op.Tag != ldapUnbindRequest {
+			return
+		}
+
+		switch op.Tag {
+		case ldapBindRequest:
+			bound = len(fields) == 3 && string(fields[1].Value) == ldapTestBindDN &&
+				string(fields[2].Value) == ldapTestPassword
+
+			code := 0
+			if !bound {
+				code = 49
+			}
+
+			conn.Write(ldapMessage(id, ldapResult(ldapBindResponse, code, "")))
+		case ldapExtendedRequest:
+			if len(fields) == 0 || string(fields[0].Value) != ldapStartTLSOID {
+				conn.Write(ldapMessage(id, ldapResult(ldapExtendedResponse, 2,
+					"unknown extended operation")))
+				continue
+			}
+
+			conn.Write(ldapMessage(id, ldapResult(ldapExtendedResponse, 0, "")))
+			conn = tls.Server(conn, t)
+			rd = bufio.NewReader(conn)
+		case ldapSearchRequest:
+			for _, m := range searchLDAPTest(id, fields, controls, entries, bound) {
+				conn.Write(m)
+			}
+		default:
+			return
+		}
+	}
+}
+
+/*
+	Returns the messages that answer a search request of the stand-in LDAP
+	server, the users that match its filter and the result.
+*/
+
+func searchLDAPTest(id int, fields, controls []berElement, entries []*LDAPEntry, bound bool) [][]byte {
+	done := func(code int, msg string, ctls ...[]byte) []byte {
+		return ldapMessage(id, ldapResult(ldapSearchDone, code, msg), ctls...)
+	}
+
+	if !bound {
+		return [][]byte{done(50, "bind first")}
+	}
+
+	if len(fields) < 8 {
+		return [][]byte{done(2, "search request is not valid")}
+	}
+
+	base := string(fields[0].Value)
+	if !strings.HasSuffix(strings.ToLower(base), ldapTestBaseDN) {
+		return [][]byte{done(32, "base "+base+" does not exist")}
+	}
+
+	var attrs []string
+	list, _ := fields[7].children()
+	for _, a := range list {
+		attrs = append(attrs, string(a.Value))
+	}
+
+	var matched []*LDAPEntry
+	for _, e := range entries {
+		if strings.HasSuffix(strings.ToLower(e.DN), strings.ToLower(base)) &&
+			matchLDAPTest(fields[6], e) {
+			matched = append(matched, e)
+		}
+	}
+
+	size, offset, paged := 0, 0, false
+	for _, ctl := range controls {
+		parts, _ := ctl.children()
+		if len(parts) < 2 || string(parts[0].Value) != ldapPagedResultsOID {
+			continue
+		}
+
+		value, err := readBER(bytes.NewReader(parts[len(parts)-1].Value))
+		if err != nil {
+			return [][]byte{done(2, "paged results control is not valid")}
+		}
+
+		v, _ := value.children()
+		if len(v) < 2 {
+			return [][]byte{done(2, "paged results control is not valid")}
+		}
+
+		size, _ = v[0].int()
+		offset, _ = strconv.Atoi(string(v[1].Value))
+		paged = true
+	}
+
+	page, code, cookie := matched, 0, ""
+	switch {
+	case paged:
+		end := offset + size
+		if end >= len(matched) {
+			end = len(matched)
+		} else {
+			cookie = strconv.Itoa(end)
+		}
+
+		page = matched[offset:end]
+	case len(matched) > ldapTestSizeLimit:
+		page, code = matched[:ldapTestSizeLimit], 4
+	}
+
+	msgs := [][]byte{ldapMessage(id, berConstructed(ldapSearchReference,
+		berString(berOctetString, "ldap://other.example.com/dc=other,dc=com")))}
+
+	for _, e := range page {
+		var list [][]byte
+		for name, values := range e.Attributes {
+			keep := len(attrs) == 0
+			for _, a := range attrs {
+				keep = keep || strings.EqualFold(a, name)
+			}
+
+			if !keep {
+				continue
+			}
+
+			var vals [][]byte
+			for _, v := range values {
+				vals = append(vals, berString(berOctetString, v))
+			}
+
+			list = append(list, berConstructed(berSequence,
+				berString(berOctetString, name), berConstructed(berSet, vals...)))
+		}
+
+		msgs = append(msgs, ldapMessage(id, berConstructed(ldapSearchEntry,
+			berString(berOctetString, e.DN),
+			berConstructed(berSequence, list...))))
+	}
+
+	if !paged {
+		return append(msgs, done(code, ""))
+	}
+
+	return append(msgs, done(code, "", pagedResultsControl(size, []byte(cookie))))
+}
+
+/*
+	Reports whether a user of the stand-in LDAP server matches a filter.
+	Extensible matches compare the values bitwise for the rule Active
+	Directory uses for flags, and as equality otherwise.
+*/
+
+func matchLDAPTest(f berElement, e *LDAPEntry) bool {
+	if f.Tag == filterPresent {
+		return len(e.values(string(f.Value))) > 0
+	}
+
+	children, err := f.children()
+	if err != nil {
+		return false
+	}
+
+	switch f.Tag {
+	case filterAnd:
+		for _, c := range children {
+			if !matchLDAPTest(c, e) {
+				return false
+			}
+		}
+
+		return true
+	case filterOr:
+		for _, c := range children {
+			if matchLDAPTest(c, e) {
+				return true
+			}
+		}
+
+		return false
+	case filterNot:
+		return len(children) == 1 && !matchLDAPTest(children[0], e)
+	case filterExtensible:
+		var rule, attr, value string
+		for _, c := range children {
+			switch c.Tag {
+			case 0x81:
+				rule = string(c.Value)
+			case 0x82:
+				attr = string(c.Value)
+			case 0x83:
+				value = string(c.Value)
+			}
+		}
+
+		for _, v := range e.values(attr) {
+			if rule != "1.2.840.113556.1.4.803" {
+				if strings.EqualFold(v, value) {
+					return true
+				}
+
+				continue
+			}
+
+			a, _ := strconv.Atoi(v)
+			b, _ := strconv.Atoi(value)
+			if a&b == b {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(children) < 2 {
+		return false
+	}
+
+	for _, v := range e.values(string(children[0].Value)) {
+		v = strings.ToLower(v)
+		want := strings.ToLower(string(children[1].Value))
+		switch f.Tag {
+		case filterEquality, filterApprox:
+			if v == want {
+				return true
+			}
+		case filterGreater:
+			if v >= want {
+				return true
+			}
+		case filterLess:
+			if v <= want {
+				return true
+			}
+		case filterSubstrings:
+			subs, _ := children[1].children()
+			ok := true
+			for _, s := range subs {
+				part := strings.ToLower(string(s.Value))
+				switch s.Tag {
+				case 0x80:
+					ok = ok && strings.HasPrefix(v, part)
+					v = strings.TrimPrefix(v, part)
+				case 0x81:
+					i := strings.Index(v, part)
+					ok = ok && i >= 0
+					if i >= 0 {
+						v = v[i+len(part):]
+					}
+				case 0x82:
+					ok = ok && strings.HasSuffix(v, part)
+				}
+			}
+
+			if ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+/*
+	ValidationReport holds the outcome of ValidateCSV.
+
+	Columns: the columns of the file that were read and ignored
+	Rows: number of rows in the file
+	Valid: number of rows without any errors
+	Errors: every problem found, in the order of the file
+*/
+
+type ValidationReport struct {
+	Columns *CSVHeader `json:"columns"`
+	Rows    int        `json:"rows"`
+	Valid   int        `json:"valid"`
+	Errors  RowErrors  `json:"errors,omitempty"`
+}
+
+/*
+	Returns the errors of every value of a row that is not valid, or of the
+	row itself if it has the wrong number of columns or its values are not
+	valid together, ie an smb_path without an smb_host.
+*/
+
+func validateRow(header *CSVHeader, row []string, line int) RowErrors {
+	errs := checkRow(header, row, line)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	_, err := csvToEntry(header, row, line)
+	if err != nil {
+		return RowErrors{err.(*RowError)}
+	}
+
+	return nil
+}
+
+/*
+	Records the lines that usernames and emails were first seen on, with 0
+	for those of the table, to find duplicates.
+*/
+
+type seenValues map[string]int
+
+/*
+	Returns the key a value of a field is recorded by. Emails are compared
+	ignoring case.
+*/
+
+func seenKey(field, value string) string {
+	if field == "email" {
+		value = strings.ToLower(value)
+	}
+
+	return field + ":" + value
+}
+
+/*
+	Returns a RowError if the value of the column of a field was already
+	seen, otherwise records it.
+*/
+
+func (s seenValues) check(header *CSVHeader, row []string, line int, field string) RowErrors {
+	c := header.column(field)
+	v := strings.TrimSpace(row[c.Index])
+	key := seenKey(field, v)
+
+	first, ok := s[key]
+	if !ok {
+		s[key] = line
+		return nil
+	}
+
+	err := ErrAlreadyInTable
+	if first > 0 {
+		err = fmt.Errorf("%w on line %d", ErrDuplicateInFile, first)
+	}
+
+	return RowErrors{{Line: line, Field: field, Column: c.Header, Value: v,
+		Err: err}}
+}
+
+/*
+	Reads a csv file from an io.Reader and checks every row without adding
+	it anywhere. Every value that is not valid is reported, along with the
+	usernames and emails that are repeated in the file, ErrDuplicateInFile,
+	or that are already used by the existing Entries, ErrAlreadyInTable, ie
+	those of the current table.
+
+	Returns an error if the header is not valid, the file cannot be read or
+	has no rows
+*/
+
+func ValidateCSV(rd io.Reader, mapping ColumnMapping, existing []*db.Entry) (*ValidationReport, error) {
+	header, records, err := readCSV(rd, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateRecords(header, records, 2, existing), nil
+}
+
+/*
+	Checks the rows that follow a header, skipping blank rows.
+
+	first: line number of the first row
+*/
+
+func validateRecords(header *CSVHeader, records [][]string, first int, existing []*db.Entry) *ValidationReport {
+	seen := make(seenValues)
+	for _, e := range existing {
+		seen[seenKey("username", e.Username)] = 0
+		seen[seenKey("email", e.Email)] = 0
+	}
+
+	report := &ValidationReport{Columns: header}
+	for i, record := range records {
+		if isBlank(record) {
+			continue
+		}
+
+		line := first + i
+		errs := validateRow(header, record, line)
+		if len(record) == header.width {
+			errs = append(errs, seen.check(header, record, line, "username")...)
+			errs = append(errs, seen.check(header, record, line, "email")...)
+		}
+
+		if len(errs) == 0 {
+			report.Valid++
+		}
+
+		report.Rows++
+		report.Errors = append(report.Errors, errs...)
+	}
+
+	return report
+}
@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+func TestValidateCSV(t *testing.T) {
+	data := [][]string{
+		{"Display Name", "sAMAccountName", "mail", "smb_host", "smb_path"},
+		{"Jane Doe", "janedoe", "janedoe@email.com", "", ""},
+		{"Jane_Doe", "jane doe", "jane.email.com", "file server", ""},
+		{"Jim Doe", "jimdoe", "JANEDOE@email.com", "", ""},
+		{"Joe Doe", "username1", "joedoe@email.com", "", ""},
+		{"Jill Doe", "jilldoe", "jilldoe@email.com", "", `scans\jilldoe`},
+		{"Jack Doe", "jackdoe"},
+	}
+
+	existing := []*db.Entry{{Name: "Test One", Username: "username1",
+		Email: "test1@test.com"}}
+
+	f, teardown := SetupCSV(t, data)
+	defer teardown()
+
+	got, err := ValidateCSV(f, nil, existing)
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+
+	expected := RowErrors{
+		{Line: 3, Field: "name", Column: "Display Name", Value: "Jane_Doe",
+			Err: db.ErrInvalidName},
+		{Line: 3, Field: "username", Column: "sAMAccountName",
+			Value: "jane doe", Err: db.ErrInvalidUsername},
+		{Line: 3, Field: "email", Column: "mail", Value: "jane.email.com",
+			Err: db.ErrInvalidEmail},
+		{Line: 3, Field: "smb_host", Column: "smb_host", Value: "file server",
+			Err: db.ErrInvalidSMBHost},
+		{Line: 4, Field: "email", Column: "mail", Value: "JANEDOE@email.com",
+			Err: fmt.Errorf("%w on line 2", ErrDuplicateInFile)},
+		{Line: 5, Field: "username", Column: "sAMAccountName",
+			Value: "username1", Err: ErrAlreadyInTable},
+		{Line: 6, Err: db.ErrInvalidSMBHost},
+		{Line: 7, Err: ErrInvalidRowLength},
+	}
+
+	if !reflect.DeepEqual(got.Errors, expected) {
+		t.Fatalf("got: %v, expected: %v", got.Errors, expected)
+	}
+
+	for _, sentinel := range []error{db.ErrInvalidName, ErrDuplicateInFile,
+		ErrAlreadyInTable, ErrInvalidRowLength} {
+		if !errors.Is(got.Errors, sentinel) {
+			t.Fatalf("got: %v, expected: %v", got.Errors, sentinel)
+		}
+	}
+
+	if got.Rows != 6 || got.Valid != 1 {
+		t.Fatalf("got: %v, expected: %v", got.Valid, "1 of 6 rows valid")
+	}
+}
@@ -0,0 +1,553 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+var (
+	ErrInvalidVCard        = errors.New("file is not a valid vcard")
+	ErrVCardVersion        = errors.New("vcard version is not supported, only 3.0 and 4.0 are")
+	ErrNoCardsInFile       = errors.New("there are no cards in this file")
+	ErrInvalidUsernameRule = errors.New("username rule must be email, initial or name")
+)
+
+/*
+	UsernameRule is how the username of a contact is derived when its card
+	does not have one.
+		UsernameFromEmail the part of the email before the @, ie jane.doe
+		UsernameFromInitial the first initial and the family name, ie jdoe
+		UsernameFromName the given and family names, ie jane.doe
+*/
+
+type UsernameRule string
+
+const (
+	UsernameFromEmail   UsernameRule = "email"
+	UsernameFromInitial UsernameRule = "initial"
+	UsernameFromName    UsernameRule = "name"
+)
+
+/*
+	Every UsernameRule, the first is the default.
+*/
+
+var UsernameRules = []string{
+	string(UsernameFromEmail),
+	string(UsernameFromInitial),
+	string(UsernameFromName),
+}
+
+/*
+	Parses a UsernameRule given by the user, ignoring case. An empty rule is
+	UsernameFromEmail.
+
+	Returns an ErrInvalidUsernameRule if the rule is not known
+*/
+
+func ParseUsernameRule(s string) (UsernameRule, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return UsernameFromEmail, nil
+	}
+
+	for _, r := range UsernameRules {
+		if s == r {
+			return UsernameRule(s), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %v", ErrInvalidUsernameRule, s)
+}
+
+/*
+	vcardProperty is a content line of a card, ie
+	'item1.EMAIL;TYPE=work,pref:jane@email.com'.
+
+	Name: name of the property in upper case, without its group
+	Params: parameters of the property by their name in upper case
+	Value: the value as it is in the file, with its escapes
+*/
+
+type vcardProperty struct {
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+/*
+	vcard is a card of a vCard file.
+
+	Line: line number of the BEGIN:VCARD of the card
+	Props: the properties of the card in the order of the file
+*/
+
+type vcard struct {
+	Line  int
+	Props []vcardProperty
+}
+
+/*
+	Returns the first property of a name, nil if the card does not have it.
+*/
+
+func (c *vcard) property(name string) *vcardProperty {
+	for i := range c.Props {
+		if c.Props[i].Name == name {
+			return &c.Props[i]
+		}
+	}
+
+	return nil
+}
+
+/*
+	Returns the value of the first property of a name without its escapes,
+	or an empty string if the card does not have it.
+*/
+
+func (c *vcard) text(name string) string {
+	p := c.property(name)
+	if p == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(unescapeText(p.Value))
+}
+
+/*
+	Returns the preferred email of the card, the first one with a PREF
+	parameter or a pref TYPE, otherwise the first one.
+*/
+
+func (c *vcard) email() string {
+	var email *vcardProperty
+	for i := range c.Props {
+		p := &c.Props[i]
+		if p.Name != "EMAIL" {
+			continue
+		}
+
+		if p.preferred() {
+			email = p
+			break
+		}
+
+		if email == nil {
+			email = p
+		}
+	}
+
+	if email == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(unescapeText(email.Value))
+}
+
+/*
+	Reports whether a property is marked as preferred, by a PREF parameter
+	in 4.0 or TYPE=pref in 3.0.
+*/
+
+func (p *vcardProperty) preferred() bool {
+	if _, ok := p.Params["PREF"]; ok {
+		return true
+	}
+
+	for _, t := range p.Params["TYPE"] {
+		if strings.EqualFold(t, "pref") {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+	Returns the given and family names of the card from its N property, or
+	from the first and last words of its FN if it does not have one.
+*/
+
+func (c *vcard) names() (string, string) {
+	if p := c.property("N"); p != nil {
+		parts := splitEscaped(p.Value, ';')
+		for len(parts) < 2 {
+			parts = append(parts, "")
+		}
+
+		given := strings.TrimSpace(unescapeText(parts[1]))
+		family := strings.TrimSpace(unescapeText(parts[0]))
+		if given != "" || family != "" {
+			return given, family
+		}
+	}
+
+	words := strings.Fields(c.text("FN"))
+	if len(words) == 0 {
+		return "", ""
+	}
+
+	if len(words) == 1 {
+		return words[0], ""
+	}
+
+	return words[0], words[len(words)-1]
+}
+
+/*
+	Returns the display name of the card, its FN or its given and family
+	names if it does not have one.
+*/
+
+func (c *vcard) name() string {
+	if fn := c.text("FN"); fn != "" {
+		return fn
+	}
+
+	given, family := c.names()
+
+	return strings.TrimSpace(given + " " + family)
+}
+
+/*
+	Returns the username of the card, its X-USERNAME or one derived from the
+	card by the rule.
+*/
+
+func (c *vcard) username(rule UsernameRule) string {
+	if u := c.text("X-USERNAME"); u != "" {
+		return u
+	}
+
+	given, family := c.names()
+	switch rule {
+	case UsernameFromInitial:
+		if given == "" {
+			return cleanUsername(family)
+		}
+
+		return cleanUsername(string([]rune(given)[:1]) + family)
+	case UsernameFromName:
+		return cleanUsername(given + "." + family)
+	}
+
+	email := c.email()
+	if i := strings.Index(email, "@"); i >= 0 {
+		email = email[:i]
+	}
+
+	return cleanUsername(email)
+}
+
+/*
+	Turns a derived username into one that is valid where possible. It is
+	lower cased, letters that are not ASCII and other characters are dropped
+	and it starts with a letter and has no repeated or trailing separators.
+*/
+
+func cleanUsername(s string) string {
+	var b strings.Builder
+	var sep rune
+	for _, c := range strings.ToLower(s) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9' && b.Len() > 0:
+			if sep != 0 {
+				b.WriteRune(sep)
+				sep = 0
+			}
+
+			b.WriteRune(c)
+		case (c == '.' || c == '_' || c == '-') && b.Len() > 0:
+			sep = c
+		case c == ' ' && b.Len() > 0:
+			sep = '.'
+		}
+	}
+
+	return b.String()
+}
+
+/*
+	Splits a value at every separator that is not escaped by a backslash.
+*/
+
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+/*
+	Removes the escapes of a text value, \n is a new line and \, \; and \\
+	are the characters themselves.
+*/
+
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
+
+/*
+	Splits s at every separator that is not inside double quotes, at most n
+	times if n is not negative.
+*/
+
+func splitUnquoted(s string, sep byte, n int) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s) && n != 0; i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+				n--
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+/*
+	Parses a content line of a card into a property.
+
+	Returns an ErrInvalidVCard if the line has no value
+*/
+
+func parseProperty(s string, line int) (vcardProperty, error) {
+	parts := splitUnquoted(s, ':', 1)
+	if len(parts) < 2 {
+		return vcardProperty{}, fmt.Errorf("%w: line %d has no value",
+			ErrInvalidVCard, line)
+	}
+
+	p := vcardProperty{Params: make(map[string][]string), Value: parts[1]}
+
+	params := splitUnquoted(parts[0], ';', -1)
+	for _, param := range params[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 1 {
+			// 2.1 style parameters are types without a name, ie EMAIL;WORK
+			kv = []string{"TYPE", kv[0]}
+		}
+
+		key := strings.ToUpper(strings.TrimSpace(kv[0]))
+		for _, v := range splitUnquoted(kv[1], ',', -1) {
+			p.Params[key] = append(p.Params[key], strings.Trim(v, `"`))
+		}
+	}
+
+	name := params[0]
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+
+	p.Name = strings.ToUpper(strings.TrimSpace(name))
+	if p.Name == "" {
+		return vcardProperty{}, fmt.Errorf("%w: line %d has no property name",
+			ErrInvalidVCard, line)
+	}
+
+	return p, nil
+}
+
+/*
+	Reads the lines of a vCard file and unfolds them, a line that starts
+	with a space or a tab continues the line before it. Returns the unfolded
+	lines with the line number each of them starts on.
+*/
+
+func unfoldLines(rd io.Reader) ([]string, []int, error) {
+	var lines []string
+	var numbers []int
+
+	s := bufio.NewScanner(rd)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimRight(s.Text(), "\r")
+		if n == 1 {
+			l = strings.TrimPrefix(l, "\ufeff")
+		}
+
+		if len(lines) > 0 && (strings.HasPrefix(l, " ") ||
+			strings.HasPrefix(l, "\t")) {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+
+		lines = append(lines, l)
+		numbers = append(numbers, n)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return lines, numbers, nil
+}
+
+/*
+	Reads every card of a vCard file. Blank lines between cards are allowed.
+
+	Returns an ErrInvalidVCard if a card is not closed or a line is outside
+	of a card, an ErrVCardVersion if a card is not 3.0 or 4.0 and an
+	ErrNoCardsInFile if there are no cards
+*/
+
+func readVCards(rd io.Reader) ([]*vcard, error) {
+	lines, numbers, err := unfoldLines(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*vcard
+	var card *vcard
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+
+		p, err := parseProperty(l, numbers[i])
+		if err != nil {
+			return nil, err
+		}
+
+		value := strings.ToUpper(strings.TrimSpace(p.Value))
+		switch {
+		case p.Name == "BEGIN" && value == "VCARD":
+			if card != nil {
+				return nil, fmt.Errorf("%w: card on line %d is not closed",
+					ErrInvalidVCard, card.Line)
+			}
+
+			card = &vcard{Line: numbers[i]}
+		case card == nil:
+			return nil, fmt.Errorf("%w: line %d is outside of a card",
+				ErrInvalidVCard, numbers[i])
+		case p.Name == "END" && value == "VCARD":
+			cards = append(cards, card)
+			card = nil
+		case p.Name == "VERSION" && value != "3.0" && value != "4.0":
+			return nil, fmt.Errorf("%w: %v on line %d", ErrVCardVersion,
+				value, numbers[i])
+		default:
+			card.Props = append(card.Props, p)
+		}
+	}
+
+	if card != nil {
+		return nil, fmt.Errorf("%w: card on line %d is not closed",
+			ErrInvalidVCard, card.Line)
+	}
+
+	if len(cards) == 0 {
+		return nil, ErrNoCardsInFile
+	}
+
+	return cards, nil
+}
+
+/*
+	Reads the cards of a vCard file as the rows of a CSV file with a name,
+	username and email column. A row is placed at the line of the card it
+	is read from and the lines between cards are left blank, so that the
+	line of a row is that of the BEGIN:VCARD of its card.
+*/
+
+func readVCardRecords(rd io.Reader, rule UsernameRule) (*CSVHeader, [][]string, error) {
+	cards, err := readVCards(rd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := &CSVHeader{width: 3, Used: []Column{
+		{Field: "name", Header: "FN", Index: 0},
+		{Field: "username", Header: "username", Index: 1},
+		{Field: "email", Header: "EMAIL", Index: 2},
+	}}
+
+	records := make([][]string, cards[len(cards)-1].Line)
+	for _, c := range cards {
+		records[c.Line-1] = []string{c.name(), c.username(rule), c.email()}
+	}
+
+	return header, records, nil
+}
+
+/*
+	Reads a vCard 3.0 or 4.0 file and converts every card into an Entry as
+	ReadCSV does. FN is the name of the Entry, the preferred EMAIL its email
+	and X-USERNAME its username, or one derived by the rule if the card does
+	not have one. The line of a row is that of the BEGIN:VCARD of its card.
+*/
+
+func ReadVCard(rd io.Reader, rule UsernameRule) (*CSVHeader, []CSVRow, error) {
+	header, records, err := readVCardRecords(rd, rule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, readRows(header, records, 1), nil
+}
+
+/*
+	Checks every card of a vCard file as ValidateCSV does.
+*/
+
+func ValidateVCard(rd io.Reader, rule UsernameRule, existing []*db.Entry) (*ValidationReport, error) {
+	header, records, err := readVCardRecords(rd, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateRecords(header, records, 1, existing), nil
+}
+
+/*
+	Reads a vCard file and returns a slice of entries if every card is
+	valid, if not returns the RowErrors of every card that is not.
+*/
+
+func ImportVCard(rd io.Reader, rule UsernameRule) ([]*db.Entry, error) {
+	_, rows, err := ReadVCard(rd, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return rowEntries(rows)
+}
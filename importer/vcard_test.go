@@ -0,0 +1,277 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+/*
+	Cards exported by a phone and by Outlook, with folded lines, groups,
+	escapes and more than one email.
+*/
+
+var vcardFixture = strings.Join([]string{
+	"BEGIN:VCARD",
+	"VERSION:3.0",
+	"N:Doe;Jane;;;",
+	"FN:Jane Doe",
+	"EMAIL;TYPE=INTERNET,HOME:jane@home.com",
+	"item1.EMAIL;TYPE=INTERNET,pref:jane.doe@",
+	" email.com",
+	"END:VCARD",
+	"",
+	"BEGIN:VCARD",
+	"VERSION:4.0",
+	"FN:John",
+	"\t Doe",
+	"N:Doe;John;;;",
+	"X-USERNAME:jdoe",
+	"EMAIL;TYPE=work:johndoe@email.com",
+	"END:VCARD",
+	"BEGIN:VCARD",
+	"VERSION:4.0",
+	"N:O'Brien;Jim;;;",
+	"FN:Jim O\\, Brien",
+	"EMAIL;PREF=1:jim_obrien@email.com",
+	"END:VCARD",
+}, "\r\n")
+
+func TestParseUsernameRule(t *testing.T) {
+	tt := []struct {
+		input    string
+		rule     UsernameRule
+		expected error
+	}{
+		{input: "", rule: UsernameFromEmail},
+		{input: "Initial", rule: UsernameFromInitial},
+		{input: "name", rule: UsernameFromName},
+		{input: "surname", expected: ErrInvalidUsernameRule},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseUsernameRule(tc.input)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", err, tc.expected)
+			}
+
+			if got != tc.rule {
+				t.Fatalf("got: %v, expected: %v", got, tc.rule)
+			}
+		})
+	}
+}
+
+func TestParseProperty(t *testing.T) {
+	tt := []struct {
+		input    string
+		expected vcardProperty
+		err      error
+	}{
+		{
+			input: "FN:Jane Doe",
+			expected: vcardProperty{Name: "FN", Params: map[string][]string{},
+				Value: "Jane Doe"},
+		},
+		{
+			input: `item1.email;type=INTERNET,pref;LABEL="Work: HQ":jane@email.com`,
+			expected: vcardProperty{Name: "EMAIL", Params: map[string][]string{
+				"TYPE":  {"INTERNET", "pref"},
+				"LABEL": {"Work: HQ"},
+			}, Value: "jane@email.com"},
+		},
+		{
+			input: "EMAIL;WORK:jane@email.com",
+			expected: vcardProperty{Name: "EMAIL", Params: map[string][]string{
+				"TYPE": {"WORK"},
+			}, Value: "jane@email.com"},
+		},
+		{input: "Jane Doe", err: ErrInvalidVCard},
+		{input: ":Jane Doe", err: ErrInvalidVCard},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseProperty(tc.input, 1)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+
+			if tc.err == nil && !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %+v, expected: %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestReadVCard(t *testing.T) {
+	tt := []struct {
+		rule     UsernameRule
+		expected [][]string
+	}{
+		{
+			rule: UsernameFromEmail,
+			expected: [][]string{
+				{"1", "Jane Doe", "jane.doe", "jane.doe@email.com"},
+				{"10", "John Doe", "jdoe", "johndoe@email.com"},
+				{"18", "Jim O, Brien", "jim_obrien", "jim_obrien@email.com"},
+			},
+		},
+		{
+			rule: UsernameFromInitial,
+			expected: [][]string{
+				{"1", "Jane Doe", "jdoe", "jane.doe@email.com"},
+				{"10", "John Doe", "jdoe", "johndoe@email.com"},
+				{"18", "Jim O, Brien", "jobrien", "jim_obrien@email.com"},
+			},
+		},
+		{
+			rule: UsernameFromName,
+			expected: [][]string{
+				{"1", "Jane Doe", "jane.doe", "jane.doe@email.com"},
+				{"10", "John Doe", "jdoe", "johndoe@email.com"},
+				{"18", "Jim O, Brien", "jim.obrien", "jim_obrien@email.com"},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(string(tc.rule), func(t *testing.T) {
+			t.Parallel()
+
+			_, records, err := readVCardRecords(strings.NewReader(vcardFixture),
+				tc.rule)
+			if err != nil {
+				t.Fatalf("got: %v, expected: %v", err, nil)
+			}
+
+			var got [][]string
+			for i, r := range records {
+				if r != nil {
+					got = append(got, append([]string{fmt.Sprint(i + 1)}, r...))
+				}
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("rows", func(t *testing.T) {
+		_, rows, err := ReadVCard(strings.NewReader(vcardFixture),
+			UsernameFromEmail)
+		if err != nil {
+			t.Fatalf("got: %v, expected: %v", err, nil)
+		}
+
+		if len(rows) != 3 {
+			t.Fatalf("got: %v, expected: %v", len(rows), 3)
+		}
+
+		// commas are not allowed in a name
+		if rows[2].Line != 18 || !errors.Is(rows[2].Err, db.ErrInvalidName) {
+			t.Fatalf("got: %v, expected: %v", rows[2].Err, db.ErrInvalidName)
+		}
+	})
+}
+
+func TestReadVCardErrors(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		expected    error
+	}{
+		{
+			description: "card is not closed",
+			input:       "BEGIN:VCARD\nVERSION:3.0\nFN:Jane Doe\n",
+			expected:    ErrInvalidVCard,
+		},
+		{
+			description: "line outside of a card",
+			input:       "FN:Jane Doe\nBEGIN:VCARD\nEND:VCARD\n",
+			expected:    ErrInvalidVCard,
+		},
+		{
+			description: "version 2.1",
+			input:       "BEGIN:VCARD\nVERSION:2.1\nFN:Jane Doe\nEND:VCARD\n",
+			expected:    ErrVCardVersion,
+		},
+		{
+			description: "no cards",
+			input:       "\n\n",
+			expected:    ErrNoCardsInFile,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, err := ReadVCard(strings.NewReader(tc.input), UsernameFromEmail)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestImportVCard(t *testing.T) {
+	valid := "BEGIN:VCARD\nVERSION:4.0\nFN:Jane Doe\n" +
+		"EMAIL:jane.doe@email.com\nEND:VCARD\n"
+
+	tt := []struct {
+		description string
+		input       string
+		count       int
+		expected    error
+	}{
+		{description: "import valid cards", input: valid, count: 1},
+		{description: "import cards with an invalid name", input: vcardFixture,
+			expected: db.ErrInvalidName},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := ImportVCard(strings.NewReader(tc.input),
+				UsernameFromInitial)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", err, tc.expected)
+			}
+
+			if len(entries) != tc.count {
+				t.Fatalf("got: %v, expected: %v", len(entries), tc.count)
+			}
+		})
+	}
+
+	t.Run("validate cards", func(t *testing.T) {
+		existing := []*db.Entry{{Username: "jdoe", Email: "jdoe@email.com"}}
+		report, err := ValidateVCard(strings.NewReader(vcardFixture),
+			UsernameFromInitial, existing)
+		if err != nil {
+			t.Fatalf("got: %v, expected: %v", err, nil)
+		}
+
+		// jdoe is already in the table for Jane and John, Jim has a comma in the name
+		if report.Rows != 3 || report.Valid != 0 || len(report.Errors) != 3 {
+			t.Fatalf("got: %+v, expected: %v", report.Errors, "3 errors")
+		}
+	})
+}
@@ -0,0 +1,515 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+var (
+	ErrInvalidWorkbook = errors.New("file is not an xlsx workbook")
+	ErrSheetNotFound   = errors.New("sheet is not in the workbook")
+)
+
+/*
+	Types of the relationships between the parts of a workbook that are
+	needed to find its sheets, by the suffix of their URI.
+*/
+
+const (
+	officeDocumentRel = "/officeDocument"
+	worksheetRel      = "/worksheet"
+	sharedStringsRel  = "/sharedStrings"
+)
+
+/*
+	xlsxRelationships models a .rels part, which maps the ids used by a part
+	to the paths of the parts it refers to.
+*/
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Type   string `xml:"Type,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+/*
+	xlsxWorkbook models the list of sheets of xl/workbook.xml. The id of a
+	sheet is the relationship of its worksheet part.
+*/
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+/*
+	xlsxText models a shared string or an inline string, which is either
+	plain text or made of formatted runs.
+*/
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (x *xlsxText) String() string {
+	s := x.T
+	for _, r := range x.Runs {
+		s += r.T
+	}
+
+	return s
+}
+
+/*
+	xlsxSharedStrings models xl/sharedStrings.xml, the strings that cells of
+	type s refer to by index.
+*/
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+/*
+	xlsxCell is a cell of a worksheet.
+
+	R: reference of the cell ie B3, cells without one follow the previous
+	T: type of the value, s for a shared string, inlineStr for an inline
+	string, otherwise V holds the value
+*/
+
+type xlsxCell struct {
+	R  string    `xml:"r,attr"`
+	T  string    `xml:"t,attr"`
+	V  string    `xml:"v"`
+	IS *xlsxText `xml:"is"`
+}
+
+/*
+	xlsxWorksheet models the rows of a worksheet part. Rows without a number
+	follow the previous row.
+*/
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int        `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+/*
+	Workbook is an xlsx workbook whose sheets can be read like CSV files.
+
+	files: the parts of the workbook by path
+	sheets: names of the sheets in the order of the workbook
+	paths: path of the worksheet part of each sheet
+	strings: the shared strings of the workbook
+*/
+
+type Workbook struct {
+	files   map[string]*zip.File
+	sheets  []string
+	paths   map[string]string
+	strings []string
+}
+
+/*
+	Opens an xlsx workbook, which is a zip file of XML parts, and reads its
+	list of sheets and shared strings.
+
+	Returns an ErrInvalidWorkbook if a part is missing or cannot be read
+*/
+
+func OpenXLSX(ra io.ReaderAt, size int64) (*Workbook, error) {
+	z, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWorkbook, err)
+	}
+
+	w := &Workbook{files: make(map[string]*zip.File),
+		paths: make(map[string]string)}
+	for _, f := range z.File {
+		w.files[f.Name] = f
+	}
+
+	book := "xl/workbook.xml"
+	rels, err := w.relationships("")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range rels.Relationships {
+		if strings.HasSuffix(r.Type, officeDocumentRel) {
+			book = partPath("", r.Target)
+		}
+	}
+
+	var wb xlsxWorkbook
+	err = w.decode(book, &wb)
+	if err != nil {
+		return nil, err
+	}
+
+	rels, err = w.relationships(book)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := path.Dir(book)
+	targets := make(map[string]string)
+	for _, r := range rels.Relationships {
+		target := partPath(dir, r.Target)
+		switch {
+		case strings.HasSuffix(r.Type, worksheetRel):
+			targets[r.ID] = target
+		case strings.HasSuffix(r.Type, sharedStringsRel):
+			err = w.readStrings(target)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, s := range wb.Sheets {
+		if _, ok := targets[s.ID]; !ok {
+			continue
+		}
+
+		w.sheets = append(w.sheets, s.Name)
+		w.paths[s.Name] = targets[s.ID]
+	}
+
+	if len(w.sheets) == 0 {
+		return nil, fmt.Errorf("%w: there are no sheets", ErrInvalidWorkbook)
+	}
+
+	return w, nil
+}
+
+/*
+	Returns the path of a part that a relationship targets, relative to the
+	directory of the part the relationship belongs to or to the root of the
+	workbook if it starts with a slash.
+*/
+
+func partPath(dir, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+
+	return path.Join(dir, target)
+}
+
+/*
+	Decodes the XML part of the workbook at a path into v.
+*/
+
+func (w *Workbook) decode(name string, v interface{}) error {
+	f, ok := w.files[name]
+	if !ok {
+		return fmt.Errorf("%w: %v is missing", ErrInvalidWorkbook, name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWorkbook, err)
+	}
+	defer rc.Close()
+
+	err = xml.NewDecoder(rc).Decode(v)
+	if err != nil {
+		return fmt.Errorf("%w: %v: %v", ErrInvalidWorkbook, name, err)
+	}
+
+	return nil
+}
+
+/*
+	Reads the relationships of a part, or of the package if the part is
+	empty. Parts without relationships have none.
+*/
+
+func (w *Workbook) relationships(part string) (*xlsxRelationships, error) {
+	name := path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")
+	if part == "" {
+		name = "_rels/.rels"
+	}
+
+	rels := &xlsxRelationships{}
+	if _, ok := w.files[name]; !ok {
+		return rels, nil
+	}
+
+	return rels, w.decode(name, rels)
+}
+
+/*
+	Reads the shared strings of the workbook from their part.
+*/
+
+func (w *Workbook) readStrings(name string) error {
+	var sst xlsxSharedStrings
+	err := w.decode(name, &sst)
+	if err != nil {
+		return err
+	}
+
+	w.strings = make([]string, len(sst.Items))
+	for i := range sst.Items {
+		w.strings[i] = sst.Items[i].String()
+	}
+
+	return nil
+}
+
+/*
+	Returns the names of the sheets of the workbook in order.
+*/
+
+func (w *Workbook) Sheets() []string {
+	return w.sheets
+}
+
+/*
+	Limits of a sheet, a row or column past them is not in a valid workbook
+	and would have records allocate a row or column for every one before it.
+*/
+
+const (
+	maxSheetRows    = 1048576
+	maxSheetColumns = 16384
+)
+
+/*
+	Converts the letters of a cell reference, ie AB12, into the position of
+	its column from 0. Returns -1 if the reference has no letters, references
+	past the last column of a sheet give maxSheetColumns.
+*/
+
+func columnIndex(ref string) int {
+	n := 0
+	for _, c := range strings.ToUpper(ref) {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+
+		n = n*26 + int(c-'A') + 1
+		if n > maxSheetColumns {
+			return maxSheetColumns
+		}
+	}
+
+	return n - 1
+}
+
+/*
+	Returns the value of a cell as text.
+*/
+
+func (w *Workbook) cellValue(c xlsxCell) (string, error) {
+	switch c.T {
+	case "s":
+		i, err := strconv.Atoi(c.V)
+		if err != nil || i < 0 || i >= len(w.strings) {
+			return "", fmt.Errorf("%w: cell %v refers to a missing string",
+				ErrInvalidWorkbook, c.R)
+		}
+
+		return w.strings[i], nil
+	case "inlineStr":
+		if c.IS == nil {
+			return "", nil
+		}
+
+		return c.IS.String(), nil
+	}
+
+	return c.V, nil
+}
+
+/*
+	Reads the values of every row of a sheet, the first sheet if the name is
+	empty. Rows are placed by their number so that blank rows are kept and
+	the rows are as wide as their last cell.
+
+	Returns an ErrSheetNotFound if the workbook has no sheet of that name, or
+	an ErrInvalidWorkbook if a row or cell is past the limits of a sheet
+*/
+
+func (w *Workbook) records(sheet string) ([][]string, error) {
+	if sheet == "" {
+		sheet = w.sheets[0]
+	}
+
+	name, ok := w.paths[sheet]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v, sheets are %v", ErrSheetNotFound,
+			sheet, strings.Join(w.sheets, ", "))
+	}
+
+	var ws xlsxWorksheet
+	err := w.decode(name, &ws)
+	if err != nil {
+		return nil, err
+	}
+
+	var records [][]string
+	for _, row := range ws.Rows {
+		n := row.R
+		if n > maxSheetRows {
+			return nil, fmt.Errorf("%w: row %v is past the last row",
+				ErrInvalidWorkbook, n)
+		}
+
+		if n <= len(records) {
+			n = len(records) + 1
+		}
+
+		for len(records) < n {
+			records = append(records, nil)
+		}
+
+		var record []string
+		for _, c := range row.Cells {
+			i := columnIndex(c.R)
+			if i < 0 {
+				i = len(record)
+			}
+
+			if i >= maxSheetColumns {
+				return nil, fmt.Errorf("%w: cell %v is past the last column",
+					ErrInvalidWorkbook, c.R)
+			}
+
+			for len(record) <= i {
+				record = append(record, "")
+			}
+
+			record[i], err = w.cellValue(c)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		records[n-1] = record
+	}
+
+	return records, nil
+}
+
+/*
+	Reads the header of a sheet, its first row that is not blank, and the
+	rows that follow it. Rows shorter than the header are padded, as cells
+	that are empty are left out of a sheet, and cells past the last column
+	of the header are dropped as a column without a header is never read,
+	ie notes beside a row.
+
+	Returns the line number of the first row after the header, or an error
+	if the header is not valid or there are no rows
+*/
+
+func (w *Workbook) readSheet(sheet string, mapping ColumnMapping) (*CSVHeader, [][]string, int, error) {
+	records, err := w.records(sheet)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	start := 0
+	for start < len(records) && isBlank(records[start]) {
+		start++
+	}
+
+	if start == len(records) {
+		return nil, nil, 0, ErrNoRowsInFile
+	}
+
+	header, err := parseCSVHeader(records[start], mapping)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	records = records[start+1:]
+	for i := range records {
+		if len(records[i]) > header.width {
+			records[i] = records[i][:header.width]
+		}
+
+		for len(records[i]) > 0 && len(records[i]) < header.width {
+			records[i] = append(records[i], "")
+		}
+	}
+
+	blank := true
+	for _, r := range records {
+		blank = blank && isBlank(r)
+	}
+
+	if blank {
+		return nil, nil, 0, ErrNoRowsInFile
+	}
+
+	return header, records, start + 2, nil
+}
+
+/*
+	Reads a sheet of the workbook, the first one if the name is empty, and
+	converts every row into an Entry as ReadCSV does. The line of a row is
+	its number in the sheet.
+*/
+
+func (w *Workbook) ReadSheet(sheet string, mapping ColumnMapping) (*CSVHeader, []CSVRow, error) {
+	header, records, first, err := w.readSheet(sheet, mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, readRows(header, records, first), nil
+}
+
+/*
+	Checks every row of a sheet of the workbook, the first one if the name
+	is empty, as ValidateCSV does.
+*/
+
+func (w *Workbook) ValidateSheet(sheet string, mapping ColumnMapping, existing []*db.Entry) (*ValidationReport, error) {
+	header, records, first, err := w.readSheet(sheet, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	return validateRecords(header, records, first, existing), nil
+}
+
+/*
+	Reads a sheet of an xlsx workbook, the first one if the name is empty,
+	and returns a slice of entries if they are all valid, if not returns the
+	RowErrors of every row that is not.
+*/
+
+func ImportXLSX(ra io.ReaderAt, size int64, sheet string) ([]*db.Entry, error) {
+	w, err := OpenXLSX(ra, size)
+	if err != nil {
+		return nil, err
+	}
+
+	_, rows, err := w.ReadSheet(sheet, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return rowEntries(rows)
+}
@@ -0,0 +1,302 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+/*
+	Function to open an xlsx workbook written by SetupXLSX.
+*/
+
+func openWorkbook(t *testing.T, name string) *Workbook {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := OpenXLSX(f, info.Size())
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+
+	return w
+}
+
+func TestColumnIndex(t *testing.T) {
+	tt := []struct {
+		input    string
+		expected int
+	}{
+		{input: "A1", expected: 0},
+		{input: "c12", expected: 2},
+		{input: "Z3", expected: 25},
+		{input: "AB12", expected: 27},
+		{input: "12", expected: -1},
+		{input: "XFD1", expected: 16383},
+		{input: "XFE1", expected: 16384},
+		{input: "ZZZZZZZZZZZZZZZ1", expected: 16384},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			got := columnIndex(tc.input)
+			if got != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRecordsLimits(t *testing.T) {
+	tt := []struct {
+		description string
+		rows        string
+		expected    error
+	}{
+		{
+			description: "last row and column",
+			rows:        `<row r="1048576"><c r="XFD1048576"><v>1</v></c></row>`,
+		},
+		{
+			description: "row past the last row",
+			rows:        `<row r="1048577"><c r="A1048577"><v>1</v></c></row>`,
+			expected:    ErrInvalidWorkbook,
+		},
+		{
+			description: "cell past the last column",
+			rows:        `<row r="1"><c r="XFE1"><v>1</v></c></row>`,
+			expected:    ErrInvalidWorkbook,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var b bytes.Buffer
+			z := zip.NewWriter(&b)
+			f, err := z.Create("xl/worksheets/sheet1.xml")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			io.WriteString(f, `<worksheet><sheetData>`+tc.rows+
+				`</sheetData></worksheet>`)
+			if err := z.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := &Workbook{
+				files:  map[string]*zip.File{zr.File[0].Name: zr.File[0]},
+				sheets: []string{"Sheet1"},
+				paths:  map[string]string{"Sheet1": zr.File[0].Name},
+			}
+
+			_, err = w.records("")
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestReadSheet(t *testing.T) {
+	notes := [][]string{
+		{"not", "an", "address", "book"},
+	}
+
+	staff := [][]string{
+		{},
+		{"Display Name", "Employee ID", "sAMAccountName", "mail", "fax_number"},
+		{"Jane Doe", "1001", "janedoe", "janedoe@email.com", "5550100"},
+		{},
+		{"John Doe", "1002", "johndoe", "johndoe.email.com", ""},
+		{"Jim Doe", "", "jimdoe", "jimdoe@email.com"},
+		{"Jill Doe", "1004", "jilldoe", "jilldoe@email.com", "", "", "on leave"},
+	}
+
+	name, teardown := SetupXLSX(t, []string{"Notes", "Staff & Contractors"},
+		notes, staff)
+	defer teardown()
+
+	w := openWorkbook(t, name)
+
+	sheets := []string{"Notes", "Staff & Contractors"}
+	if !reflect.DeepEqual(w.Sheets(), sheets) {
+		t.Fatalf("got: %v, expected: %v", w.Sheets(), sheets)
+	}
+
+	header, rows, err := w.ReadSheet("Staff & Contractors", nil)
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+
+	if !reflect.DeepEqual(header.Ignored, []string{"Employee ID"}) {
+		t.Fatalf("got: %v, expected: %v", header.Ignored, "Employee ID")
+	}
+
+	tt := []struct {
+		description string
+		row         CSVRow
+		line        int
+		username    string
+		expected    error
+	}{
+		{description: "shared strings and numbers", row: rows[0], line: 3,
+			username: "janedoe"},
+		{description: "invalid email after a blank row", row: rows[1], line: 5,
+			expected: db.ErrInvalidEmail},
+		{description: "empty cells are left out", row: rows[2], line: 6,
+			username: "jimdoe"},
+		{description: "cells without a header are dropped", row: rows[3],
+			line: 7, username: "jilldoe"},
+	}
+
+	if len(rows) != len(tt) {
+		t.Fatalf("got: %v, expected: %v", len(rows), len(tt))
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.row.Line != tc.line {
+				t.Fatalf("got: %v, expected: %v", tc.row.Line, tc.line)
+			}
+
+			if !errors.Is(tc.row.Err, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", tc.row.Err, tc.expected)
+			}
+
+			if tc.expected == nil && tc.row.Entry.Username != tc.username {
+				t.Fatalf("got: %v, expected: %v", tc.row.Entry.Username,
+					tc.username)
+			}
+		})
+	}
+
+	if rows[0].Entry.Fax.Number != "5550100" {
+		t.Fatalf("got: %v, expected: %v", rows[0].Entry.Fax.Number, "5550100")
+	}
+
+	t.Run("sheet errors", func(t *testing.T) {
+		_, _, err := w.ReadSheet("Missing", nil)
+		if !errors.Is(err, ErrSheetNotFound) {
+			t.Fatalf("got: %v, expected: %v", err, ErrSheetNotFound)
+		}
+
+		// the first sheet is read by default
+		_, _, err = w.ReadSheet("", nil)
+		if !errors.Is(err, ErrInvalidHeader) {
+			t.Fatalf("got: %v, expected: %v", err, ErrInvalidHeader)
+		}
+	})
+
+	t.Run("validate sheet", func(t *testing.T) {
+		existing := []*db.Entry{{Username: "jimdoe", Email: "jim@email.com"}}
+		report, err := w.ValidateSheet("Staff & Contractors", nil, existing)
+		if err != nil {
+			t.Fatalf("got: %v, expected: %v", err, nil)
+		}
+
+		if report.Rows != 4 || report.Valid != 2 || len(report.Errors) != 2 {
+			t.Fatalf("got: %+v, expected: %v", report, "2 of 4 rows valid")
+		}
+	})
+}
+
+func TestImportXLSX(t *testing.T) {
+	valid := [][]string{
+		{"name", "username", "email"},
+		{"Jane Doe", "janedoe", "janedoe@email.com"},
+		{"John Doe", "johndoe", "johndoe@email.com"},
+	}
+
+	invalid := [][]string{
+		{"name", "username", "email"},
+		{"Jane Doe", "janedoe", "janedoe.email.com"},
+	}
+
+	empty := [][]string{
+		{"name", "username", "email"},
+	}
+
+	tt := []struct {
+		description string
+		data        [][]string
+		count       int
+		expected    error
+	}{
+		{description: "import valid sheet", data: valid, count: 2},
+		{description: "import sheet with an invalid email", data: invalid,
+			expected: db.ErrInvalidEmail},
+		{description: "import sheet without rows", data: empty,
+			expected: ErrNoRowsInFile},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			name, teardown := SetupXLSX(t, []string{"Sheet1"}, tc.data)
+			defer teardown()
+
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			info, err := f.Stat()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := ImportXLSX(f, info.Size(), "Sheet1")
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", err, tc.expected)
+			}
+
+			if len(entries) != tc.count {
+				t.Fatalf("got: %v, expected: %v", len(entries), tc.count)
+			}
+		})
+	}
+
+	t.Run("file that is not a workbook", func(t *testing.T) {
+		r, teardown := SetupCSV(t, valid)
+		defer teardown()
+
+		f := r.(*os.File)
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ImportXLSX(f, info.Size(), "")
+		if !errors.Is(err, ErrInvalidWorkbook) {
+			t.Fatalf("got: %v, expected: %v", err, ErrInvalidWorkbook)
+		}
+	})
+}
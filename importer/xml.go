@@ -0,0 +1,244 @@
+package importer
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+var (
+	ErrInvalidAddressBook = errors.New("file is not a kyocera address book")
+	ErrNoContactsInFile   = errors.New("there are no contacts in this file")
+)
+
+const (
+	addressBookPrefix = "DeviceAddressBook"
+	contactType       = "Contact"
+	oneTouchKeyType   = "OneTouchKey"
+	emailAddressType  = "EMAIL"
+	defaultSMBPort    = "445"
+	defaultFTPPort    = "21"
+)
+
+/*
+	xmlAddressBook models the parts of a Kyocera DeviceAddressBook XML file
+	that are needed to rebuild Entries. The version suffix of the root element
+	is not checked, only that it is a DeviceAddressBook.
+
+	XMLName: name of the root element ie DeviceAddressBook_v5_2
+	Items: every Item element, contacts and OneTouchKeys alike
+*/
+
+type xmlAddressBook struct {
+	XMLName xml.Name
+	Items   []xmlItem `xml:"Item"`
+}
+
+/*
+	xmlItem holds the attributes of an Item element that the importer uses.
+	Contacts and OneTouchKeys share the Item element so both are decoded into
+	the same struct and told apart by Type.
+*/
+
+type xmlItem struct {
+	Id             int64  `xml:"Id,attr"`
+	Type           string `xml:"Type,attr"`
+	DisplayName    string `xml:"DisplayName,attr"`
+	MailAddress    string `xml:"MailAddress,attr"`
+	SmbHostName    string `xml:"SmbHostName,attr"`
+	SmbPath        string `xml:"SmbPath,attr"`
+	SmbLoginName   string `xml:"SmbLoginName,attr"`
+	SmbLoginPasswd string `xml:"SmbLoginPasswd,attr"`
+	SmbPort        string `xml:"SmbPort,attr"`
+	FtpHostName    string `xml:"FtpHostName,attr"`
+	FtpPath        string `xml:"FtpPath,attr"`
+	FtpLoginName   string `xml:"FtpLoginName,attr"`
+	FtpLoginPasswd string `xml:"FtpLoginPasswd,attr"`
+	FtpPort        string `xml:"FtpPort,attr"`
+	FaxNumber      string `xml:"FaxNumber,attr"`
+	FaxSubaddress  string `xml:"FaxSubaddress,attr"`
+	FaxCommSpeed   string `xml:"FaxCommSpeed,attr"`
+	FaxECM         string `xml:"FaxECM,attr"`
+	FaxEncryption  string `xml:"FaxEncryption,attr"`
+	FaxEncryptKey  string `xml:"FaxEncryptKeyNumber,attr"`
+	InetFAXAddr    string `xml:"InetFAXAddr,attr"`
+	AddressId      int64  `xml:"AddressId,attr"`
+	AddressType    string `xml:"AddressType,attr"`
+}
+
+/*
+	Returns the optional Entry fields of a contact Item keyed by their names in
+	db.DestinationFields. The SMB and FTP fields are only returned when the
+	contact has a host for them as their ports are always present. Ports and
+	fax settings are only returned when they differ from the scanner's
+	defaults.
+*/
+
+func contactFields(item xmlItem) map[string]string {
+	fields := make(map[string]string)
+	if item.SmbHostName != "" {
+		fields["smb_host"] = item.SmbHostName
+		fields["smb_path"] = item.SmbPath
+		fields["smb_login"] = item.SmbLoginName
+		fields["smb_password"] = item.SmbLoginPasswd
+		if item.SmbPort != defaultSMBPort {
+			fields["smb_port"] = item.SmbPort
+		}
+	}
+
+	if item.FtpHostName != "" {
+		fields["ftp_host"] = item.FtpHostName
+		fields["ftp_path"] = item.FtpPath
+		fields["ftp_login"] = item.FtpLoginName
+		fields["ftp_password"] = item.FtpLoginPasswd
+		if item.FtpPort != defaultFTPPort {
+			fields["ftp_port"] = item.FtpPort
+		}
+	}
+
+	fields["ifax_address"] = item.InetFAXAddr
+	if item.FaxNumber != "" {
+		fields["fax_number"] = item.FaxNumber
+		fields["fax_subaddress"] = item.FaxSubaddress
+		if item.FaxCommSpeed != db.DefaultFaxCommSpeed {
+			fields["fax_speed"] = item.FaxCommSpeed
+		}
+
+		if item.FaxECM != db.DefaultFaxECM {
+			fields["fax_ecm"] = item.FaxECM
+		}
+
+		if item.FaxEncryption != db.DefaultFaxEncryption {
+			fields["fax_encryption"] = item.FaxEncryption
+			fields["fax_encryption_key"] = item.FaxEncryptKey
+		}
+	}
+
+	return fields
+}
+
+/*
+	Derives a username for a contact from the local part of its email address
+	as Kyocera address books do not store usernames.
+*/
+
+func usernameFromEmail(email string) string {
+	i := strings.Index(email, "@")
+	if i < 0 {
+		return email
+	}
+
+	return email[:i]
+}
+
+/*
+	Convert a contact Item into an Entry.
+*/
+
+func contactToEntry(item xmlItem) (*db.Entry, error) {
+	e, err := db.NewEntry(item.DisplayName, usernameFromEmail(item.MailAddress),
+		item.MailAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := contactFields(item)
+	for _, key := range db.DestinationFields {
+		value := fields[key]
+		if value == "" {
+			continue
+		}
+
+		err = e.SetField(key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+/*
+	Reads a Kyocera address book from an io.Reader as it is laid out on the
+	scanner and returns an entry for each of its contacts if they are all
+	valid, if not returns an error. The entries keep their contact Id as
+	their number and the Id of their email OneTouchKey as their slot, or
+	db.NoSlot if they do not have one. Usernames are derived from the email
+	addresses.
+*/
+
+func ReadAddressBook(rd io.Reader) ([]*db.Entry, error) {
+	var book xmlAddressBook
+	err := xml.NewDecoder(rd).Decode(&book)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(book.XMLName.Local, addressBookPrefix) {
+		return nil, ErrInvalidAddressBook
+	}
+
+	slots := make(map[int64]int64)
+	for _, item := range book.Items {
+		if item.Type == oneTouchKeyType && item.AddressType == emailAddressType {
+			slots[item.AddressId] = item.Id
+		}
+	}
+
+	var entries []*db.Entry
+	for _, item := range book.Items {
+		if item.Type != contactType {
+			continue
+		}
+
+		e, err := contactToEntry(item)
+		if err != nil {
+			return nil, fmt.Errorf("%w on contact %d", err, item.Id)
+		}
+
+		e.Number = item.Id
+		e.Slot = db.NoSlot
+		if slot, ok := slots[item.Id]; ok {
+			e.Slot = slot
+		}
+
+		entries = append(entries, e)
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrNoContactsInFile
+	}
+
+	return entries, nil
+}
+
+/*
+	Reads a Kyocera address book from an io.Reader and returns a slice of
+	entries for its contacts if they are all valid, if not returns an error.
+	Contacts that do not have an email OneTouchKey are returned in the
+	unmatched slice as well. Groups and their OneTouchKeys are not imported.
+	The entries are not given numbers or slots so that they are assigned by
+	the table they are inserted into.
+*/
+
+func ImportXML(rd io.Reader) (entries, unmatched []*db.Entry, err error) {
+	entries, err = ReadAddressBook(rd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range entries {
+		if e.Slot == db.NoSlot {
+			unmatched = append(unmatched, e)
+		}
+
+		e.Number = 0
+		e.Slot = 0
+	}
+
+	return entries, unmatched, nil
+}
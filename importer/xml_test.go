@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+	"github.com/tweekes0/kyocera-ab-tool/exporter"
+)
+
+const (
+	xml1 = `<DeviceAddressBook_v5_2>
+    <!--Contact List-->
+    <Item Id="1" Type="Contact" DisplayName="Jane Doe" MailAddress="janedoe@email.com"/>
+    <Item Id="2" Type="Contact" DisplayName="John Doe" MailAddress="johndoe@email.com"/>
+    <!--Email One Touch Keys-->
+    <Item Id="1" AddressId="1" Type="OneTouchKey" AddressType="EMAIL" DisplayName="Jane Doe"/>
+</DeviceAddressBook_v5_2>`
+
+	xml2 = `<Contacts>
+    <Item Id="1" Type="Contact" DisplayName="Jane Doe" MailAddress="janedoe@email.com"/>
+</Contacts>`
+
+	xml3 = `<DeviceAddressBook_v5_2>
+    <Item Id="1" Type="Contact" DisplayName="Jane Doe" MailAddress="janedoe@email.com"/>
+    <Item Id="2" Type="Contact" DisplayName="John Doe" MailAddress="johndoe.email.com"/>
+</DeviceAddressBook_v5_2>`
+
+	xml4 = `<DeviceAddressBook_v5_2>
+    <Item Id="1" AddressId="1" Type="OneTouchKey" AddressType="EMAIL" DisplayName="Jane Doe"/>
+</DeviceAddressBook_v5_2>`
+
+	xml5 = `<DeviceAddressBook_v5_2>
+    <Item Id="1" Type="Contact" DisplayName="Jane Doe" MailAddress="janedoe@email.com"/>
+    <Item Id="1" Type="Group" DisplayName="Sales" DisplayNameKana="Sales">
+        <Member ContactId="1" AddressType="EMAIL"/>
+    </Item>
+    <Item Id="1" AddressId="1" Type="OneTouchKey" AddressType="GROUP" DisplayName="Sales"/>
+</DeviceAddressBook_v5_2>`
+)
+
+func TestUsernameFromEmail(t *testing.T) {
+	tt := []struct {
+		description string
+		input       string
+		expected    string
+	}{
+		{
+			description: "email address",
+			input:       "jane.doe@email.com",
+			expected:    "jane.doe",
+		},
+		{
+			description: "string without an @",
+			input:       "janedoe",
+			expected:    "janedoe",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			got := usernameFromEmail(tc.input)
+			if got != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestImportXML(t *testing.T) {
+	_, _, err1 := ImportXML(strings.NewReader(xml1))
+	_, _, err2 := ImportXML(strings.NewReader(xml2))
+	_, _, err3 := ImportXML(strings.NewReader(xml3))
+	_, _, err4 := ImportXML(strings.NewReader(xml4))
+
+	tt := []struct {
+		description string
+		got         error
+		expected    error
+	}{
+		{
+			description: "import valid address book",
+			got:         err1,
+			expected:    nil,
+		},
+		{
+			description: "import xml that is not an address book",
+			got:         err2,
+			expected:    ErrInvalidAddressBook,
+		},
+		{
+			description: "import address book without contacts",
+			got:         err4,
+			expected:    ErrNoContactsInFile,
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			if !errors.Is(tc.got, tc.expected) {
+				t.Fatalf("got: %v, expected: %v", tc.got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("import address book with an invalid contact", func(t *testing.T) {
+		expected := "email is not valid on contact 2"
+		if err3 == nil || err3.Error() != expected {
+			t.Fatalf("got: %v, expected: %v", err3, expected)
+		}
+
+		if !errors.Is(err3, db.ErrInvalidEmail) {
+			t.Fatalf("got: %v, expected: %v", err3, db.ErrInvalidEmail)
+		}
+	})
+
+	t.Run("contacts without one touch keys are unmatched", func(t *testing.T) {
+		entries, unmatched, err := ImportXML(strings.NewReader(xml1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("got: %v, expected: %v", len(entries), 2)
+		}
+
+		if len(unmatched) != 1 || unmatched[0].Username != "johndoe" {
+			t.Fatalf("got: %v, expected: %v", unmatched, "[johndoe]")
+		}
+	})
+
+	t.Run("group one touch keys do not match contacts", func(t *testing.T) {
+		entries, unmatched, err := ImportXML(strings.NewReader(xml5))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) != 1 {
+			t.Fatalf("got: %v, expected: %v", len(entries), 1)
+		}
+
+		if len(unmatched) != 1 || unmatched[0].Username != "janedoe" {
+			t.Fatalf("got: %v, expected: %v", unmatched, "[janedoe]")
+		}
+	})
+
+	t.Run("import an exported address book", func(t *testing.T) {
+		e1, _ := db.NewEntry("jane doe", "janedoe", "janedoe@email.com")
+		e2, _ := db.NewEntry("john doe", "johndoe", "johndoe@email.com")
+		e2.SMB = db.SMBDestination{Host: "fileserver", Path: `scans\johndoe`}
+		e2.FTP = db.FTPDestination{Host: "ftpserver", Path: "/johndoe",
+			Port: 2121}
+		e2.Fax = db.FaxDestination{Number: "555-0100", CommSpeed: "BPS_9600",
+			IFaxAddress: "fax@email.com"}
+		expected := []*db.Entry{e1, e2}
+
+		book, err := exporter.ExportAddressBook(expected, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := exporter.ElementToString(book)
+		got, unmatched, err := ImportXML(strings.NewReader(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("got: %v, expected: %v", got, expected)
+		}
+
+		if len(unmatched) != 0 {
+			t.Fatalf("got: %v, expected: %v", unmatched, []*db.Entry{})
+		}
+	})
+}
+
+func TestReadAddressBook(t *testing.T) {
+	book := `<DeviceAddressBook_v5_2>
+    <Item Id="4" Type="Contact" DisplayName="Jane Doe" MailAddress="janedoe@email.com"/>
+    <Item Id="7" Type="Contact" DisplayName="John Doe" MailAddress="johndoe@email.com"/>
+    <Item Id="1" Type="Group" DisplayName="Sales" DisplayNameKana="Sales">
+        <Member ContactId="7" AddressType="EMAIL"/>
+    </Item>
+    <Item Id="3" AddressId="4" Type="OneTouchKey" AddressType="EMAIL" DisplayName="Jane Doe"/>
+    <Item Id="5" AddressId="7" Type="OneTouchKey" AddressType="SMB" DisplayName="John Doe"/>
+    <Item Id="7" AddressId="1" Type="OneTouchKey" AddressType="GROUP" DisplayName="Sales"/>
+</DeviceAddressBook_v5_2>`
+
+	entries, err := ReadAddressBook(strings.NewReader(book))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e1, _ := db.NewEntry("jane doe", "janedoe", "janedoe@email.com")
+	e1.Number, e1.Slot = 4, 3
+	e2, _ := db.NewEntry("john doe", "johndoe", "johndoe@email.com")
+	e2.Number, e2.Slot = 7, db.NoSlot
+	expected := []*db.Entry{e1, e2}
+
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("got: %v, expected: %v", entries, expected)
+	}
+
+	_, err = ReadAddressBook(strings.NewReader(xml2))
+	if !errors.Is(err, ErrInvalidAddressBook) {
+		t.Fatalf("got: %v, expected: %v", err, ErrInvalidAddressBook)
+	}
+}
@@ -2,22 +2,46 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 
+	"github.com/chzyer/readline"
 	"github.com/tweekes0/kyocera-ab-tool/db"
 	"github.com/tweekes0/kyocera-ab-tool/prompt"
 )
 
 const (
-	DB_DRIVER    = "sqlite3"    // Database driver
-	DB_FILENAME  = "sqlite.db"  // Database filename
-	DATABASE_DIR = "./Database" // Database directory
+	DB_DRIVER     = "sqlite3"           // Database driver
+	DB_FILENAME   = "sqlite.db"         // Database filename
+	JSON_FILENAME = "address_book.json" // JSON backend filename
+	DATABASE_DIR  = "./Database"        // Database directory
 )
 
 func main() {
+	// SQLite is used by default unless the binary was built without CGO
+	defaultBackend := "sqlite"
+	if !db.SQLiteAvailable {
+		defaultBackend = "json"
+	}
+
+	backend := flag.String("backend", defaultBackend,
+		"storage backend to use, sqlite or json")
+	keepGoing := flag.Bool("keep-going", false,
+		"keep running piped commands after one fails")
+	flag.Parse()
+	args := flag.Args()
+
+	// Commands given as arguments are ran non-interactively, so messages are
+	// kept off of stdout to leave it for the command's output
+	out := os.Stdout
+	if len(args) > 0 {
+		out = os.Stderr
+	}
+
 	// Create the Database directory if it doesn't exist
 	_, err := os.Stat(DATABASE_DIR)
 	if os.IsNotExist(err) {
@@ -26,19 +50,58 @@ func main() {
 		}
 
 		msg := fmt.Sprintf("Creating the %v directory", DATABASE_DIR)
-		prompt.OutputMessage(os.Stdout, '!', msg)
+		prompt.OutputMessage(out, '!', msg)
+	}
+
+	var r db.AddressBookRepository
+	switch *backend {
+	case "sqlite":
+		r = openSQLite(out)
+	case "json":
+		r, err = db.NewJSONRepository(filepath.Join(DATABASE_DIR, JSON_FILENAME))
+		errChecker(err)
+	default:
+		log.Fatalf("unknown backend: %q", *backend)
 	}
 
+	err = r.Initialize()
+	errChecker(err)
+
+	// Run a single command when arguments are given
+	if len(args) > 0 {
+		os.Exit(prompt.Run(r, args, os.Stdout, os.Stderr))
+	}
+
+	// Run the piped commands as a script, stopping at the first failure
+	// unless -keep-going is given
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		if !prompt.Script(r, os.Stdin, os.Stdout, *keepGoing) {
+			os.Exit(prompt.ExitError)
+		}
+
+		return
+	}
+
+	// CLI application
+	prompt.Prompt(r, os.Stdin, os.Stdout)
+}
+
+/*
+	Creates the SQLite database file if it doesn't exist and returns a
+	repository for it.
+*/
+
+func openSQLite(out io.Writer) *db.SQLiteRepository {
 	// Create path to database in the Database directory
 	db_path := filepath.Join(DATABASE_DIR, DB_FILENAME)
 
-	_, err = os.Stat(db_path)
+	_, err := os.Stat(db_path)
 	if os.IsNotExist(err) {
 		_, err := os.Create(db_path)
 		errChecker(err)
 
 		msg := "Creating database file"
-		prompt.OutputMessage(os.Stdout, '!', msg)
+		prompt.OutputMessage(out, '!', msg)
 	}
 
 	// Create a reference to a SQL database
@@ -47,15 +110,11 @@ func main() {
 		log.Fatalf("Could not open database: %q", err)
 	}
 
-	// Create sqlite repository and initialize it
+	// Create sqlite repository
 	r, err := db.NewSQLiteRepository(sqlite)
 	errChecker(err)
 
-	err = r.Initialize()
-	errChecker(err)
-
-	// CLI application
-	prompt.Prompt(r, os.Stdin, os.Stdout)
+	return r
 }
 
 func errChecker(e error) {
@@ -0,0 +1,1081 @@
+package prompt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+	"github.com/tweekes0/kyocera-ab-tool/exporter"
+	"github.com/tweekes0/kyocera-ab-tool/importer"
+)
+
+/*
+	Exit codes returned by Run.
+		ExitOK indicates the command succeeded
+		ExitError indicates the command failed
+		ExitUsage indicates the command or its flags were not valid
+*/
+
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+/*
+	Location of the SQLite database that the migrate command copies from when
+	no path is given.
+*/
+
+const DefaultSQLitePath = "./Database/sqlite.db"
+
+var (
+	ErrUnknownFormat   = errors.New("file format is not valid")
+	ErrInvalidOnError  = errors.New("on-error must be stop or continue")
+	ErrNoSQLite        = errors.New("migrate reads a sqlite database, which needs a binary built with CGO")
+	ErrInvalidMode     = errors.New("mode must be abort, skip, upsert, sync or validate")
+	ErrInvalidLDAPMode = errors.New("mode must be sync, upsert, skip or validate")
+	ErrXMLImportFlag   = errors.New("xml address books are imported whole, flag cannot be given")
+)
+
+/*
+	cliResult is written to stdout as JSON after a command is ran
+	non-interactively so that scripts can parse the outcome.
+
+	Command: name of the command that was ran
+	Table: the table the command was ran against
+	OK: whether the command succeeded
+	Error: reason the command failed
+	Count: number of entries or tables affected or listed
+	File: path of the file that was read or written
+	Tables: tables listed by list-tables
+	Users: entries listed by show-users or added by add-user, with their
+	passwords masked
+	Unmatched: usernames of imported contacts without a OneTouchKey
+	Lines: outcome of each command ran by script
+	Groups: groups listed by show-groups or created by create-group
+	Slots: one touch key slots listed by show-slots
+	Collisions: slots listed by show-slots that are used more than once
+	Diff: users added, removed, modified or moved as listed by diff
+	Merge: what happened to each user of the source table of merge
+	Rows: what happened to each row of a csv file imported by import
+	Columns: the columns of a csv file imported by import that were read
+	Issues: problems found in a csv file by import in validate mode
+	Warning: a caution about an operation that succeeded, ie exporting a
+	schema version that has not been checked against a device
+*/
+
+type cliResult struct {
+	Command   string       `json:"command"`
+	Table     string       `json:"table,omitempty"`
+	OK        bool         `json:"ok"`
+	Error     string       `json:"error,omitempty"`
+	Count     int          `json:"count"`
+	File      string       `json:"file,omitempty"`
+	Tables    []string     `json:"tables,omitempty"`
+	Users     []*db.Entry  `json:"users,omitempty"`
+	Unmatched []string     `json:"unmatched,omitempty"`
+	Lines     []scriptLine `json:"lines,omitempty"`
+	Groups    []cliGroup   `json:"groups,omitempty"`
+
+	Slots      []slotRow           `json:"slots,omitempty"`
+	Collisions []db.SlotCollision  `json:"collisions,omitempty"`
+	Diff       *db.TableDiff       `json:"diff,omitempty"`
+	Merge      *db.MergeReport     `json:"merge,omitempty"`
+	Rows       []importLine        `json:"rows,omitempty"`
+	Columns    *importer.CSVHeader `json:"columns,omitempty"`
+	Issues     importer.RowErrors  `json:"issues,omitempty"`
+	Warning    string              `json:"warning,omitempty"`
+}
+
+/*
+	Returns copies of entries with their passwords masked as they are
+	written in a cliResult. The JSON of db.Entry is also how the json backend
+	stores them so the passwords cannot be left out of it.
+*/
+
+func maskedUsers(entries []*db.Entry) []*db.Entry {
+	var masked []*db.Entry
+	for _, e := range entries {
+		masked = append(masked, e.Masked())
+	}
+
+	return masked
+}
+
+/*
+	cliGroup is a group as it is written in a cliResult, with the usernames
+	of its members.
+*/
+
+type cliGroup struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+/*
+	cliOptions holds the values of the flags given to a command, keyed by the
+	flag's name.
+*/
+
+type cliOptions map[string]string
+
+/*
+	cliCommand describes a non-interactive command.
+
+	description: summary printed in the usage
+	flags: names of the flags from cliFlags the command accepts
+	required: flags that must be given a value
+	run: operation that fills in the result or returns why it failed
+*/
+
+type cliCommand struct {
+	description string
+	flags       []string
+	required    []string
+	run         func(r db.AddressBookRepository, o cliOptions, res *cliResult) error
+}
+
+/*
+	Descriptions of every flag a command can accept.
+*/
+
+var cliFlags = map[string]string{
+	"table":  "table to run the command against",
+	"to":     "new name of the table",
+	"file":   "path of the file to import",
+	"format": "format of the file to import, csv, xlsx, vcf or xml (default: file extension)",
+	"sheet":  "sheet of an xlsx workbook to import (default: first sheet)",
+	"config": "path of the ldap config file",
+	"derive": "rule for the usernames of vcard contacts without one, email, initial or name (default: email)",
+	"out":    "path of the xml file to export to (default: Address Books directory)",
+	"schema": "address book schema version, one of " +
+		strings.Join(exporter.SchemaVersions(), ", ") + " (default: " +
+		exporter.DefaultSchema + ")",
+	"user":     "user fields 'NAME,USERNAME,EMAIL[,FIELD=VALUE...]'",
+	"username": "username of the user",
+	"group":    "name of the group",
+	"slot":     "one touch key slot, 1-" + fmt.Sprint(db.MaxSlot) + " or none",
+	"with":     "username of the user to swap slots with",
+	"on-error": "stop or continue running the script after a command fails (default: stop)",
+	"from":     "path of the sqlite database to copy (default: " + DefaultSQLitePath + ")",
+	"against":  "exported xml file or table to compare the table against",
+	"source":   "table whose users are merged into the table",
+	"strategy": "skip, overwrite or rename users whose username or email is taken (default: skip)",
+	"map":      "columns of a csv file to read fields from 'FIELD=COLUMN[,FIELD=COLUMN...]'",
+	"mode":     "abort the import or skip the rows of a csv file that cannot be added, upsert or sync the users by username, or validate the file without importing it (default: abort)",
+}
+
+/*
+	Map of the non-interactive commands. Each one runs the same operations as
+	its REPL counterpart.
+*/
+
+var cliCommands = map[string]cliCommand{
+	"list-tables": {
+		description: "list all tables",
+		run:         cliListTables,
+	},
+	"create-table": {
+		description: "creates a new table",
+		flags:       []string{"table"},
+		required:    []string{"table"},
+		run:         cliCreateTable,
+	},
+	"delete-table": {
+		description: "deletes the specified table",
+		flags:       []string{"table"},
+		required:    []string{"table"},
+		run:         cliDeleteTable,
+	},
+	"copy-table": {
+		description: "copies the table with its users, groups and reserved slots to a new table",
+		flags:       []string{"table", "to"},
+		required:    []string{"table", "to"},
+		run:         cliCopyTable,
+	},
+	"rename-table": {
+		description: "renames the table, the default table cannot be renamed",
+		flags:       []string{"table", "to"},
+		required:    []string{"table", "to"},
+		run:         cliRenameTable,
+	},
+	"clear-table": {
+		description: "clears all users from the table",
+		flags:       []string{"table"},
+		run:         cliClearTable,
+	},
+	"show-users": {
+		description: "show all the users in the table",
+		flags:       []string{"table"},
+		run:         cliShowUsers,
+	},
+	"add-user": {
+		description: "add user to the table",
+		flags:       []string{"table", "user"},
+		required:    []string{"user"},
+		run:         cliAddUser,
+	},
+	"update-user": {
+		description: "update user in the table",
+		flags:       []string{"table", "username", "user"},
+		required:    []string{"username", "user"},
+		run:         cliUpdateUser,
+	},
+	"delete-user": {
+		description: "delete a single user from the table",
+		flags:       []string{"table", "username"},
+		required:    []string{"username"},
+		run:         cliDeleteUser,
+	},
+	"create-group": {
+		description: "creates a new group in the table",
+		flags:       []string{"table", "group"},
+		required:    []string{"group"},
+		run:         cliCreateGroup,
+	},
+	"add-to-group": {
+		description: "add user to a group of the table",
+		flags:       []string{"table", "group", "username"},
+		required:    []string{"group", "username"},
+		run:         cliAddToGroup,
+	},
+	"show-groups": {
+		description: "show all the groups in the table and their members",
+		flags:       []string{"table"},
+		run:         cliShowGroups,
+	},
+	"move-slot": {
+		description: "move the one touch key of a user to a slot",
+		flags:       []string{"table", "username", "slot"},
+		required:    []string{"username", "slot"},
+		run:         cliMoveSlot,
+	},
+	"swap-slots": {
+		description: "swap the one touch key slots of two users",
+		flags:       []string{"table", "username", "with"},
+		required:    []string{"username", "with"},
+		run:         cliSwapSlots,
+	},
+	"reserve-slot": {
+		description: "keep a one touch key slot empty",
+		flags:       []string{"table", "slot"},
+		required:    []string{"slot"},
+		run:         cliReserveSlot,
+	},
+	"release-slot": {
+		description: "free a reserved one touch key slot",
+		flags:       []string{"table", "slot"},
+		required:    []string{"slot"},
+		run:         cliReleaseSlot,
+	},
+	"show-slots": {
+		description: "show the one touch key slots of the table, fails if a slot is used more than once",
+		flags:       []string{"table"},
+		run:         cliShowSlots,
+	},
+	"compact": {
+		description: "renumber the users of the table from 1 without gaps",
+		flags:       []string{"table"},
+		run:         cliCompact,
+	},
+	"diff": {
+		description: "show the users added, removed, modified or moved since an exported xml file or another table",
+		flags:       []string{"table", "against"},
+		required:    []string{"against"},
+		run:         cliDiff,
+	},
+	"merge": {
+		description: "insert the users of another table into the table",
+		flags:       []string{"table", "source", "strategy"},
+		required:    []string{"source"},
+		run:         cliMerge,
+	},
+	"import": {
+		description: "import users from a csv, xlsx, vcard or kyocera xml file into the table",
+		flags:       []string{"table", "file", "format", "sheet", "derive", "mode", "map"},
+		required:    []string{"file"},
+		run:         cliImport,
+	},
+	"sync-ldap": {
+		description: "sync the table with the users of an ldap directory",
+		flags:       []string{"table", "config", "mode"},
+		required:    []string{"config"},
+		run:         cliSyncLDAP,
+	},
+	"export": {
+		description: "exports the table to a kyocera xml file",
+		flags:       []string{"table", "out", "schema"},
+		run:         cliExport,
+	},
+	"migrate": {
+		description: "copies every table of a sqlite database into the current backend",
+		flags:       []string{"from"},
+		run:         cliMigrate,
+	},
+	"script": {
+		description: "runs a file of prompt commands, one per line",
+		flags:       []string{"file", "on-error"},
+		required:    []string{"file"},
+		run:         cliScript,
+	},
+}
+
+/*
+	Runs a single command non-interactively, ie
+		kyocera-ab-tool export --table sales --out sales.xml
+
+	The outcome is written to stdout as JSON and usage errors are written to
+	stderr. Returns one of the Exit codes.
+*/
+
+func Run(r db.AddressBookRepository, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" {
+		cliUsage(stderr)
+		if len(args) == 0 {
+			return ExitUsage
+		}
+
+		return ExitOK
+	}
+
+	name := args[0]
+	command, ok := cliCommands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command: %v\n", name)
+		cliUsage(stderr)
+		return ExitUsage
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	values := make(map[string]*string)
+	for _, f := range command.flags {
+		values[f] = fs.String(f, "", cliFlags[f])
+	}
+
+	err := fs.Parse(args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+
+		return ExitUsage
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected argument: %v\n", fs.Arg(0))
+		return ExitUsage
+	}
+
+	o := make(cliOptions)
+	for f, v := range values {
+		o[f] = strings.TrimSpace(*v)
+	}
+
+	for _, f := range command.required {
+		if o[f] == "" {
+			fmt.Fprintf(stderr, "flag -%v is required\n", f)
+			fs.Usage()
+			return ExitUsage
+		}
+	}
+
+	res := &cliResult{Command: name, Table: o["table"]}
+	err = command.run(r, o, res)
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.OK = true
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(res)
+
+	if !res.OK {
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+/*
+	List the non-interactive commands and their flags.
+*/
+
+func cliUsage(w io.Writer) {
+	keys := make([]string, 0, len(cliCommands))
+	for k := range cliCommands {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	fmt.Fprint(w, "\nUsage: kyocera-ab-tool [-backend sqlite|json] [COMMAND [FLAGS]]\n")
+	fmt.Fprint(w, "\nRuns the interactive prompt when no command is given.\n")
+	fmt.Fprint(w, "\nCommands:\n")
+	for _, k := range keys {
+		c := cliCommands[k]
+		fmt.Fprintf(w, "     %-15v : %10v\n", k, c.description)
+		for _, f := range c.flags {
+			fmt.Fprintf(w, "         -%-11v %v\n", f, cliFlags[f])
+		}
+	}
+
+	fmt.Fprint(w, "\n")
+}
+
+/*
+	Switches to the table given by the table flag, the default table is used
+	when the flag is empty.
+*/
+
+func cliUseTable(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	if o["table"] == "" {
+		o["table"] = db.DEFAULT_TABLE
+	}
+
+	res.Table = o["table"]
+	return r.SwitchTable(o["table"])
+}
+
+func cliListTables(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	var err error
+	res.Tables, err = r.ListTables()
+	res.Count = len(res.Tables)
+
+	return err
+}
+
+func cliCreateTable(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	return r.NewTable(o["table"])
+}
+
+func cliDeleteTable(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	return r.DeleteTable(o["table"])
+}
+
+func cliCopyTable(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	return r.CopyTable(o["table"], o["to"])
+}
+
+func cliRenameTable(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	return r.RenameTable(o["table"], o["to"])
+}
+
+func cliClearTable(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	return r.ClearTable()
+}
+
+func cliShowUsers(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	all, err := r.All()
+	res.Users = maskedUsers(all)
+	res.Count = len(res.Users)
+
+	return err
+}
+
+func cliAddUser(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	e, _, err := parseEntry(o["user"])
+	if err != nil {
+		return err
+	}
+
+	e, err = r.Insert(*e)
+	if err != nil {
+		return err
+	}
+
+	res.Users = maskedUsers([]*db.Entry{e})
+	res.Count = 1
+
+	return nil
+}
+
+func cliUpdateUser(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	e, err := mergeEntry(r, o["username"], o["user"])
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Update(o["username"], e)
+	if err != nil {
+		return err
+	}
+
+	res.Count = 1
+
+	return nil
+}
+
+func cliDeleteUser(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.GetByUsername(o["username"])
+	if err != nil {
+		return err
+	}
+
+	err = r.Delete(o["username"])
+	if err != nil {
+		return err
+	}
+
+	res.Count = 1
+
+	return nil
+}
+
+func cliCreateGroup(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	g, err := r.NewGroup(o["group"])
+	if err != nil {
+		return err
+	}
+
+	res.Groups = []cliGroup{{ID: g.ID, Name: g.Name, Members: []string{}}}
+	res.Count = 1
+
+	return nil
+}
+
+func cliAddToGroup(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	err = r.AddToGroup(o["group"], o["username"])
+	if err != nil {
+		return err
+	}
+
+	res.Count = 1
+
+	return nil
+}
+
+func cliShowGroups(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	groups, err := r.Groups()
+	if err != nil {
+		return err
+	}
+
+	entries, err := r.All()
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		members := groupMembers(g, entries)
+		if members == nil {
+			members = []string{}
+		}
+
+		res.Groups = append(res.Groups, cliGroup{ID: g.ID, Name: g.Name,
+			Members: members})
+	}
+
+	res.Count = len(res.Groups)
+
+	return nil
+}
+
+/*
+	Imports a csv, xlsx, vcf or xml file into the table. The format is taken
+	from the format flag or the extension of the file. A csv file, a sheet of
+	an xlsx workbook or a vCard file is imported in a single transaction, the
+	mode flag decides whether a row that cannot be added aborts the import or
+	is skipped, or whether users are upserted.
+*/
+
+func cliImport(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	format := strings.ToLower(o["format"])
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(o["file"])), ".")
+	}
+
+	res.File = o["file"]
+
+	mapping, err := importer.ParseMapping(o["map"])
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "csv":
+		f, err := os.Open(o["file"])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return cliImportRows(r, csvSource(f, mapping), o, res)
+	case "xlsx":
+		wb, err := openXLSX(o["file"])
+		if err != nil {
+			return err
+		}
+
+		return cliImportRows(r, sheetSource(wb, o["sheet"], mapping), o, res)
+	case "vcf":
+		rule, err := importer.ParseUsernameRule(o["derive"])
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(o["file"])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		return cliImportRows(r, vcardSource(f, rule), o, res)
+	case "xml":
+		for _, flag := range []string{"mode", "map", "sheet", "derive"} {
+			if o[flag] != "" {
+				return fmt.Errorf("%w: -%v", ErrXMLImportFlag, flag)
+			}
+		}
+	default:
+		return ErrUnknownFormat
+	}
+
+	f, err := os.Open(o["file"])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, unmatched, err := importer.ImportXML(f)
+	if err != nil {
+		return err
+	}
+
+	n, err := insertEntries(r, entries)
+	if err != nil && n < len(entries) {
+		return fmt.Errorf("%w: %v", err, entries[n].Username)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	res.Count = n
+
+	for _, e := range unmatched {
+		res.Unmatched = append(res.Unmatched, e.Username)
+	}
+
+	return nil
+}
+
+/*
+	Imports the rows of a csv file, a sheet or a vCard file into the table in a single
+	transaction as import_csv does, recording the outcome of each row.
+*/
+
+func cliImportRows(r db.AddressBookRepository, src importSource, o cliOptions, res *cliResult) error {
+	mode := strings.ToLower(o["mode"])
+	switch mode {
+	case "":
+		mode = importAbort
+	case importAbort, importSkip, importUpsert, importSync, importValidate:
+	default:
+		return ErrInvalidMode
+	}
+
+	return cliImportMode(r, src, mode, res)
+}
+
+/*
+	Imports the rows of an importSource into the table in the given mode,
+	one of the importModes or importSyncSkip, recording the outcome of each
+	row.
+*/
+
+func cliImportMode(r db.AddressBookRepository, src importSource, mode string, res *cliResult) error {
+	if mode == importValidate {
+		return cliValidateRows(r, src, res)
+	}
+
+	header, rows, err := src.read()
+	if err != nil {
+		return err
+	}
+
+	res.Columns = header
+	res.Rows, err = importRows(r, rows, header.Fields(), mode)
+	if err != nil {
+		return err
+	}
+
+	res.Count = len(res.Rows) - countLines(res.Rows, "skipped") -
+		countLines(res.Rows, "unchanged")
+
+	return nil
+}
+
+/*
+	Checks the rows of a csv file, a sheet or a vCard file as import_csv does in validate
+	mode. Count is the number of valid rows.
+*/
+
+func cliValidateRows(r db.AddressBookRepository, src importSource, res *cliResult) error {
+	existing, err := r.All()
+	if err != nil {
+		return err
+	}
+
+	report, err := src.validate(existing)
+	if err != nil {
+		return err
+	}
+
+	res.Columns, res.Issues, res.Count = report.Columns, report.Errors, report.Valid
+	if len(report.Errors) > 0 {
+		return ErrInvalidRows
+	}
+
+	return nil
+}
+
+/*
+	Syncs the table with the users of a directory as sync_ldap does. The mode
+	is sync unless upsert, skip or validate is given.
+*/
+
+func cliSyncLDAP(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	res.File = o["config"]
+
+	c, err := openLDAPConfig(o["config"])
+	if err != nil {
+		return err
+	}
+
+	mode := ldapImportMode(o["mode"])
+	if mode == "" {
+		return ErrInvalidLDAPMode
+	}
+
+	return cliImportMode(r, ldapSource(c), mode, res)
+}
+
+func cliMoveSlot(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	slot, err := db.ParseSlot(o["slot"])
+	if err != nil {
+		return err
+	}
+
+	err = r.SetSlot(o["username"], slot)
+	if err != nil {
+		return err
+	}
+
+	res.Count = 1
+
+	return nil
+}
+
+func cliSwapSlots(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	err = r.SwapSlots(o["username"], o["with"])
+	if err != nil {
+		return err
+	}
+
+	res.Count = 2
+
+	return nil
+}
+
+func cliReserveSlot(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	slot, err := db.ParseSlot(o["slot"])
+	if err != nil {
+		return err
+	}
+
+	err = r.ReserveSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	res.Count = 1
+
+	return nil
+}
+
+func cliReleaseSlot(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	slot, err := db.ParseSlot(o["slot"])
+	if err != nil {
+		return err
+	}
+
+	err = r.ReleaseSlot(slot)
+	if err != nil {
+		return err
+	}
+
+	res.Count = 1
+
+	return nil
+}
+
+func cliCompact(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	res.Count, err = r.CompactNumbers()
+
+	return err
+}
+
+func cliShowSlots(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	res.Slots, res.Collisions, err = tableSlots(r)
+	if err != nil {
+		return err
+	}
+
+	res.Count = len(res.Slots)
+	if len(res.Collisions) > 0 {
+		return db.ErrSlotCollision
+	}
+
+	return nil
+}
+
+/*
+	Compares the table against the exported xml file or table given by the
+	against flag.
+*/
+
+func cliDiff(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	res.Diff, err = diffAgainst(r, o["against"])
+	if err != nil {
+		return err
+	}
+
+	res.Count = res.Diff.Count()
+	return nil
+}
+
+/*
+	Merges the table given by the source flag into the table using the
+	strategy flag.
+*/
+
+func cliMerge(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	strategy := db.MergeSkip
+	if o["strategy"] != "" {
+		strategy, err = db.ParseMergeStrategy(o["strategy"])
+		if err != nil {
+			return err
+		}
+	}
+
+	res.Merge, err = db.MergeTable(r, o["source"], strategy)
+	res.Count = res.Merge.Count()
+
+	return err
+}
+
+/*
+	Exports the table to the file given by the out flag or to a new file in the
+	Address Books directory.
+*/
+
+func cliExport(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	err := cliUseTable(r, o, res)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	schema, err := exporter.ParseSchema(o["schema"])
+	if err != nil {
+		return err
+	}
+
+	res.Warning = schema.Warning()
+	res.Count, err = writeAddressBook(r, &buf, schema)
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	if o["out"] == "" {
+		f, err = createFile(r.CurrentTable())
+	} else {
+		f, err = os.Create(o["out"])
+	}
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res.File = f.Name()
+	_, err = buf.WriteTo(f)
+
+	return err
+}
+
+/*
+	Runs the prompt commands in the file given by the file flag. The output of
+	the commands is discarded, the outcome of each one is kept in the result.
+*/
+
+func cliScript(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	var keepGoing bool
+	switch strings.ToLower(o["on-error"]) {
+	case "", "stop":
+	case "continue":
+		keepGoing = true
+	default:
+		return ErrInvalidOnError
+	}
+
+	f, err := os.Open(o["file"])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res.File = o["file"]
+	res.Lines, err = runScript(r, f, io.Discard, keepGoing)
+	res.Count = len(res.Lines)
+	res.Table = r.CurrentTable()
+	if err != nil {
+		return err
+	}
+
+	for _, l := range res.Lines {
+		if !l.OK {
+			return fmt.Errorf("line %d: %v", l.Line, l.Error)
+		}
+	}
+
+	return nil
+}
+
+/*
+	Copies the tables of the SQLite database given by the from flag into the
+	repository, ie to move an address book to the json backend. A binary
+	built without CGO cannot read the database and fails with ErrNoSQLite.
+*/
+
+func cliMigrate(r db.AddressBookRepository, o cliOptions, res *cliResult) error {
+	if !db.SQLiteAvailable {
+		return ErrNoSQLite
+	}
+
+	if o["from"] == "" {
+		o["from"] = DefaultSQLitePath
+	}
+
+	res.File = o["from"]
+
+	src, err := db.OpenSQLite(o["from"])
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	res.Tables, err = src.ListTables()
+	if err != nil {
+		return err
+	}
+
+	res.Count, err = db.CopyTables(r, src)
+
+	return err
+}
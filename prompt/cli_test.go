@@ -0,0 +1,739 @@
+package prompt
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+	"github.com/tweekes0/kyocera-ab-tool/exporter"
+	"github.com/tweekes0/kyocera-ab-tool/importer"
+)
+
+func TestRun(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	csvPath := filepath.Join(dir, "users.csv")
+	err = ioutil.WriteFile(csvPath,
+		[]byte("name,username,email\nJane Doe,janedoe,janedoe@email.com\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csvColumns := &importer.CSVHeader{Used: []importer.Column{
+		{Field: "name", Header: "name", Index: 0},
+		{Field: "username", Header: "username", Index: 1},
+		{Field: "email", Header: "email", Index: 2},
+	}}
+
+	xlsxPath := filepath.Join("testdata", "staff.xlsx")
+
+	vcfPath := filepath.Join(dir, "contacts.vcf")
+	err = ioutil.WriteFile(vcfPath, []byte("BEGIN:VCARD\nVERSION:4.0\n"+
+		"FN:Jill Doe\nEMAIL:jill@email.com\nEND:VCARD\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sync-ldap reads the config before it connects to the directory
+	ldapPath := filepath.Join(dir, "ldap.conf")
+	err = ioutil.WriteFile(ldapPath, []byte("url = ldaps://127.0.0.1\n"+
+		"base_dn = dc=example,dc=com\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlPath := filepath.Join(dir, "out.xml")
+
+	scriptPath := filepath.Join(dir, "script.txt")
+	err = ioutil.WriteFile(scriptPath,
+		[]byte("switch_table sales\nshow_users\nswitch_table hr\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		description string
+		args        []string
+		code        int
+		expected    cliResult
+	}{
+		{
+			description: "list tables",
+			args:        []string{"list-tables"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "list-tables",
+				OK:      true,
+				Count:   1,
+				Tables:  []string{db.DEFAULT_TABLE},
+			},
+		},
+		{
+			description: "create table",
+			args:        []string{"create-table", "--table", "sales"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "create-table",
+				Table:   "sales",
+				OK:      true,
+			},
+		},
+		{
+			description: "copy table",
+			args:        []string{"copy-table", "--table", "sales", "--to", "salez"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "copy-table",
+				Table:   "sales",
+				OK:      true,
+			},
+		},
+		{
+			description: "rename table to existing table",
+			args:        []string{"rename-table", "--table", "salez", "--to", "sales"},
+			code:        ExitError,
+			expected: cliResult{
+				Command: "rename-table",
+				Table:   "salez",
+				Error:   "table already exists",
+			},
+		},
+		{
+			description: "rename table",
+			args:        []string{"rename-table", "--table", "salez", "--to", "support"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "rename-table",
+				Table:   "salez",
+				OK:      true,
+			},
+		},
+		{
+			description: "import csv into table",
+			args:        []string{"import", "--table", "sales", "--file", csvPath},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+				File:    csvPath,
+				Rows: []importLine{{Line: 2, Username: "janedoe",
+					Result: "inserted"}},
+				Columns: csvColumns,
+			},
+		},
+		{
+			description: "import duplicate csv into table",
+			args:        []string{"import", "--table", "sales", "--file", csvPath},
+			code:        ExitError,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				Error:   "line 2: record already exists",
+				File:    csvPath,
+				Rows: []importLine{{Line: 2, Username: "janedoe",
+					Result: "failed", Reason: "record already exists"}},
+				Columns: csvColumns,
+			},
+		},
+		{
+			description: "import duplicate csv into table skipping rows",
+			args: []string{"import", "--table", "sales", "--file", csvPath,
+				"--mode", "skip"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				OK:      true,
+				File:    csvPath,
+				Rows: []importLine{{Line: 2, Username: "janedoe",
+					Result: "skipped", Reason: "record already exists"}},
+				Columns: csvColumns,
+			},
+		},
+		{
+			description: "import csv with an unknown mapped field",
+			args: []string{"import", "--table", "sales", "--file", csvPath,
+				"--map", "phone=Phone Number"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				Error: "column mapping must be FIELD=COLUMN pairs of known " +
+					"fields: phone=Phone Number",
+				File: csvPath,
+			},
+		},
+		{
+			description: "validate csv against table",
+			args: []string{"import", "--table", "sales", "--file", csvPath,
+				"--mode", "validate"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				Error:   "file has rows that are not valid",
+				File:    csvPath,
+				Columns: csvColumns,
+				Issues: importer.RowErrors{
+					{Line: 2, Field: "username", Column: "username",
+						Value: "janedoe", Err: importer.ErrAlreadyInTable},
+					{Line: 2, Field: "email", Column: "email",
+						Value: "janedoe@email.com", Err: importer.ErrAlreadyInTable},
+				},
+			},
+		},
+		{
+			description: "validate sheet of xlsx workbook against table",
+			args: []string{"import", "--table", "sales", "--file", xlsxPath,
+				"--sheet", "Contractors", "--mode", "validate"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+				File:    xlsxPath,
+				Columns: &importer.CSVHeader{Used: []importer.Column{
+					{Field: "name", Header: "Full Name", Index: 0},
+					{Field: "username", Header: "login", Index: 1},
+					{Field: "email", Header: "mail", Index: 2},
+				}},
+			},
+		},
+		{
+			description: "validate vcard file against table",
+			args: []string{"import", "--table", "sales", "--file", vcfPath,
+				"--derive", "name", "--mode", "validate"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+				File:    vcfPath,
+				Columns: &importer.CSVHeader{Used: []importer.Column{
+					{Field: "name", Header: "FN", Index: 0},
+					{Field: "username", Header: "username", Index: 1},
+					{Field: "email", Header: "EMAIL", Index: 2},
+				}},
+			},
+		},
+		{
+			description: "import vcard file with an unknown username rule",
+			args: []string{"import", "--table", "sales", "--file", vcfPath,
+				"--derive", "surname"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				Error:   "username rule must be email, initial or name: surname",
+				File:    vcfPath,
+			},
+		},
+		{
+			description: "sync ldap directory in an unknown mode",
+			args: []string{"sync-ldap", "--table", "sales", "--config",
+				ldapPath, "--mode", "abort"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "sync-ldap",
+				Table:   "sales",
+				Error:   "mode must be sync, upsert, skip or validate",
+				File:    ldapPath,
+			},
+		},
+		{
+			description: "import missing sheet of xlsx workbook",
+			args: []string{"import", "--table", "sales", "--file", xlsxPath,
+				"--sheet", "Sales"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				Error: "sheet is not in the workbook: Sales, sheets are " +
+					"Notes, Staff, Contractors",
+				File: xlsxPath,
+			},
+		},
+		{
+			description: "sync csv into table",
+			args: []string{"import", "--table", "sales", "--file", csvPath,
+				"--mode", "sync"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				OK:      true,
+				File:    csvPath,
+				Rows: []importLine{{Line: 2, Username: "janedoe",
+					Result: "unchanged"}},
+				Columns: csvColumns,
+			},
+		},
+		{
+			description: "export table",
+			args:        []string{"export", "--table", "sales", "--out", xmlPath},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "export",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+				File:    xmlPath,
+			},
+		},
+		{
+			description: "diff table against its export",
+			args:        []string{"diff", "--table", "sales", "--against", xmlPath},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "diff",
+				Table:   "sales",
+				OK:      true,
+				Diff:    &db.TableDiff{},
+			},
+		},
+		{
+			description: "import xml address book with a mode",
+			args: []string{"import", "--table", "sales", "--file", xmlPath,
+				"--mode", "skip"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "import",
+				Table:   "sales",
+				Error: "xml address books are imported whole, flag cannot " +
+					"be given: -mode",
+				File: xmlPath,
+			},
+		},
+		{
+			description: "diff table against a missing table",
+			args:        []string{"diff", "--table", "sales", "--against", "hr"},
+			code:        ExitError,
+			expected: cliResult{
+				Command: "diff",
+				Table:   "sales",
+				Error:   "table does not exist",
+			},
+		},
+		{
+			description: "export table with a schema that is not verified",
+			args: []string{"export", "--table", "sales", "--out", xmlPath,
+				"--schema", "v4_1"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "export",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+				File:    xmlPath,
+				Warning: (&exporter.Schema{Version: "v4_1"}).Warning(),
+			},
+		},
+		{
+			description: "export table with unknown schema",
+			args: []string{"export", "--table", "sales", "--out", xmlPath,
+				"--schema", "v9_9"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "export",
+				Table:   "sales",
+				Error:   "address book schema version is not valid",
+			},
+		},
+		{
+			description: "create group",
+			args:        []string{"create-group", "--table", "sales", "--group", "Sales"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "create-group",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+				Groups:  []cliGroup{{ID: 1, Name: "Sales", Members: []string{}}},
+			},
+		},
+		{
+			description: "add user to group",
+			args: []string{"add-to-group", "--table", "sales", "--group", "Sales",
+				"--username", "janedoe"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "add-to-group",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+			},
+		},
+		{
+			description: "add user to missing group",
+			args: []string{"add-to-group", "--table", "sales", "--group", "HR",
+				"--username", "janedoe"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "add-to-group",
+				Table:   "sales",
+				Error:   "group does not exist",
+			},
+		},
+		{
+			description: "show groups",
+			args:        []string{"show-groups", "--table", "sales"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "show-groups",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+				Groups: []cliGroup{{ID: 1, Name: "Sales",
+					Members: []string{"janedoe"}}},
+			},
+		},
+		{
+			description: "move slot",
+			args: []string{"move-slot", "--table", "sales", "--username", "janedoe",
+				"--slot", "4"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "move-slot",
+				Table:   "sales",
+				OK:      true,
+				Count:   1,
+			},
+		},
+		{
+			description: "reserve taken slot",
+			args:        []string{"reserve-slot", "--table", "sales", "--slot", "4"},
+			code:        ExitError,
+			expected: cliResult{
+				Command: "reserve-slot",
+				Table:   "sales",
+				Error:   "one touch key slot is already in use",
+			},
+		},
+		{
+			description: "show slots",
+			args:        []string{"show-slots", "--table", "sales"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "show-slots",
+				Table:   "sales",
+				OK:      true,
+				Count:   2,
+				Slots: []slotRow{{Slot: 2, Group: "Sales"},
+					{Slot: 4, Username: "janedoe"}},
+			},
+		},
+		{
+			description: "compact table without gaps",
+			args:        []string{"compact", "--table", "sales"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "compact",
+				Table:   "sales",
+				OK:      true,
+			},
+		},
+		{
+			description: "merge table",
+			args: []string{"merge", "--table", "sales", "--source",
+				db.DEFAULT_TABLE},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "merge",
+				Table:   "sales",
+				OK:      true,
+				Count:   3,
+				Merge: &db.MergeReport{Added: []string{"username1", "username2",
+					"username3"}},
+			},
+		},
+		{
+			description: "merge table again",
+			args: []string{"merge", "--table", "sales", "--source",
+				db.DEFAULT_TABLE, "--strategy", "skip"},
+			code: ExitOK,
+			expected: cliResult{
+				Command: "merge",
+				Table:   "sales",
+				OK:      true,
+				Merge: &db.MergeReport{Skipped: []string{"username1", "username2",
+					"username3"}},
+			},
+		},
+		{
+			description: "merge table with unknown strategy",
+			args: []string{"merge", "--table", "sales", "--source",
+				db.DEFAULT_TABLE, "--strategy", "replace"},
+			code: ExitError,
+			expected: cliResult{
+				Command: "merge",
+				Table:   "sales",
+				Error:   "merge strategy must be skip, overwrite or rename",
+			},
+		},
+		{
+			description: "run script",
+			args:        []string{"script", "--file", scriptPath},
+			code:        ExitError,
+			expected: cliResult{
+				Command: "script",
+				Table:   "sales",
+				Error:   "line 3: table does not exist",
+				Count:   3,
+				File:    scriptPath,
+				Lines: []scriptLine{
+					{Line: 1, Command: "switch_table sales", OK: true},
+					{Line: 2, Command: "show_users", OK: true},
+					{Line: 3, Command: "switch_table hr",
+						Error: "table does not exist"},
+				},
+			},
+		},
+		{
+			description: "add invalid user",
+			args:        []string{"add-user", "--user", "jane 1,jdoe,jdoe@email.com"},
+			code:        ExitError,
+			expected: cliResult{
+				Command: "add-user",
+				Table:   db.DEFAULT_TABLE,
+				Error:   "name is not valid",
+			},
+		},
+		{
+			description: "delete user",
+			args:        []string{"delete-user", "--username", "username1"},
+			code:        ExitOK,
+			expected: cliResult{
+				Command: "delete-user",
+				Table:   db.DEFAULT_TABLE,
+				OK:      true,
+				Count:   1,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(repo, tc.args, &stdout, &stderr)
+
+			if code != tc.code {
+				t.Fatalf("got: %v, expected: %v", code, tc.code)
+			}
+
+			var got cliResult
+			err := json.Unmarshal(stdout.Bytes(), &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %+v, expected: %+v", got, tc.expected)
+			}
+		})
+	}
+
+	t.Run("exported file is written", func(t *testing.T) {
+		b, err := ioutil.ReadFile(xmlPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(string(b), `MailAddress="janedoe@email.com"`) {
+			t.Fatalf("got: %v, expected: %v", string(b), "janedoe@email.com")
+		}
+	})
+}
+
+func TestRunMasksPasswords(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	user := "jane doe,jdoe,jdoe@email.com,smb_host=fileserver,smb_path=scans," +
+		"smb_password=smbsecret,ftp_host=ftpserver,ftp_path=/scans," +
+		"ftp_password=ftpsecret"
+
+	tt := []struct {
+		description string
+		args        []string
+	}{
+		{
+			description: "add user",
+			args:        []string{"add-user", "--user", user},
+		},
+		{
+			description: "show users",
+			args:        []string{"show-users"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(repo, tc.args, &stdout, &stderr)
+			if code != ExitOK {
+				t.Fatalf("got: %v, expected: %v", code, ExitOK)
+			}
+
+			got := stdout.String()
+			if strings.Contains(got, "secret") ||
+				strings.Count(got, `"password": "********"`) != 2 {
+				t.Fatalf("got: %v, expected: %v", got, "masked passwords")
+			}
+		})
+	}
+
+	t.Run("passwords are stored", func(t *testing.T) {
+		e, err := repo.GetByUsername("jdoe")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e.SMB.Password != "smbsecret" || e.FTP.Password != "ftpsecret" {
+			t.Fatalf("got: %v, expected: %v", e, "smbsecret and ftpsecret")
+		}
+	})
+}
+
+func TestRunUsage(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	tt := []struct {
+		description string
+		args        []string
+		code        int
+	}{
+		{
+			description: "no command",
+			args:        []string{},
+			code:        ExitUsage,
+		},
+		{
+			description: "unknown command",
+			args:        []string{"unknown"},
+			code:        ExitUsage,
+		},
+		{
+			description: "missing required flag",
+			args:        []string{"import", "--table", db.DEFAULT_TABLE},
+			code:        ExitUsage,
+		},
+		{
+			description: "flag the command does not accept",
+			args:        []string{"list-tables", "--file", "users.csv"},
+			code:        ExitUsage,
+		},
+		{
+			description: "help",
+			args:        []string{"help"},
+			code:        ExitOK,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := Run(repo, tc.args, &stdout, &stderr)
+
+			if code != tc.code {
+				t.Fatalf("got: %v, expected: %v", code, tc.code)
+			}
+
+			if stdout.Len() != 0 {
+				t.Fatalf("got: %v, expected: %v", stdout.String(), "")
+			}
+		})
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sqlitePath := filepath.Join(dir, "sqlite.db")
+	f, err := os.Create(sqlitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	src, err := db.OpenSQLite(sqlitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	err = src.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := db.NewEntry("Jane Doe", "janedoe", "janedoe@email.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = src.Insert(*e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := db.NewJSONRepository(filepath.Join(dir, "address_book.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Initialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := Run(repo, []string{"migrate", "--from", sqlitePath}, &stdout, &stderr)
+	if code != ExitOK {
+		t.Fatalf("got: %v, expected: %v", code, ExitOK)
+	}
+
+	expected := cliResult{
+		Command: "migrate",
+		OK:      true,
+		Count:   1,
+		File:    sqlitePath,
+		Tables:  []string{db.DEFAULT_TABLE},
+	}
+
+	var got cliResult
+	err = json.Unmarshal(stdout.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got: %+v, expected: %+v", got, expected)
+	}
+
+	_, err = repo.GetByUsername("janedoe")
+	if err != nil {
+		t.Fatalf("got: %v, expected: %v", err, nil)
+	}
+}
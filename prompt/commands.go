@@ -1,9 +1,12 @@
 package prompt
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -14,6 +17,12 @@ import (
 	"github.com/tweekes0/kyocera-ab-tool/importer"
 )
 
+var (
+	ErrInvalidFieldCount = errors.New("invalid number of fields")
+	ErrEmptyTable        = errors.New("cannot export empty table")
+	ErrInvalidRows       = errors.New("file has rows that are not valid")
+)
+
 /*
 	List of PcItems that readline structs use for terminal autocompletes.
 */
@@ -23,6 +32,8 @@ var completions = readline.NewPrefixCompleter(
 	readline.PcItem("switch_table"),
 	readline.PcItem("clear_table"),
 	readline.PcItem("delete_table"),
+	readline.PcItem("copy_table"),
+	readline.PcItem("rename_table"),
 	readline.PcItem("export_table"),
 	readline.PcItem("list_tables"),
 	readline.PcItem("show_users"),
@@ -30,6 +41,21 @@ var completions = readline.NewPrefixCompleter(
 	readline.PcItem("delete_user"),
 	readline.PcItem("update_user"),
 	readline.PcItem("import_csv"),
+	readline.PcItem("import_xlsx"),
+	readline.PcItem("import_vcard"),
+	readline.PcItem("import_xml"),
+	readline.PcItem("sync_ldap"),
+	readline.PcItem("create_group"),
+	readline.PcItem("add_to_group"),
+	readline.PcItem("show_groups"),
+	readline.PcItem("move_slot"),
+	readline.PcItem("swap_slots"),
+	readline.PcItem("reserve_slot"),
+	readline.PcItem("release_slot"),
+	readline.PcItem("show_slots"),
+	readline.PcItem("compact_table"),
+	readline.PcItem("diff_table"),
+	readline.PcItem("merge_table"),
 	readline.PcItem("exit"),
 
 	readline.PcItem("help",
@@ -37,6 +63,8 @@ var completions = readline.NewPrefixCompleter(
 		readline.PcItem("switch_table"),
 		readline.PcItem("clear_table"),
 		readline.PcItem("delete_table"),
+		readline.PcItem("copy_table"),
+		readline.PcItem("rename_table"),
 		readline.PcItem("export_table"),
 		readline.PcItem("list_tables"),
 		readline.PcItem("show_users"),
@@ -44,6 +72,21 @@ var completions = readline.NewPrefixCompleter(
 		readline.PcItem("delete_user"),
 		readline.PcItem("update_user"),
 		readline.PcItem("import_csv"),
+		readline.PcItem("import_xlsx"),
+		readline.PcItem("import_vcard"),
+		readline.PcItem("import_xml"),
+		readline.PcItem("sync_ldap"),
+		readline.PcItem("create_group"),
+		readline.PcItem("add_to_group"),
+		readline.PcItem("show_groups"),
+		readline.PcItem("move_slot"),
+		readline.PcItem("swap_slots"),
+		readline.PcItem("reserve_slot"),
+		readline.PcItem("release_slot"),
+		readline.PcItem("show_slots"),
+		readline.PcItem("compact_table"),
+		readline.PcItem("diff_table"),
+		readline.PcItem("merge_table"),
 		readline.PcItem("exit"),
 	),
 )
@@ -72,9 +115,19 @@ var commands = map[string]struct {
 		description: "deletes the specified table",
 		usage:       "delete_table 'TABLE_NAME'",
 	},
+	"copy_table": {
+		description: "copies a table with its users, groups and reserved slots to a new table and sets it to the current table",
+		usage:       "copy_table 'TABLE_NAME,NEW_TABLE_NAME'",
+	},
+	"rename_table": {
+		description: "renames a table, the default table cannot be renamed",
+		usage:       "rename_table 'TABLE_NAME,NEW_TABLE_NAME'",
+	},
 	"export_table": {
 		description: "exports the current table to an xml file in the Address Books directory",
-		usage:       "export_table",
+		usage: "export_table ['SCHEMA_VERSION']\nschema versions: " +
+			strings.Join(exporter.SchemaVersions(), ", ") + " (default: " +
+			exporter.DefaultSchema + ")",
 	},
 	"list_tables": {
 		description: "list all tables",
@@ -86,7 +139,8 @@ var commands = map[string]struct {
 	},
 	"add_user": {
 		description: "add user to the current table. Fields must be separated by commas",
-		usage:       "add_user 'NAME,USERNAME,EMAIL'",
+		usage: "add_user 'NAME,USERNAME,EMAIL[,FIELD=VALUE...]'\nescape a comma in a value with a backslash, ie smb_password=a\\,b\nfields: " +
+			strings.Join(db.DestinationFields, ", "),
 	},
 	"delete_user": {
 		description: "delete a single user from the current table",
@@ -94,11 +148,72 @@ var commands = map[string]struct {
 	},
 	"update_user": {
 		description: "update user in the current table. Fields must be separated by commas",
-		usage:       "update_user 'USERNAME' 'NAME,USERNAME,EMAIL'",
+		usage: "update_user 'USERNAME' 'NAME,USERNAME,EMAIL[,FIELD=VALUE...]'\nfields that are not given keep their values, FIELD= clears one\nfields: " +
+			strings.Join(db.DestinationFields, ", "),
 	},
 	"import_csv": {
-		description: "import users from csv file into current table",
-		usage:       "import_csv 'PATH_TO_FILE'",
+		description: "import users from csv file into current table and report the outcome of each row",
+		usage:       "import_csv 'PATH_TO_FILE[,abort|skip|upsert|sync|validate][,FIELD=COLUMN...]' (default: abort)\nabort rolls back the whole import if a row fails, skip imports the other rows\nupsert updates existing users by username, sync also removes users not in the file\nvalidate reports every problem in the file without importing it\ncolumns can be in any order, FIELD=COLUMN reads a field from a column with another name",
+	},
+	"import_xlsx": {
+		description: "import users from a sheet of an xlsx workbook into current table as import_csv does",
+		usage:       "import_xlsx 'PATH_TO_FILE[,SHEET][,abort|skip|upsert|sync|validate][,FIELD=COLUMN...]' (default: first sheet, abort)\nthe header is the first row of the sheet that is not blank, modes and columns are the same as import_csv",
+	},
+	"import_vcard": {
+		description: "import contacts from a vcard file into current table as import_csv does",
+		usage:       "import_vcard 'PATH_TO_FILE[,email|initial|name][,abort|skip|upsert|sync|validate]' (default: email, abort)\nFN is the name, the preferred EMAIL the email and X-USERNAME the username\ncontacts without a username get one from their email, first initial and family name, or given and family names",
+	},
+	"import_xml": {
+		description: "import contacts from a kyocera address book xml file into current table",
+		usage:       "import_xml 'PATH_TO_FILE'",
+	},
+	"sync_ldap": {
+		description: "sync the users of an ldap directory, ie active directory, into current table",
+		usage:       "sync_ldap 'PATH_TO_CONFIG[,sync|upsert|skip|validate]' (default: sync)\nthe config file has 'key = value' lines for url, bind_dn, password, base_dn, filter,\npage_size, ca_file, insecure_skip_verify, start_tls, allow_plaintext, timeout and map\nsync inserts, updates and removes users so the table matches the directory, skip also skips users that cannot be added\nonly the fields read from the directory are updated",
+	},
+	"create_group": {
+		description: "creates a new group in the current table",
+		usage:       "create_group 'GROUP_NAME'",
+	},
+	"add_to_group": {
+		description: "add user to a group of the current table. Fields must be separated by commas",
+		usage:       "add_to_group 'GROUP_NAME,USERNAME'",
+	},
+	"show_groups": {
+		description: "show all the groups in the current table and their members",
+		usage:       "show_groups",
+	},
+	"move_slot": {
+		description: "move the one touch key of a user to a slot, or remove it with none",
+		usage:       "move_slot 'USERNAME,SLOT'\nslots: 1-" + fmt.Sprint(db.MaxSlot) + " or none",
+	},
+	"swap_slots": {
+		description: "swap the one touch key slots of two users",
+		usage:       "swap_slots 'USERNAME,USERNAME'",
+	},
+	"reserve_slot": {
+		description: "keep a one touch key slot empty",
+		usage:       "reserve_slot 'SLOT'",
+	},
+	"release_slot": {
+		description: "free a reserved one touch key slot",
+		usage:       "release_slot 'SLOT'",
+	},
+	"show_slots": {
+		description: "show the one touch key slots of the current table in order",
+		usage:       "show_slots",
+	},
+	"compact_table": {
+		description: "renumber the users of the current table from 1 without gaps, changing their contact ids",
+		usage:       "compact_table",
+	},
+	"diff_table": {
+		description: "show the users added, removed, modified or moved since an exported xml file or another table",
+		usage:       "diff_table 'PATH_TO_FILE.xml|TABLE_NAME[,json]'",
+	},
+	"merge_table": {
+		description: "insert the users of another table into the current table, handling taken usernames and emails",
+		usage:       "merge_table 'TABLE_NAME[,skip|overwrite|rename]' (default: skip)",
 	},
 	"exit": {
 		description: "exits the program",
@@ -142,10 +257,10 @@ func listCommands(w io.Writer) {
 
 /*
 	Create a new table and write to io.Writer the success or failure
-	of the operation.
+	of the operation. The error is also returned to the caller.
 */
 
-func createTable(r *db.SQLiteRepository, w io.Writer, tableName string) {
+func createTable(r db.AddressBookRepository, w io.Writer, tableName string) error {
 	err := r.NewTable(tableName)
 	if err != nil {
 		OutputMessage(w, '-', err.Error())
@@ -153,24 +268,28 @@ func createTable(r *db.SQLiteRepository, w io.Writer, tableName string) {
 		msg := fmt.Sprintf("%v was created successfully", r.CurrentTable())
 		OutputMessage(w, '+', msg)
 	}
+
+	return err
 }
 
 /*
 	Switch to a table and write to w if the operation fails.
 */
 
-func switchTable(r *db.SQLiteRepository, w io.Writer, tableName string) {
+func switchTable(r db.AddressBookRepository, w io.Writer, tableName string) error {
 	err := r.SwitchTable(tableName)
 	if err != nil {
 		OutputMessage(w, '-', err.Error())
 	}
+
+	return err
 }
 
 /*
 	Display all the users that in the current table.
 */
 
-func showUsers(r *db.SQLiteRepository, w io.Writer) {
+func showUsers(r db.AddressBookRepository, w io.Writer) error {
 	all, err := r.All()
 	switch {
 	case err != nil:
@@ -182,100 +301,166 @@ func showUsers(r *db.SQLiteRepository, w io.Writer) {
 		msg := fmt.Sprintf("users of %v", r.CurrentTable())
 		OutputMessage(w, '+', msg)
 
-		all, err := r.All()
-		if err != nil {
-			OutputMessage(w, '-', err.Error())
-			return
-		}
-
-		tbl := table.New("ID", "Name", "Username", "Email")		
+		tbl := table.New("ID", "Number", "Name", "Username", "Email", "Slot",
+			"Destinations")
 
 		for _, entry := range all {
-			tbl.AddRow(entry.ID, entry.Name, entry.Username, entry.Email)
+			tbl.AddRow(entry.ID, entry.Number, entry.Name, entry.Username,
+				entry.Email, slotString(entry.Slot),
+				strings.Join(entry.Destinations(), ", "))
 		}
 
-		tbl.Print()
+		tbl.WithWriter(w).Print()
 	}
+
+	return err
 }
 
 /*
-	Inserts a new user's Entry into the current table, granted that the
-	params are valid
+	Splits comma separated user fields. A comma that is part of a value is
+	escaped with a backslash, ie smb_password=pass\,word, other backslashes
+	are kept as they are, ie smb_path=scans\jdoe.
 */
 
-func addUser(r *db.SQLiteRepository, w io.Writer, params string) {
-	fields := strings.Split(params, ",")
-	if len(fields) != 3 {
-		msg := "invalid number of fields"
-		OutputMessage(w, '-', msg)
-		return
+func splitFields(params string) []string {
+	var fields []string
+	var b strings.Builder
+	for i := 0; i < len(params); i++ {
+		switch {
+		case params[i] == '\\' && i+1 < len(params) && params[i+1] == ',':
+			b.WriteByte(',')
+			i++
+		case params[i] == ',':
+			fields = append(fields, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(params[i])
+		}
 	}
 
-	for i, field := range fields {
-		fields[i] = strings.TrimSpace(field)
+	return append(fields, strings.TrimSpace(b.String()))
+}
+
+/*
+	Parses comma separated user fields into an Entry. The first three fields
+	are the name, username and email, any that follow are optional fields in
+	the form of key=value, ie smb_host=fileserver. A field with an empty
+	value is left unset. Returns the keys of every field that was given so
+	that update_user only changes those.
+*/
+
+func parseEntry(params string) (*db.Entry, []string, error) {
+	fields := splitFields(params)
+	if len(fields) < 3 {
+		return nil, nil, ErrInvalidFieldCount
 	}
 
 	e, err := db.NewEntry(fields[0], fields[1], fields[2])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := []string{"name", "username", "email"}
+	for _, field := range fields[3:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, nil, db.ErrUnknownField
+		}
+
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if value != "" {
+			err = e.SetField(key, value)
+		} else if !isDestinationField(key) {
+			err = db.ErrUnknownField
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return e, keys, nil
+}
+
+/*
+	Inserts a new user's Entry into the current table, granted that the
+	params are valid
+*/
+
+func addUser(r db.AddressBookRepository, w io.Writer, params string) error {
+	e, _, err := parseEntry(params)
 	if err != nil {
 		OutputMessage(w, '-', err.Error())
-		return
+		return err
 	} else {
 		_, err = r.Insert(*e)
 		if err != nil {
 			OutputMessage(w, '-', err.Error())
-			return
+			return err
 		} else {
 			msg := fmt.Sprintf("%v was added successfully", e.Name)
 			OutputMessage(w, '+', msg)
 		}
 	}
+
+	return nil
 }
 
-func updateUser(r *db.SQLiteRepository, w io.Writer, params string) {
-	p := strings.Split(params, " ")
-	_, err := r.GetByUsername(p[0])
+/*
+	Returns the Entry of a user with the given fields changed, the fields
+	that are not given keep their values.
+*/
 
+func mergeEntry(r db.AddressBookRepository, username, params string) (*db.Entry, error) {
+	e, err := r.GetByUsername(username)
 	if err != nil {
-		OutputMessage(w, '-', err.Error())
-		return
+		return nil, err
 	}
 
-	fields := strings.Split(strings.Join(p[1:], " "), ",")
-	if len(fields) != 3 {
-		msg := "invalid number of fields"
-		OutputMessage(w, '-', msg)
-		return
+	u, keys, err := parseEntry(params)
+	if err != nil {
+		return nil, err
 	}
 
-	for i, field := range fields {
-		fields[i] = strings.TrimSpace(field)
-	}
+	return e, e.MergeFields(u, keys...)
+}
 
-	e, err := db.NewEntry(fields[0], fields[1], fields[2])
+/*
+	Updates the Entry of a user given 'USERNAME NAME,USERNAME,EMAIL', any
+	optional fields that follow are changed and the rest are kept.
+*/
+
+func updateUser(r db.AddressBookRepository, w io.Writer, params string) error {
+	p := strings.Split(params, " ")
+	e, err := mergeEntry(r, p[0], strings.Join(p[1:], " "))
 	if err != nil {
 		OutputMessage(w, '-', err.Error())
-		return
+		return err
 	}
 
 	_, err = r.Update(p[0], e)
 	if err != nil {
 		OutputMessage(w, '-', err.Error())
-		return
+		return err
 	}
 
 	msg := fmt.Sprintf("%v has been updated", e.Name)
 	OutputMessage(w, '+', msg)
+
+	return nil
 }
 
 /*
 	Delete an user's Entry from the database given a valid username.
 */
 
-func deleteUser(r *db.SQLiteRepository, w io.Writer, username string) {
+func deleteUser(r db.AddressBookRepository, w io.Writer, username string) error {
 	e, err := r.GetByUsername(username)
 	if err != nil {
 		OutputMessage(w, '-', err.Error())
-		return
+		return err
 	}
 
 	err = r.Delete(username)
@@ -285,24 +470,89 @@ func deleteUser(r *db.SQLiteRepository, w io.Writer, username string) {
 		msg := fmt.Sprintf("%v was deleted successfully", e.Name)
 		OutputMessage(w, '+', msg)
 	}
+
+	return err
 }
 
 /*
 	Clear all the entries from the current table
 */
 
-func clearTable(r *db.SQLiteRepository, w io.Writer) {
-	r.ClearTable()
+func clearTable(r db.AddressBookRepository, w io.Writer) error {
+	err := r.ClearTable()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
 
 	msg := fmt.Sprintf("%v was cleared successfully", r.CurrentTable())
 	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Parses 'TABLE_NAME,NEW_NAME' into the names of the table and its new name.
+*/
+
+func parseTableNames(params string) (string, string, error) {
+	fields := strings.Split(params, ",")
+	if len(fields) != 2 {
+		return "", "", ErrInvalidFieldCount
+	}
+
+	return strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), nil
+}
+
+/*
+	Parses 'TABLE_NAME,NEW_NAME' and copies the table with its users, groups
+	and reserved slots to a new table that becomes the current table.
+*/
+
+func copyTable(r db.AddressBookRepository, w io.Writer, params string) error {
+	tableName, newName, err := parseTableNames(params)
+	if err == nil {
+		err = r.CopyTable(tableName, newName)
+	}
+
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("%v was copied to %v successfully", tableName, newName)
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Parses 'TABLE_NAME,NEW_NAME' and renames the table. DEFAULT_TABLE cannot
+	be renamed.
+*/
+
+func renameTable(r db.AddressBookRepository, w io.Writer, params string) error {
+	tableName, newName, err := parseTableNames(params)
+	if err == nil {
+		err = r.RenameTable(tableName, newName)
+	}
+
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("%v was renamed to %v successfully", tableName, newName)
+	OutputMessage(w, '+', msg)
+
+	return nil
 }
 
 /*
 	Deletes the specified table. DEFAULT_TABLE cannot be deleted.
 */
 
-func deleteTable(r *db.SQLiteRepository, w io.Writer, tableName string) {
+func deleteTable(r db.AddressBookRepository, w io.Writer, tableName string) error {
 	err := r.DeleteTable(tableName)
 	if err != nil {
 		OutputMessage(w, '-', err.Error())
@@ -310,14 +560,21 @@ func deleteTable(r *db.SQLiteRepository, w io.Writer, tableName string) {
 		msg := fmt.Sprintf("%v was deleted successfully", tableName)
 		OutputMessage(w, '+', msg)
 	}
+
+	return err
 }
 
 /*
 	List all tables, created by the user.
 */
 
-func listTables(r *db.SQLiteRepository, w io.Writer) {
-	t := r.ListTables()
+func listTables(r db.AddressBookRepository, w io.Writer) error {
+	t, err := r.ListTables()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, "Tables:")
 	for _, tt := range t {
@@ -325,69 +582,1128 @@ func listTables(r *db.SQLiteRepository, w io.Writer) {
 	}
 
 	fmt.Fprintln(w)
+
+	return nil
 }
 
 /*
-	Import csv entries into the current table.
+	Inserts entries into the current table in order in a single transaction,
+	so either every entry is inserted or none are. The number of entries
+	inserted is returned along with the error of the entry that failed, so
+	entries[n] is the entry that could not be inserted. n is len(entries)
+	when the transaction fails to commit after every entry was inserted.
 */
 
-func importCSV(r *db.SQLiteRepository, rd io.Reader, w io.Writer) {
-	entries, err := importer.ImportCSV(rd)
-	if err != nil {
-		OutputMessage(w, '-', err.Error())
-		return
+func insertEntries(r db.AddressBookRepository, entries []*db.Entry) (int, error) {
+	n := 0
+	err := r.Transaction(func(tx db.AddressBookRepository) error {
+		for n = range entries {
+			_, err := tx.Insert(*entries[n])
+			if err != nil {
+				return err
+			}
+		}
+
+		n = len(entries)
+		return nil
+	})
+
+	return n, err
+}
+
+/*
+	Modes of importing a csv file.
+		importAbort rolls the import back if any row cannot be inserted
+		importSkip skips the rows that cannot be inserted and keeps the rest
+		importUpsert updates the users already in the table by username and
+		inserts the rest, rolling back if any row fails
+		importSync upserts the rows and removes the users that are not in the
+		file
+		importSyncSkip syncs like importSync but skips the rows that cannot be
+		upserted, ie the skip mode of a directory
+		importValidate checks every row without changing the table
+*/
+
+const (
+	importAbort    = "abort"
+	importSkip     = "skip"
+	importUpsert   = "upsert"
+	importSync     = "sync"
+	importSyncSkip = "sync,skip"
+	importValidate = "validate"
+)
+
+/*
+	All of the modes of importing a csv file.
+*/
+
+var importModes = []string{importAbort, importSkip, importUpsert, importSync,
+	importValidate}
+
+/*
+	Modes of syncing the users of a directory, importSync is the default.
+	importSkip syncs the directory skipping the users that cannot be added.
+*/
+
+var ldapModes = []string{importSync, importUpsert, importSkip, importValidate}
+
+/*
+	Returns the mode of importing the rows of a directory for one of the
+	ldapModes, or an empty string if the mode is not one of them.
+*/
+
+func ldapImportMode(mode string) string {
+	switch strings.ToLower(mode) {
+	case "", importSync:
+		return importSync
+	case importSkip:
+		return importSyncSkip
+	case importUpsert:
+		return importUpsert
+	case importValidate:
+		return importValidate
 	}
 
-	for i, e := range entries {
-		_, err = r.Insert(*e)
-		if err != nil {
-			if errors.Is(err, db.ErrDuplicate) {
-				msg := fmt.Sprintf("Entry on line %d already exists", i+2)
-				OutputMessage(w, '-', msg)
-				return
+	return ""
+}
+
+/*
+	importLine is the outcome of importing a row of a csv file.
+
+	Line: line number of the row in the file, 0 for users that were removed
+	Username: username of the row if it could be read
+	Result: inserted, updated, unchanged, removed, skipped, failed or rolled
+	back
+	Column: column of the value that made the row fail, if there is one
+	Reason: why the row was skipped or failed
+*/
+
+type importLine struct {
+	Line     int    `json:"line"`
+	Username string `json:"username,omitempty"`
+	Result   string `json:"result"`
+	Column   string `json:"column,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+/*
+	Returns the error of a row of a csv file as a RowError, wrapping errors
+	that are not one such as those of Insert.
+*/
+
+func rowError(err error, line int) *importer.RowError {
+	var rowErr *importer.RowError
+	if errors.As(err, &rowErr) {
+		return rowErr
+	}
+
+	return &importer.RowError{Line: line, Err: err}
+}
+
+/*
+	Inserts the rows of a csv file into the current table in a single
+	transaction. In importAbort mode the first row that cannot be inserted
+	rolls back every row and its error is returned, in importSkip mode the
+	row is skipped. importUpsert and importSync update the fields of the
+	users that are already in the table and roll back like importAbort,
+	importSync then removes the users that are not in the file.
+	importSyncSkip skips like importSkip and removes like importSync, keeping
+	the users of the rows that were skipped. Returns the outcome of every row
+	that was tried.
+
+	fields: keys of the fields read from the file, the only fields that are
+	updated
+*/
+
+func importRows(r db.AddressBookRepository, rows []importer.CSVRow, fields []string, mode string) ([]importLine, error) {
+	var lines []importLine
+	err := r.Transaction(func(tx db.AddressBookRepository) error {
+		lines = nil
+		var usernames []string
+		for _, row := range rows {
+			line := importLine{Line: row.Line, Username: row.Username,
+				Result: "inserted"}
+
+			err := row.Err
+			switch {
+			case err != nil:
+			case mode == importUpsert || mode == importSync ||
+				mode == importSyncSkip:
+				var result db.UpsertResult
+				result, err = db.Upsert(tx, *row.Entry, fields...)
+				line.Result = string(result)
+			default:
+				_, err = tx.Insert(*row.Entry)
 			}
 
-			OutputMessage(w, '-', err.Error())
-			return
+			if err != nil {
+				rowErr := rowError(err, row.Line)
+				line.Result = "skipped"
+				line.Column, line.Reason = rowErr.Column, rowErr.Err.Error()
+				if mode != importSkip && mode != importSyncSkip {
+					line.Result = "failed"
+					lines = append(lines, line)
+					return rowErr
+				}
+			}
+
+			lines = append(lines, line)
+			usernames = append(usernames, line.Username)
+		}
+
+		if mode != importSync && mode != importSyncSkip {
+			return nil
+		}
+
+		removed, err := db.RemoveAbsent(tx, usernames)
+		for _, u := range removed {
+			lines = append(lines, importLine{Username: u, Result: "removed"})
+		}
+
+		return err
+	})
+
+	if err != nil {
+		for i := range lines {
+			if lines[i].Result != "failed" {
+				lines[i].Result = "rolled back"
+			}
 		}
 	}
 
-	msg := fmt.Sprintf("import completed successfully. %d entries added.",
-		len(entries))
-	OutputMessage(w, '+', msg)
+	return lines, err
 }
 
 /*
-	Converts the entries within the current table to XML and write it to the
-	out io.Writer
+	Returns the number of rows of an import with the given result.
 */
 
-func exportTable(r *db.SQLiteRepository, w, out io.Writer) {
-	entries, err := r.All()
+func countLines(lines []importLine, result string) int {
+	n := 0
+	for _, l := range lines {
+		if l.Result == result {
+			n++
+		}
+	}
 
-	if err != nil {
-		OutputMessage(w, '-', err.Error())
-		return
+	return n
+}
+
+/*
+	Displays the columns of a csv file that are read into each field and the
+	columns that are ignored.
+*/
+
+func outputColumns(w io.Writer, h *importer.CSVHeader) {
+	var used []string
+	for _, c := range h.Used {
+		used = append(used, fmt.Sprintf("%v (%v)", c.Field, c.Header))
 	}
 
-	book, err := exporter.ExportAddressBook(entries)
-	if err != nil {
-		OutputMessage(w, '-', err.Error())
-		return
+	OutputMessage(w, '+', "columns used: "+strings.Join(used, ", "))
+	if len(h.Ignored) > 0 {
+		OutputMessage(w, '!', "columns ignored: "+strings.Join(h.Ignored, ", "))
+	}
+}
+
+/*
+	importSource reads the rows of a csv file, of a sheet of a workbook, the
+	cards of a vCard file or the users of a directory.
+
+	read: converts every row into a user
+	validate: checks every row against the rules of a user and the users of
+	the current table
+*/
+
+type importSource struct {
+	read     func() (*importer.CSVHeader, []importer.CSVRow, error)
+	validate func(existing []*db.Entry) (*importer.ValidationReport, error)
+}
+
+/*
+	Returns an importSource for a csv file. mapping, which can be nil, names
+	the columns of fields whose column is not found by its name.
+*/
+
+func csvSource(rd io.Reader, mapping importer.ColumnMapping) importSource {
+	return importSource{
+		read: func() (*importer.CSVHeader, []importer.CSVRow, error) {
+			return importer.ReadCSV(rd, mapping)
+		},
+		validate: func(existing []*db.Entry) (*importer.ValidationReport, error) {
+			return importer.ValidateCSV(rd, mapping, existing)
+		},
 	}
+}
 
-	s := exporter.ElementToString(book)
-	out.Write([]byte(s))
+/*
+	Returns an importSource for a sheet of a workbook, the first one if the
+	sheet is empty.
+*/
 
-	msg := "table exported successfully"
-	OutputMessage(w, '+', msg)
+func sheetSource(wb *importer.Workbook, sheet string, mapping importer.ColumnMapping) importSource {
+	return importSource{
+		read: func() (*importer.CSVHeader, []importer.CSVRow, error) {
+			return wb.ReadSheet(sheet, mapping)
+		},
+		validate: func(existing []*db.Entry) (*importer.ValidationReport, error) {
+			return wb.ValidateSheet(sheet, mapping, existing)
+		},
+	}
 }
 
 /*
-	Writes a help message to w, typically os.Stdout
+	Returns an importSource for the cards of a vCard file, rule derives the
+	usernames of cards that do not have one.
 */
 
-func helpUser(w io.Writer) {
-	msg := "type 'help' for a list of commands"
-	OutputMessage(w, '!', msg)
+func vcardSource(rd io.Reader, rule importer.UsernameRule) importSource {
+	return importSource{
+		read: func() (*importer.CSVHeader, []importer.CSVRow, error) {
+			return importer.ReadVCard(rd, rule)
+		},
+		validate: func(existing []*db.Entry) (*importer.ValidationReport, error) {
+			return importer.ValidateVCard(rd, rule, existing)
+		},
+	}
+}
+
+/*
+	Returns an importSource for the users of a directory found by the search
+	of an LDAPConfig.
+*/
+
+func ldapSource(c *importer.LDAPConfig) importSource {
+	return importSource{
+		read: func() (*importer.CSVHeader, []importer.CSVRow, error) {
+			return importer.ReadLDAP(c)
+		},
+		validate: func(existing []*db.Entry) (*importer.ValidationReport, error) {
+			return importer.ValidateLDAP(c, existing)
+		},
+	}
+}
+
+/*
+	Import csv entries into the current table in a single transaction and
+	display the columns that were read and what happened to each row. mode
+	is one of the importModes and mapping, which can be nil, names the
+	columns of fields whose column is not found by its name.
+*/
+
+func importCSV(r db.AddressBookRepository, rd io.Reader, w io.Writer, mode string, mapping importer.ColumnMapping) error {
+	return importFrom(r, w, csvSource(rd, mapping), mode)
+}
+
+/*
+	Import the rows of a sheet of an xlsx workbook into the current table as
+	importCSV does. The first sheet is read if the sheet is empty.
+*/
+
+func importXLSX(r db.AddressBookRepository, wb *importer.Workbook, w io.Writer, sheet, mode string, mapping importer.ColumnMapping) error {
+	return importFrom(r, w, sheetSource(wb, sheet, mapping), mode)
+}
+
+/*
+	Import the cards of a vCard file into the current table as importCSV
+	does, rule derives the usernames of cards that do not have one.
+*/
+
+func importVCard(r db.AddressBookRepository, rd io.Reader, w io.Writer, rule importer.UsernameRule, mode string) error {
+	return importFrom(r, w, vcardSource(rd, rule), mode)
+}
+
+/*
+	Sync the current table with the users of a directory as importCSV does,
+	in importSync mode unless another of the ldapModes is given.
+*/
+
+func syncLDAP(r db.AddressBookRepository, c *importer.LDAPConfig, w io.Writer, mode string) error {
+	return importFrom(r, w, ldapSource(c), ldapImportMode(mode))
+}
+
+/*
+	Import the rows of an importSource into the current table in a single
+	transaction and display the columns that were read and what happened to
+	each row.
+*/
+
+func importFrom(r db.AddressBookRepository, w io.Writer, src importSource, mode string) error {
+	if mode == importValidate {
+		return validateFrom(r, w, src)
+	}
+
+	header, rows, err := src.read()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	outputColumns(w, header)
+
+	lines, err := importRows(r, rows, header.Fields(), mode)
+
+	tbl := table.New("Line", "Username", "Result", "Column", "Reason")
+	for _, l := range lines {
+		line := fmt.Sprint(l.Line)
+		if l.Line == 0 {
+			line = "-"
+		}
+
+		tbl.AddRow(line, l.Username, l.Result, l.Column, l.Reason)
+	}
+
+	tbl.WithWriter(w).Print()
+	fmt.Fprint(w, "\n")
+
+	if err != nil {
+		msg := fmt.Sprintf("import aborted, no entries were added: %v", err)
+		OutputMessage(w, '-', msg)
+		return err
+	}
+
+	msg := fmt.Sprintf("import completed successfully. %d entries added.",
+		countLines(lines, "inserted"))
+	if mode == importUpsert || mode == importSync || mode == importSyncSkip {
+		msg = fmt.Sprintf("import completed successfully. %d entries added, "+
+			"%d updated, %d unchanged, %d removed.",
+			countLines(lines, "inserted"), countLines(lines, "updated"),
+			countLines(lines, "unchanged"), countLines(lines, "removed"))
+	}
+
+	if skipped := countLines(lines, "skipped"); skipped > 0 {
+		msg += fmt.Sprintf(" %d rows skipped.", skipped)
+	}
+
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Checks every row of an importSource against the rules of a user and the
+	users of the current table without adding them, and displays every
+	problem that was found.
+*/
+
+func validateFrom(r db.AddressBookRepository, w io.Writer, src importSource) error {
+	existing, err := r.All()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	report, err := src.validate(existing)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	outputColumns(w, report.Columns)
+
+	if len(report.Errors) == 0 {
+		msg := fmt.Sprintf("all %d rows are valid, no entries were added.",
+			report.Rows)
+		OutputMessage(w, '+', msg)
+		return nil
+	}
+
+	tbl := table.New("Line", "Column", "Value", "Rule")
+	for _, e := range report.Errors {
+		tbl.AddRow(e.Line, e.Column, e.Value, e.Err)
+	}
+
+	tbl.WithWriter(w).Print()
+	fmt.Fprint(w, "\n")
+
+	msg := fmt.Sprintf("%d problems found, %d of %d rows are valid. no "+
+		"entries were added.", len(report.Errors), report.Valid, report.Rows)
+	OutputMessage(w, '-', msg)
+
+	return ErrInvalidRows
+}
+
+/*
+	Import the contacts of a Kyocera address book into the current table and
+	report the contacts that did not have a OneTouchKey.
+*/
+
+func importXML(r db.AddressBookRepository, rd io.Reader, w io.Writer) error {
+	entries, unmatched, err := importer.ImportXML(rd)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	n, err := insertEntries(r, entries)
+	if err != nil {
+		if errors.Is(err, db.ErrDuplicate) && n < len(entries) {
+			msg := fmt.Sprintf("%v already exists", entries[n].Name)
+			OutputMessage(w, '-', msg)
+			return err
+		}
+
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("import completed successfully. %d entries added.",
+		len(entries))
+	OutputMessage(w, '+', msg)
+
+	if len(unmatched) > 0 {
+		names := make([]string, 0, len(unmatched))
+		for _, e := range unmatched {
+			names = append(names, e.Name)
+		}
+
+		msg := fmt.Sprintf("contacts without a one touch key: %v",
+			strings.Join(names, ", "))
+		OutputMessage(w, '!', msg)
+	}
+
+	return nil
+}
+
+/*
+	Converts the entries within the current table to XML and writes it to out.
+	Returns the number of entries that were exported, an empty table cannot
+	be exported.
+*/
+
+func writeAddressBook(r db.AddressBookRepository, out io.Writer, schema *exporter.Schema) (int, error) {
+	entries, err := r.All()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(entries) == 0 {
+		return 0, ErrEmptyTable
+	}
+
+	groups, err := r.Groups()
+	if err != nil {
+		return 0, err
+	}
+
+	reserved, err := r.ReservedSlots()
+	if err != nil {
+		return 0, err
+	}
+
+	book, err := exporter.ExportAddressBook(entries, groups, reserved, schema)
+	if err != nil {
+		return 0, err
+	}
+
+	s := exporter.ElementToString(book)
+	_, err = out.Write([]byte(s))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(entries), nil
+}
+
+/*
+	Converts the entries within the current table to XML and write it to the
+	out io.Writer
+*/
+
+func exportTable(r db.AddressBookRepository, w, out io.Writer, schema *exporter.Schema) error {
+	_, err := writeAddressBook(r, out, schema)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := "table exported successfully"
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Exports the current table in the given schema version to a new file in
+	the Address Books directory. An empty version exports the default schema.
+*/
+
+func exportTableFile(r db.AddressBookRepository, w io.Writer, version string) error {
+	schema, err := exporter.ParseSchema(version)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	all, err := r.All()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	if len(all) == 0 {
+		OutputMessage(w, '-', ErrEmptyTable.Error())
+		return ErrEmptyTable
+	}
+
+	if warning := schema.Warning(); warning != "" {
+		OutputMessage(w, '!', warning)
+	}
+
+	f, err := createFile(r.CurrentTable())
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+	defer f.Close()
+
+	return exportTable(r, w, f, schema)
+}
+
+/*
+	Writes a help message to w, typically os.Stdout
+*/
+
+func helpUser(w io.Writer) {
+	msg := "type 'help' for a list of commands"
+	OutputMessage(w, '!', msg)
+}
+
+/*
+	Create a new group in the current table and write to w the success or
+	failure of the operation.
+*/
+
+func createGroup(r db.AddressBookRepository, w io.Writer, name string) error {
+	g, err := r.NewGroup(name)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("%v was created successfully", g.Name)
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Parses 'GROUP_NAME,USERNAME' and adds the user to the group.
+*/
+
+func addToGroup(r db.AddressBookRepository, w io.Writer, params string) error {
+	fields := strings.Split(params, ",")
+	if len(fields) != 2 {
+		OutputMessage(w, '-', ErrInvalidFieldCount.Error())
+		return ErrInvalidFieldCount
+	}
+
+	name := strings.TrimSpace(fields[0])
+	username := strings.TrimSpace(fields[1])
+
+	err := r.AddToGroup(name, username)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("%v was added to %v", username, name)
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Returns the usernames of the members of a Group, given the Entries of the
+	Group's table.
+*/
+
+func groupMembers(g *db.Group, entries []*db.Entry) []string {
+	usernames := make(map[int64]string)
+	for _, e := range entries {
+		usernames[e.ID] = e.Username
+	}
+
+	var members []string
+	for _, id := range g.Members {
+		members = append(members, usernames[id])
+	}
+
+	return members
+}
+
+/*
+	Display all the groups in the current table and their members.
+*/
+
+func showGroups(r db.AddressBookRepository, w io.Writer) error {
+	groups, err := r.Groups()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	if len(groups) == 0 {
+		msg := fmt.Sprintf("%v has no groups", r.CurrentTable())
+		OutputMessage(w, '!', msg)
+		return nil
+	}
+
+	entries, err := r.All()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("groups of %v", r.CurrentTable())
+	OutputMessage(w, '+', msg)
+
+	tbl := table.New("ID", "Name", "Members")
+	for _, g := range groups {
+		tbl.AddRow(g.ID, g.Name, strings.Join(groupMembers(g, entries), ", "))
+	}
+
+	tbl.WithWriter(w).Print()
+
+	return nil
+}
+
+/*
+	Returns a slot as it is shown to the user.
+*/
+
+func slotString(slot int64) string {
+	if slot == db.NoSlot {
+		return "none"
+	}
+
+	return fmt.Sprint(slot)
+}
+
+/*
+	Parses 'USERNAME,SLOT' and moves the user's one touch key to the slot.
+*/
+
+func moveSlot(r db.AddressBookRepository, w io.Writer, params string) error {
+	fields := strings.Split(params, ",")
+	if len(fields) != 2 {
+		OutputMessage(w, '-', ErrInvalidFieldCount.Error())
+		return ErrInvalidFieldCount
+	}
+
+	username := strings.TrimSpace(fields[0])
+	slot, err := db.ParseSlot(fields[1])
+	if err == nil {
+		err = r.SetSlot(username, slot)
+	}
+
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("%v was moved to slot %v", username, slotString(slot))
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Parses 'USERNAME,USERNAME' and swaps the one touch key slots of the users.
+*/
+
+func swapSlots(r db.AddressBookRepository, w io.Writer, params string) error {
+	fields := strings.Split(params, ",")
+	if len(fields) != 2 {
+		OutputMessage(w, '-', ErrInvalidFieldCount.Error())
+		return ErrInvalidFieldCount
+	}
+
+	username := strings.TrimSpace(fields[0])
+	other := strings.TrimSpace(fields[1])
+
+	err := r.SwapSlots(username, other)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("slots of %v and %v were swapped", username, other)
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Reserves a one touch key slot of the current table.
+*/
+
+func reserveSlot(r db.AddressBookRepository, w io.Writer, param string) error {
+	slot, err := db.ParseSlot(param)
+	if err == nil {
+		err = r.ReserveSlot(slot)
+	}
+
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("slot %v was reserved", slot)
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Releases a reserved one touch key slot of the current table.
+*/
+
+func releaseSlot(r db.AddressBookRepository, w io.Writer, param string) error {
+	slot, err := db.ParseSlot(param)
+	if err == nil {
+		err = r.ReleaseSlot(slot)
+	}
+
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("slot %v was released", slot)
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	slotRow is a slot of a table as it is shown by show_slots and show-slots.
+
+	Slot: the slot, db.NoSlot for users without a one touch key
+	Username: the user whose key is in the slot, empty for reserved slots
+	Key: SMB or FTP when the key is the one of a destination of the user
+	Group: the group whose key is in the slot
+	Reserved: whether the slot is reserved
+*/
+
+type slotRow struct {
+	Slot     int64  `json:"slot"`
+	Username string `json:"username,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Reserved bool   `json:"reserved,omitempty"`
+}
+
+/*
+	Returns the slots of the current table in order, including the keys of
+	the users' destinations and of the groups, keys without a slot are last,
+	along with the slots that collide.
+*/
+
+func tableSlots(r db.AddressBookRepository) ([]slotRow, []db.SlotCollision, error) {
+	entries, err := r.All()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups, err := r.Groups()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reserved, err := r.ReservedSlots()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []slotRow
+	for _, e := range entries {
+		rows = append(rows, slotRow{Slot: e.Slot, Username: e.Username})
+		if e.SMBSlot != 0 {
+			rows = append(rows,
+				slotRow{Slot: e.SMBSlot, Username: e.Username, Key: "SMB"})
+		}
+
+		if e.FTPSlot != 0 {
+			rows = append(rows,
+				slotRow{Slot: e.FTPSlot, Username: e.Username, Key: "FTP"})
+		}
+	}
+
+	for _, g := range groups {
+		rows = append(rows, slotRow{Slot: g.Slot, Group: g.Name})
+	}
+
+	for _, slot := range reserved {
+		rows = append(rows, slotRow{Slot: slot, Reserved: true})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i].Slot, rows[j].Slot
+		if a == db.NoSlot || b == db.NoSlot {
+			return b == db.NoSlot && a != db.NoSlot
+		}
+
+		return a < b
+	})
+
+	return rows, db.SlotCollisions(entries, groups, reserved), nil
+}
+
+/*
+	Display the one touch key slots of the current table in order and warn
+	about the slots that are used more than once.
+*/
+
+func showSlots(r db.AddressBookRepository, w io.Writer) error {
+	rows, collisions, err := tableSlots(r)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	if len(rows) == 0 {
+		msg := fmt.Sprintf("%v has no slots", r.CurrentTable())
+		OutputMessage(w, '!', msg)
+		return nil
+	}
+
+	msg := fmt.Sprintf("slots of %v", r.CurrentTable())
+	OutputMessage(w, '+', msg)
+
+	tbl := table.New("Slot", "Username")
+	for _, row := range rows {
+		username := row.Username
+		switch {
+		case row.Reserved:
+			username = "(reserved)"
+		case row.Group != "":
+			username = fmt.Sprintf("%v (group)", row.Group)
+		case row.Key != "":
+			username = fmt.Sprintf("%v (%v)", row.Username, row.Key)
+		}
+
+		tbl.AddRow(slotString(row.Slot), username)
+	}
+
+	tbl.WithWriter(w).Print()
+
+	for _, c := range collisions {
+		msg := fmt.Sprintf("slot %v is used by %v", c.Slot,
+			strings.Join(append(c.Usernames, c.Groups...), ", "))
+		if c.Reserved {
+			msg += " and is reserved"
+		}
+
+		OutputMessage(w, '!', msg)
+	}
+
+	if len(collisions) > 0 {
+		return db.ErrSlotCollision
+	}
+
+	return nil
+}
+
+/*
+	Renumbers the users of the current table from 1 without gaps. Their
+	contact ids change on the scanner when the table is exported again.
+*/
+
+func compactTable(r db.AddressBookRepository, w io.Writer) error {
+	n, err := r.CompactNumbers()
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("%v users of %v were renumbered", n, r.CurrentTable())
+	OutputMessage(w, '+', msg)
+
+	return nil
+}
+
+/*
+	Returns the entries of another table. The current table is switched back
+	to once they are read.
+*/
+
+func tableEntries(r db.AddressBookRepository, tableName string) ([]*db.Entry, error) {
+	current := r.CurrentTable()
+	err := r.SwitchTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.All()
+	switchErr := r.SwitchTable(current)
+	if err != nil {
+		return nil, err
+	}
+
+	if switchErr != nil {
+		return nil, switchErr
+	}
+
+	return entries, nil
+}
+
+/*
+	Compares the current table against an exported xml file or another table.
+	Sources that end in .xml are read as files, anything else is a table. The
+	passwords of the users that were added or removed are masked.
+*/
+
+func diffAgainst(r db.AddressBookRepository, source string) (*db.TableDiff, error) {
+	var old []*db.Entry
+	var err error
+
+	if strings.EqualFold(filepath.Ext(source), ".xml") {
+		var f *os.File
+		f, err = os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		old, err = importer.ReadAddressBook(f)
+	} else {
+		old, err = tableEntries(r, source)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := r.All()
+	if err != nil {
+		return nil, err
+	}
+
+	d := db.Diff(old, current)
+	d.Added = maskedUsers(d.Added)
+	d.Removed = maskedUsers(d.Removed)
+
+	return d, nil
+}
+
+/*
+	Parses 'SOURCE[,json]' and displays the users that were added, removed,
+	modified or moved in the current table since SOURCE, an exported xml file
+	or another table. The differences are written as JSON when json is given.
+*/
+
+func diffTable(r db.AddressBookRepository, w io.Writer, params string) error {
+	source, asJSON := params, false
+	i := strings.LastIndex(params, ",")
+	if i >= 0 && strings.EqualFold(strings.TrimSpace(params[i+1:]), "json") {
+		source, asJSON = params[:i], true
+	}
+
+	source = strings.TrimSpace(source)
+	d, err := diffAgainst(r, source)
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	if d.Count() == 0 {
+		msg := fmt.Sprintf("%v has not changed since %v", r.CurrentTable(), source)
+		OutputMessage(w, '+', msg)
+		return nil
+	}
+
+	msg := fmt.Sprintf("changes to %v since %v", r.CurrentTable(), source)
+	OutputMessage(w, '+', msg)
+
+	tbl := table.New("Change", "Username", "Field", "Old", "New")
+	for _, e := range d.Added {
+		tbl.AddRow("added", e.Username, "", "", "")
+	}
+
+	for _, e := range d.Removed {
+		tbl.AddRow("removed", e.Username, "", "", "")
+	}
+
+	for _, c := range d.Modified {
+		for _, f := range c.Fields {
+			tbl.AddRow("modified", c.Username, f.Field, f.Old, f.New)
+		}
+	}
+
+	for _, m := range d.Moved {
+		tbl.AddRow("moved", m.Username, "slot", slotString(m.From),
+			slotString(m.To))
+	}
+
+	tbl.WithWriter(w).Print()
+
+	return nil
+}
+
+/*
+	Parses 'SOURCE[,STRATEGY]' and merges the users of the SOURCE table into
+	the current table, then reports what happened to each of them. Users whose
+	username or email is taken are skipped unless the strategy is overwrite or
+	rename.
+*/
+
+func mergeTable(r db.AddressBookRepository, w io.Writer, params string) error {
+	fields := strings.Split(params, ",")
+	if len(fields) > 2 {
+		OutputMessage(w, '-', ErrInvalidFieldCount.Error())
+		return ErrInvalidFieldCount
+	}
+
+	source := strings.TrimSpace(fields[0])
+	strategy := db.MergeSkip
+	if len(fields) == 2 {
+		var err error
+		strategy, err = db.ParseMergeStrategy(fields[1])
+		if err != nil {
+			OutputMessage(w, '-', err.Error())
+			return err
+		}
+	}
+
+	report, err := db.MergeTable(r, source, strategy)
+	if report.Count()+len(report.Skipped) > 0 {
+		tbl := table.New("Username", "Result")
+		for _, username := range report.Added {
+			tbl.AddRow(username, "added")
+		}
+
+		for _, username := range report.Overwritten {
+			tbl.AddRow(username, "overwritten")
+		}
+
+		for _, m := range report.Renamed {
+			tbl.AddRow(m.From, "renamed to "+m.To)
+		}
+
+		for _, username := range report.Skipped {
+			tbl.AddRow(username, "skipped")
+		}
+
+		tbl.WithWriter(w).Print()
+		fmt.Fprint(w, "\n")
+	}
+
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+		return err
+	}
+
+	msg := fmt.Sprintf("%v merged into %v: %d added, %d overwritten, "+
+		"%d renamed, %d skipped", source, r.CurrentTable(), len(report.Added),
+		len(report.Overwritten), len(report.Renamed), len(report.Skipped))
+	OutputMessage(w, '+', msg)
+
+	return nil
 }
@@ -2,10 +2,18 @@ package prompt
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
+	"github.com/rodaine/table"
 	"github.com/tweekes0/kyocera-ab-tool/db"
 	"github.com/tweekes0/kyocera-ab-tool/importer"
 )
@@ -185,7 +193,7 @@ func TestShowUser(t *testing.T) {
 
 	t.Run("show users in the default table after inserts", func(t *testing.T) {
 		var got, expected bytes.Buffer
-		_, err := repo.All()
+		all, err := repo.All()
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -195,6 +203,14 @@ func TestShowUser(t *testing.T) {
 		expected.WriteString(fmt.Sprintf("[+] users of %v\n\n",
 			repo.CurrentTable()))
 
+		tbl := table.New("ID", "Number", "Name", "Username", "Email", "Slot",
+			"Destinations")
+		for _, e := range all {
+			tbl.AddRow(e.ID, e.Number, e.Name, e.Username, e.Email, e.Slot,
+				strings.Join(e.Destinations(), ", "))
+		}
+		tbl.WithWriter(&expected).Print()
+
 		if got.String() != expected.String() {
 			t.Fatalf("got: %v, expected: %v", got.String(), expected.String())
 		}
@@ -239,6 +255,16 @@ func TestAddUser(t *testing.T) {
 			input:       "    jane 1,jdoe,jdoe@email.com",
 			expected:    "[-] name is not valid\n\n",
 		},
+		{
+			description: "add user with smb destination",
+			input:       `jim doe,jimdoe,jimdoe@email.com,smb_host=fileserver,smb_path=scans\jimdoe`,
+			expected:    "[+] Jim Doe was added successfully\n\n",
+		},
+		{
+			description: "add user with unknown field",
+			input:       "jill doe,jilldoe,jilldoe@email.com,phone=5555555",
+			expected:    "[-] field is not valid\n\n",
+		},
 		{
 			description: "add user with too few fields ",
 			input:       "jane 1,jdoe",
@@ -259,6 +285,116 @@ func TestAddUser(t *testing.T) {
 	}
 }
 
+func TestSplitFields(t *testing.T) {
+	tt := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "jane doe, jdoe ,jdoe@email.com",
+			expected: []string{"jane doe", "jdoe", "jdoe@email.com"}},
+		{input: `smb_path=scans\jdoe,smb_password=pass\,word`,
+			expected: []string{`smb_path=scans\jdoe`, "smb_password=pass,word"}},
+		{input: `smb_password=pass\`, expected: []string{`smb_password=pass\`}},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			got := splitFields(tc.input)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %q, expected: %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	var out bytes.Buffer
+	addUser(repo, &out, `jim doe,jimdoe,jimdoe@email.com,smb_host=fileserver,`+
+		`smb_path=scans\jimdoe,smb_login=jimdoe,fax_number=5550100`)
+
+	smb := db.SMBDestination{Host: "fileserver", Path: `scans\jimdoe`,
+		Login: "jimdoe"}
+	fax := db.FaxDestination{Number: "5550100"}
+
+	tt := []struct {
+		description string
+		input       string
+		expected    string
+		smb         db.SMBDestination
+		ftp         db.FTPDestination
+		fax         db.FaxDestination
+	}{
+		{
+			description: "update name keeps destinations",
+			input:       "jimdoe jim smith,jimdoe,jimdoe@email.com",
+			expected:    "[+] Jim Smith has been updated\n\n",
+			smb:         smb,
+			fax:         fax,
+		},
+		{
+			description: "update password with a comma",
+			input: `jimdoe jim smith,jimdoe,jimdoe@email.com,` +
+				`smb_password=pass\,word`,
+			expected: "[+] Jim Smith has been updated\n\n",
+			smb: db.SMBDestination{Host: smb.Host, Path: smb.Path,
+				Login: smb.Login, Password: "pass,word"},
+			fax: fax,
+		},
+		{
+			description: "clear fields",
+			input: "jimdoe jim smith,jimdoe,jimdoe@email.com,smb_login=," +
+				"smb_password=,fax_number=",
+			expected: "[+] Jim Smith has been updated\n\n",
+			smb:      db.SMBDestination{Host: smb.Host, Path: smb.Path},
+		},
+		{
+			description: "clear a field that is needed",
+			input:       "jimdoe jim smith,jimdoe,jimdoe@email.com,smb_host=",
+			expected:    "[-] smb host is not valid\n\n",
+			smb:         db.SMBDestination{Host: smb.Host, Path: smb.Path},
+		},
+		{
+			description: "clear unknown field",
+			input:       "jimdoe jim smith,jimdoe,jimdoe@email.com,phone=",
+			expected:    "[-] field is not valid\n\n",
+			smb:         db.SMBDestination{Host: smb.Host, Path: smb.Path},
+		},
+		{
+			description: "update non-existing user",
+			input:       "jdoe jane doe,jdoe,jdoe@email.com",
+			expected:    "[-] record does not exist\n\n",
+			smb:         db.SMBDestination{Host: smb.Host, Path: smb.Path},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			var got bytes.Buffer
+			updateUser(repo, &got, tc.input)
+
+			if got.String() != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got.String(), tc.expected)
+			}
+
+			e, err := repo.GetByUsername("jimdoe")
+			if err != nil {
+				t.Fatalf("got: %v, expected: %v", err, nil)
+			}
+
+			if e.SMB != tc.smb || e.FTP != tc.ftp || e.Fax != tc.fax {
+				t.Fatalf("got: %v, expected: %v", e, tc)
+			}
+		})
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	repo, teardown := db.SetupWithInserts(t)
 	defer teardown()
@@ -355,6 +491,81 @@ func TestDeleteTable(t *testing.T) {
 	}
 }
 
+func TestCopyAndRenameTable(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	tt := []struct {
+		description string
+		run         func(w *bytes.Buffer) error
+		expected    string
+	}{
+		{
+			description: "copy table",
+			run: func(w *bytes.Buffer) error {
+				return copyTable(repo, w, "default_table, site_a")
+			},
+			expected: "[+] default_table was copied to site_a successfully\n\n",
+		},
+		{
+			description: "copy to existing table",
+			run: func(w *bytes.Buffer) error {
+				return copyTable(repo, w, "site_a,default_table")
+			},
+			expected: "[-] table already exists\n\n",
+		},
+		{
+			description: "copy with wrong field count",
+			run:         func(w *bytes.Buffer) error { return copyTable(repo, w, "site_a") },
+			expected:    fmt.Sprintf("[-] %v\n\n", ErrInvalidFieldCount),
+		},
+		{
+			description: "rename current table",
+			run: func(w *bytes.Buffer) error {
+				return renameTable(repo, w, "site_a,site_b")
+			},
+			expected: "[+] site_a was renamed to site_b successfully\n\n",
+		},
+		{
+			description: "rename default table",
+			run: func(w *bytes.Buffer) error {
+				return renameTable(repo, w, "default_table,main")
+			},
+			expected: "[-] table cannot be renamed\n\n",
+		},
+		{
+			description: "rename to invalid name",
+			run: func(w *bytes.Buffer) error {
+				return renameTable(repo, w, "site_b,--invalid")
+			},
+			expected: "[-] tablename is not valid\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		var got bytes.Buffer
+		tc.run(&got)
+
+		if got.String() != tc.expected {
+			t.Fatalf("%v got: %v, expected: %v", tc.description, got.String(),
+				tc.expected)
+		}
+	}
+
+	if repo.CurrentTable() != "site_b" {
+		t.Fatalf("got: %v, expected: %v", repo.CurrentTable(), "site_b")
+	}
+
+	all, err := repo.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 3 {
+		t.Fatalf("got: %v, expected: %v", len(all), 3)
+	}
+}
+
 func TestListTables(t *testing.T) {
 	repo, teardown := db.SetupWithInserts(t)
 	defer teardown()
@@ -378,6 +589,434 @@ func TestListTables(t *testing.T) {
 	}
 }
 
+func TestGroupCommands(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	var got bytes.Buffer
+	showGroups(repo, &got)
+
+	expected := "[!] default_table has no groups\n\n"
+	if got.String() != expected {
+		t.Fatalf("got: %v, expected: %v", got.String(), expected)
+	}
+
+	tt := []struct {
+		description string
+		run         func(w *bytes.Buffer) error
+		expected    string
+	}{
+		{
+			description: "create group",
+			run:         func(w *bytes.Buffer) error { return createGroup(repo, w, "Sales") },
+			expected:    "[+] Sales was created successfully\n\n",
+		},
+		{
+			description: "create duplicate group",
+			run:         func(w *bytes.Buffer) error { return createGroup(repo, w, "sales") },
+			expected:    "[-] group already exists\n\n",
+		},
+		{
+			description: "add user to group",
+			run: func(w *bytes.Buffer) error {
+				return addToGroup(repo, w, "Sales, username1")
+			},
+			expected: "[+] username1 was added to Sales\n\n",
+		},
+		{
+			description: "add user to missing group",
+			run: func(w *bytes.Buffer) error {
+				return addToGroup(repo, w, "HR,username1")
+			},
+			expected: "[-] group does not exist\n\n",
+		},
+		{
+			description: "add user with wrong field count",
+			run: func(w *bytes.Buffer) error {
+				return addToGroup(repo, w, "Sales")
+			},
+			expected: fmt.Sprintf("[-] %v\n\n", ErrInvalidFieldCount),
+		},
+	}
+
+	for _, tc := range tt {
+		var got bytes.Buffer
+		tc.run(&got)
+
+		if got.String() != tc.expected {
+			t.Fatalf("%v got: %v, expected: %v", tc.description, got.String(),
+				tc.expected)
+		}
+	}
+
+	got.Reset()
+	showGroups(repo, &got)
+
+	var tbl bytes.Buffer
+	table.New("ID", "Name", "Members").WithWriter(&tbl).
+		AddRow(1, "Sales", "username1").Print()
+
+	expected = "[+] groups of default_table\n\n" + tbl.String()
+	if got.String() != expected {
+		t.Fatalf("got: %v, expected: %v", got.String(), expected)
+	}
+}
+
+func TestSlotCommands(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	tt := []struct {
+		description string
+		run         func(w *bytes.Buffer) error
+		expected    string
+	}{
+		{
+			description: "move slot",
+			run:         func(w *bytes.Buffer) error { return moveSlot(repo, w, "username1, 5") },
+			expected:    "[+] username1 was moved to slot 5\n\n",
+		},
+		{
+			description: "move to a taken slot",
+			run:         func(w *bytes.Buffer) error { return moveSlot(repo, w, "username1,3") },
+			expected:    "[-] one touch key slot is already in use\n\n",
+		},
+		{
+			description: "move slot with wrong field count",
+			run:         func(w *bytes.Buffer) error { return moveSlot(repo, w, "username1") },
+			expected:    fmt.Sprintf("[-] %v\n\n", ErrInvalidFieldCount),
+		},
+		{
+			description: "swap slots",
+			run: func(w *bytes.Buffer) error {
+				return swapSlots(repo, w, "username2,username3")
+			},
+			expected: "[+] slots of username2 and username3 were swapped\n\n",
+		},
+		{
+			description: "reserve slot",
+			run:         func(w *bytes.Buffer) error { return reserveSlot(repo, w, "1") },
+			expected:    "[+] slot 1 was reserved\n\n",
+		},
+		{
+			description: "reserve invalid slot",
+			run:         func(w *bytes.Buffer) error { return reserveSlot(repo, w, "first") },
+			expected:    "[-] one touch key slot is not valid\n\n",
+		},
+		{
+			description: "release slot that is not reserved",
+			run:         func(w *bytes.Buffer) error { return releaseSlot(repo, w, "9") },
+			expected:    "[-] one touch key slot is not reserved\n\n",
+		},
+		{
+			description: "remove one touch key",
+			run:         func(w *bytes.Buffer) error { return moveSlot(repo, w, "username3,none") },
+			expected:    "[+] username3 was moved to slot none\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		var got bytes.Buffer
+		tc.run(&got)
+
+		if got.String() != tc.expected {
+			t.Fatalf("%v got: %v, expected: %v", tc.description, got.String(),
+				tc.expected)
+		}
+	}
+
+	t.Run("show slots in order", func(t *testing.T) {
+		var got, tbl bytes.Buffer
+		err := showSlots(repo, &got)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		table.New("Slot", "Username").WithWriter(&tbl).
+			AddRow("1", "(reserved)").
+			AddRow("3", "username2").
+			AddRow("5", "username1").
+			AddRow("none", "username3").Print()
+
+		expected := "[+] slots of default_table\n\n" + tbl.String()
+		if got.String() != expected {
+			t.Fatalf("got: %v, expected: %v", got.String(), expected)
+		}
+	})
+
+	t.Run("export one touch keys in slot order", func(t *testing.T) {
+		var out bytes.Buffer
+		_, err := writeAddressBook(repo, &out, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		xml := out.String()
+		second := strings.Index(xml, `Id="3" AddressId="2"`)
+		first := strings.Index(xml, `Id="5" AddressId="1"`)
+		if second < 0 || first < second {
+			t.Fatalf("got: %v, expected: %v", xml, "keys 3 and 5 in order")
+		}
+
+		if strings.Contains(xml, `AddressId="3"`) {
+			t.Fatalf("got: %v, expected: %v", xml, "no key for username3")
+		}
+	})
+}
+
+func TestDiffTable(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	_, err := repo.Insert(db.Entry{Name: "Test Five", Username: "username5",
+		Email: "test5@test.com",
+		SMB:   db.SMBDestination{Host: "fileserver", Path: "scans"},
+		FTP:   db.FTPDestination{Host: "ftpserver", Path: "/scans"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	xmlPath := filepath.Join(dir, "default_table.xml")
+	f, err := os.Create(xmlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = writeAddressBook(repo, f, nil)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("unchanged table", func(t *testing.T) {
+		var got bytes.Buffer
+		err := diffTable(repo, &got, xmlPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := fmt.Sprintf("[+] default_table has not changed since %v\n\n",
+			xmlPath)
+		if got.String() != expected {
+			t.Fatalf("got: %v, expected: %v", got.String(), expected)
+		}
+	})
+
+	err = repo.SetSlot("username1", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = repo.Delete("username2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Update("username3", &db.Entry{Name: "Test Tres",
+		Username: "username3", Email: "test3@test.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Insert(db.Entry{Name: "Test Four", Username: "username4",
+		Email: "test4@test.com",
+		SMB: db.SMBDestination{Host: "fileserver", Path: "scans",
+			Login: "test4", Password: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("changes since an exported file", func(t *testing.T) {
+		var got, tbl bytes.Buffer
+		err := diffTable(repo, &got, xmlPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		table.New("Change", "Username", "Field", "Old", "New").WithWriter(&tbl).
+			AddRow("added", "username4", "", "", "").
+			AddRow("removed", "test2", "", "", "").
+			AddRow("modified", "username3", "name", "Test Three", "Test Tres").
+			AddRow("moved", "username1", "slot", "1", "8").Print()
+
+		expected := fmt.Sprintf("[+] changes to default_table since %v\n\n",
+			xmlPath) + tbl.String()
+		if got.String() != expected {
+			t.Fatalf("got: %v, expected: %v", got.String(), expected)
+		}
+	})
+
+	t.Run("changes as json", func(t *testing.T) {
+		var got bytes.Buffer
+		err := diffTable(repo, &got, xmlPath+", json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var d db.TableDiff
+		err = json.Unmarshal(got.Bytes(), &d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Count() != 4 || d.Moved[0].To != 8 {
+			t.Fatalf("got: %+v, expected: %v", d, "4 changes")
+		}
+
+		if strings.Contains(got.String(), "secret") {
+			t.Fatalf("got: %v, expected: %v", got.String(), "masked passwords")
+		}
+	})
+
+	t.Run("changes since another table", func(t *testing.T) {
+		err := repo.NewTable("site")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got bytes.Buffer
+		err = diffTable(repo, &got, db.DEFAULT_TABLE)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if repo.CurrentTable() != "site" {
+			t.Fatalf("got: %v, expected: %v", repo.CurrentTable(), "site")
+		}
+
+		if !strings.Contains(got.String(), "removed  username4") {
+			t.Fatalf("got: %v, expected: %v", got.String(), "username4 removed")
+		}
+	})
+
+	t.Run("missing table", func(t *testing.T) {
+		var got bytes.Buffer
+		diffTable(repo, &got, "missing")
+
+		expected := "[-] table does not exist\n\n"
+		if got.String() != expected {
+			t.Fatalf("got: %v, expected: %v", got.String(), expected)
+		}
+	})
+}
+
+func TestMergeTable(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	err := repo.NewTable("site")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site := []db.Entry{
+		{Name: "Site One", Username: "username1", Email: "site1@test.com"},
+		{Name: "Site Two", Username: "siteuser2", Email: "test2@test.com"},
+		{Name: "Test Four", Username: "username4", Email: "test4@test.com"},
+	}
+
+	for _, e := range site {
+		_, err = repo.Insert(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = repo.SwitchTable(db.DEFAULT_TABLE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("merge with rename", func(t *testing.T) {
+		var got, tbl bytes.Buffer
+		err := mergeTable(repo, &got, "site, rename")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		table.New("Username", "Result").WithWriter(&tbl).
+			AddRow("username4", "added").
+			AddRow("username1", "renamed to username1_2").
+			AddRow("siteuser2", "skipped").Print()
+
+		expected := tbl.String() + "\n[+] site merged into default_table: " +
+			"1 added, 0 overwritten, 1 renamed, 1 skipped\n\n"
+		if got.String() != expected {
+			t.Fatalf("got: %v, expected: %v", got.String(), expected)
+		}
+	})
+
+	tt := []struct {
+		description string
+		input       string
+		expected    string
+	}{
+		{
+			description: "merge with unknown strategy",
+			input:       "site,replace",
+			expected:    "[-] merge strategy must be skip, overwrite or rename\n\n",
+		},
+		{
+			description: "merge table into itself",
+			input:       db.DEFAULT_TABLE,
+			expected:    "[-] table cannot be merged into itself\n\n",
+		},
+		{
+			description: "merge missing table",
+			input:       "missing,skip",
+			expected:    "[-] table does not exist\n\n",
+		},
+		{
+			description: "merge with wrong field count",
+			input:       "site,skip,rename",
+			expected:    fmt.Sprintf("[-] %v\n\n", ErrInvalidFieldCount),
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			var got bytes.Buffer
+			mergeTable(repo, &got, tc.input)
+
+			if got.String() != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got.String(), tc.expected)
+			}
+		})
+	}
+}
+
+/*
+	Returns a table as importCSV prints it with the given columns and rows.
+*/
+
+func printedTable(columns []interface{}, rows ...[]interface{}) string {
+	var b bytes.Buffer
+	tbl := table.New(columns...).WithWriter(&b)
+	for _, row := range rows {
+		tbl.AddRow(row...)
+	}
+
+	tbl.Print()
+
+	return b.String() + "\n"
+}
+
+/*
+	Returns the table importCSV prints for the given rows.
+*/
+
+func importReport(rows ...[]interface{}) string {
+	return printedTable([]interface{}{"Line", "Username", "Result", "Column",
+		"Reason"}, rows...)
+}
+
 func TestImportCSV(t *testing.T) {
 	repo, teardown := db.SetupWithInserts(t)
 	defer teardown()
@@ -393,36 +1032,149 @@ func TestImportCSV(t *testing.T) {
 		{"valid name", "janedoe", "janedoe@email.com"},
 	}
 
+	csv3 := [][]string{
+		{"name", "username", "email"},
+		{"Jim Doe", "jimdoe", "jimdoe@email.com"},
+		{"Jane Doe", "janedoe", "janedoe@email.com"},
+		{"Joe Doe", "joedoe", "not an email"},
+	}
+
+	csv4 := [][]string{
+		{"name", "username", "email"},
+		{"Jane Smith", "janedoe", "janedoe@email.com"},
+		{"Jim Doe", "jimdoe", "jimdoe@email.com"},
+		{"Joe Doe", "joedoe", "joedoe@email.com"},
+	}
+
+	csv5 := [][]string{
+		{"mail", "Department", "Preferred Name", "sAMAccountName", "Display Name"},
+		{"jill@email.com", "Sales", "Jill Doe", "jilldoe", "Doe, Jill"},
+	}
+
+	csv6 := [][]string{
+		{"name", "username", "email"},
+		{"Jill Doe", "jilldoe", "jill@email.com"},
+		{"Jack Doe", "jack doe", "jack@email.com"},
+	}
+
+	csv7 := [][]string{
+		{"name", "username", "email"},
+		{"Jack Doe", "jackdoe", "jack@email.com"},
+	}
+
+	columns := "[+] columns used: name (name), username (username), " +
+		"email (email)\n\n"
+
 	tt := []struct {
 		description string
 		input       [][]string
+		mode        string
+		mapping     importer.ColumnMapping
 		expected    string
 	}{
 		{
 			description: "import valid csv",
 			input:       csv1,
-			expected:    "[+] import completed successfully. 2 entries added.\n\n",
+			mode:        importAbort,
+			expected: columns + importReport(
+				[]interface{}{2, "janedoe", "inserted", "", ""},
+				[]interface{}{3, "johndoe", "inserted", "", ""},
+			) + "[+] import completed successfully. 2 entries added.\n\n",
 		},
 		{
 			description: "import csv with invalid header",
 			input:       csv2,
-			expected:    "[-] invalid header\n\n",
+			mode:        importAbort,
+			expected:    "[-] invalid header: missing name column\n\n",
 		},
 		{
 			description: "import csv with existing entry",
-			input:       csv1,
-			expected:    "[-] Entry on line 2 already exists\n\n",
+			input:       csv3,
+			mode:        importAbort,
+			expected: columns + importReport(
+				[]interface{}{2, "jimdoe", "rolled back", "", ""},
+				[]interface{}{3, "janedoe", "failed", "", "record already exists"},
+			) + "[-] import aborted, no entries were added: " +
+				"line 3: record already exists\n\n",
+		},
+		{
+			description: "import csv skipping existing entry",
+			input:       csv3,
+			mode:        importSkip,
+			expected: columns + importReport(
+				[]interface{}{2, "jimdoe", "inserted", "", ""},
+				[]interface{}{3, "janedoe", "skipped", "", "record already exists"},
+				[]interface{}{4, "joedoe", "skipped", "email", "email is not valid"},
+			) + "[+] import completed successfully. 1 entries added. " +
+				"2 rows skipped.\n\n",
+		},
+		{
+			description: "upsert csv",
+			input:       csv4,
+			mode:        importUpsert,
+			expected: columns + importReport(
+				[]interface{}{"2", "janedoe", "updated", "", ""},
+				[]interface{}{"3", "jimdoe", "unchanged", "", ""},
+				[]interface{}{"4", "joedoe", "inserted", "", ""},
+			) + "[+] import completed successfully. 1 entries added, " +
+				"1 updated, 1 unchanged, 0 removed.\n\n",
+		},
+		{
+			description: "sync csv",
+			input:       csv4,
+			mode:        importSync,
+			expected: columns + importReport(
+				[]interface{}{"2", "janedoe", "unchanged", "", ""},
+				[]interface{}{"3", "jimdoe", "unchanged", "", ""},
+				[]interface{}{"4", "joedoe", "unchanged", "", ""},
+				[]interface{}{"-", "username1", "removed", "", ""},
+				[]interface{}{"-", "username2", "removed", "", ""},
+				[]interface{}{"-", "username3", "removed", "", ""},
+				[]interface{}{"-", "johndoe", "removed", "", ""},
+			) + "[+] import completed successfully. 0 entries added, " +
+				"0 updated, 3 unchanged, 4 removed.\n\n",
+		},
+		{
+			description: "import csv with aliases and a mapping",
+			input:       csv5,
+			mode:        importAbort,
+			mapping:     importer.ColumnMapping{"name": "Preferred Name"},
+			expected: "[+] columns used: email (mail), name (Preferred Name), " +
+				"username (sAMAccountName)\n\n" +
+				"[!] columns ignored: Department, Display Name\n\n" +
+				importReport([]interface{}{"2", "jilldoe", "inserted", "", ""}) +
+				"[+] import completed successfully. 1 entries added.\n\n",
+		},
+		{
+			description: "validate csv with problems",
+			input:       csv6,
+			mode:        importValidate,
+			expected: columns + printedTable(
+				[]interface{}{"Line", "Column", "Value", "Rule"},
+				[]interface{}{2, "username", "jilldoe", "already in the table"},
+				[]interface{}{2, "email", "jill@email.com",
+					"already in the table"},
+				[]interface{}{3, "username", "jack doe", "username is not valid"},
+			) + "[-] 3 problems found, 0 of 2 rows are valid. no entries " +
+				"were added.\n\n",
+		},
+		{
+			description: "validate valid csv",
+			input:       csv7,
+			mode:        importValidate,
+			expected: columns + "[+] all 1 rows are valid, no entries " +
+				"were added.\n\n",
 		},
 	}
 
 	for _, tc := range tt {
-
+		tc := tc
 		t.Run(tc.description, func(t *testing.T) {
 			r, td := importer.SetupCSV(t, tc.input)
 			defer td()
 
 			var got bytes.Buffer
-			importCSV(repo, r, &got)
+			importCSV(repo, r, &got, tc.mode, tc.mapping)
 
 			if got.String() != tc.expected {
 				t.Fatalf("got: %v, expected: %v", got.String(), tc.expected)
@@ -432,6 +1184,311 @@ func TestImportCSV(t *testing.T) {
 	}
 }
 
+func TestImportXLSX(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	// Notes holds a row that is not a header, Staff a blank row, the header,
+	// Jane Doe and John Doe, and Contractors the header and Jill Doe
+	wb, err := openXLSX(filepath.Join("testdata", "staff.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := "[+] columns used: name (Display Name), username " +
+		"(sAMAccountName), email (mail)\n\n[!] columns ignored: Office\n\n"
+
+	tt := []struct {
+		description string
+		sheet       string
+		mode        string
+		expected    string
+	}{
+		{
+			description: "import first sheet without a header",
+			sheet:       "",
+			mode:        importAbort,
+			expected:    "[-] invalid header: missing name column\n\n",
+		},
+		{
+			description: "import missing sheet",
+			sheet:       "Sales",
+			mode:        importAbort,
+			expected: "[-] sheet is not in the workbook: Sales, sheets are " +
+				"Notes, Staff, Contractors\n\n",
+		},
+		{
+			description: "validate sheet",
+			sheet:       "Staff",
+			mode:        importValidate,
+			expected: columns + "[+] all 2 rows are valid, no entries " +
+				"were added.\n\n",
+		},
+		{
+			description: "import sheet",
+			sheet:       "Staff",
+			mode:        importAbort,
+			expected: columns + importReport(
+				[]interface{}{3, "janedoe", "inserted", "", ""},
+				[]interface{}{4, "johndoe", "inserted", "", ""},
+			) + "[+] import completed successfully. 2 entries added.\n\n",
+		},
+		{
+			description: "import sheet again skipping existing entries",
+			sheet:       "Staff",
+			mode:        importSkip,
+			expected: columns + importReport(
+				[]interface{}{3, "janedoe", "skipped", "", "record already exists"},
+				[]interface{}{4, "johndoe", "skipped", "", "record already exists"},
+			) + "[+] import completed successfully. 0 entries added. " +
+				"2 rows skipped.\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			var got bytes.Buffer
+			importXLSX(repo, wb, &got, tc.sheet, tc.mode, nil)
+
+			if got.String() != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestImportVCard(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	cards := "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Doe;Jane;;;\r\nFN:Jane Doe\r\n" +
+		"EMAIL;TYPE=INTERNET,pref:jane.doe@email.com\r\nEND:VCARD\r\n" +
+		"BEGIN:VCARD\r\nVERSION:4.0\r\nFN:John\r\n  Doe\r\n" +
+		"EMAIL:john@email.com\r\nX-USERNAME:johndoe\r\nEND:VCARD\r\n"
+
+	columns := "[+] columns used: name (FN), username (username), " +
+		"email (EMAIL)\n\n"
+
+	tt := []struct {
+		description string
+		input       string
+		rule        importer.UsernameRule
+		mode        string
+		expected    string
+	}{
+		{
+			description: "import file that is not a vcard",
+			input:       "name,username,email\n",
+			rule:        importer.UsernameFromEmail,
+			mode:        importAbort,
+			expected:    "[-] file is not a valid vcard: line 1 has no value\n\n",
+		},
+		{
+			description: "validate cards",
+			input:       cards,
+			rule:        importer.UsernameFromInitial,
+			mode:        importValidate,
+			expected: columns + "[+] all 2 rows are valid, no entries " +
+				"were added.\n\n",
+		},
+		{
+			description: "import cards",
+			input:       cards,
+			rule:        importer.UsernameFromInitial,
+			mode:        importAbort,
+			expected: columns + importReport(
+				[]interface{}{1, "jdoe", "inserted", "", ""},
+				[]interface{}{7, "johndoe", "inserted", "", ""},
+			) + "[+] import completed successfully. 2 entries added.\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			var got bytes.Buffer
+			importVCard(repo, strings.NewReader(tc.input), &got, tc.rule, tc.mode)
+
+			if got.String() != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestSyncLDAP(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	u, err := repo.GetByUsername("username1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u.SMB = db.SMBDestination{Host: "fileserver", Path: "scans"}
+	_, err = repo.Update(u.Username, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("sync with an invalid config", func(t *testing.T) {
+		c := &importer.LDAPConfig{URL: "ftp://dc1.example.com",
+			BaseDN: "dc=example,dc=com", Filter: importer.DefaultLDAPFilter}
+
+		var got bytes.Buffer
+		syncLDAP(repo, c, &got, "")
+
+		expected := "[-] ldap config is not valid: url must start with " +
+			"ldap:// or ldaps://\n\n"
+		if got.String() != expected {
+			t.Fatalf("got: %v, expected: %v", got.String(), expected)
+		}
+	})
+
+	// the rows of a directory as ldapSource reads them, the directory itself
+	// is searched by the tests of the importer
+	directory := "displayName,sAMAccountName,mail\n" +
+		"Ann Smith,username1,test1@test.com\n" +
+		"Test Two,username2,test2.test.com\n" +
+		"Jane Doe,janedoe,janedoe@email.com\n"
+
+	columns := "[+] columns used: name (displayName), username " +
+		"(sAMAccountName), email (mail)\n\n"
+
+	tt := []struct {
+		description string
+		mode        string
+		expected    string
+	}{
+		{
+			description: "validate directory",
+			mode:        importValidate,
+			expected: columns + printedTable(
+				[]interface{}{"Line", "Column", "Value", "Rule"},
+				[]interface{}{2, "sAMAccountName", "username1",
+					"already in the table"},
+				[]interface{}{2, "mail", "test1@test.com",
+					"already in the table"},
+				[]interface{}{3, "mail", "test2.test.com", "email is not valid"},
+				[]interface{}{3, "sAMAccountName", "username2",
+					"already in the table"},
+			) + "[-] 4 problems found, 1 of 3 rows are valid. no entries " +
+				"were added.\n\n",
+		},
+		{
+			description: "sync directory with an invalid user",
+			mode:        "",
+			expected: columns + importReport(
+				[]interface{}{"2", "username1", "rolled back", "", ""},
+				[]interface{}{"3", "username2", "failed", "mail",
+					"email is not valid"},
+			) + "[-] import aborted, no entries were added: line 3, " +
+				"column mail: email is not valid\n\n",
+		},
+		{
+			description: "sync directory skipping invalid users",
+			mode:        importSkip,
+			expected: columns + importReport(
+				[]interface{}{"2", "username1", "updated", "", ""},
+				[]interface{}{"3", "username2", "skipped", "mail",
+					"email is not valid"},
+				[]interface{}{"4", "janedoe", "inserted", "", ""},
+				[]interface{}{"-", "username3", "removed", "", ""},
+			) + "[+] import completed successfully. 1 entries added, " +
+				"1 updated, 0 unchanged, 1 removed. 1 rows skipped.\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			var got bytes.Buffer
+			src := csvSource(strings.NewReader(directory), nil)
+			importFrom(repo, &got, src, ldapImportMode(tc.mode))
+
+			if got.String() != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got.String(), tc.expected)
+			}
+		})
+	}
+
+	all, err := repo.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != 3 || all[0].Name != "Ann Smith" || all[0].SMB != u.SMB ||
+		all[1].Username != "username2" {
+		t.Fatalf("got: %v, expected: %v", all,
+			"username1 with its smb destination, username2 and janedoe")
+	}
+}
+
+func TestImportXML(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	xml1 := `<DeviceAddressBook_v5_2>
+    <Item Id="1" Type="Contact" DisplayName="Jane Doe" MailAddress="janedoe@email.com"/>
+    <Item Id="2" Type="Contact" DisplayName="John Doe" MailAddress="johndoe@email.com"/>
+    <Item Id="1" AddressId="1" Type="OneTouchKey" AddressType="EMAIL" DisplayName="Jane Doe"/>
+</DeviceAddressBook_v5_2>`
+
+	xml2 := `<Contacts></Contacts>`
+
+	xml3 := `<DeviceAddressBook_v5_2>
+    <Item Id="1" Type="Contact" DisplayName="Jim Doe" MailAddress="jimdoe@email.com"/>
+    <Item Id="2" Type="Contact" DisplayName="Jane Doe" MailAddress="janedoe@email.com"/>
+</DeviceAddressBook_v5_2>`
+
+	tt := []struct {
+		description string
+		input       string
+		expected    string
+	}{
+		{
+			description: "import valid address book",
+			input:       xml1,
+			expected: "[+] import completed successfully. 2 entries added.\n\n" +
+				"[!] contacts without a one touch key: John Doe\n\n",
+		},
+		{
+			description: "import xml that is not an address book",
+			input:       xml2,
+			expected:    "[-] file is not a kyocera address book\n\n",
+		},
+		{
+			description: "import address book with existing entry",
+			input:       xml1,
+			expected:    "[-] Jane Doe already exists\n\n",
+		},
+		{
+			description: "import address book with an existing entry after a new one",
+			input:       xml3,
+			expected:    "[-] Jane Doe already exists\n\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			var got bytes.Buffer
+			importXML(repo, strings.NewReader(tc.input), &got)
+
+			if got.String() != tc.expected {
+				t.Fatalf("got: %v, expected: %v", got.String(), tc.expected)
+			}
+		})
+	}
+
+	t.Run("failed import adds no entries", func(t *testing.T) {
+		_, err := repo.GetByUsername("jimdoe")
+		if !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("got: %v, expected: %v", err, db.ErrNotFound)
+		}
+	})
+}
+
 func TestHelpUser(t *testing.T) {
 	t.Parallel()
 
@@ -444,3 +1501,63 @@ func TestHelpUser(t *testing.T) {
 		t.Fatalf("got: %v, expected: %v", got.String(), expected)
 	}
 }
+
+/*
+	failingRepository is a fake db.AddressBookRepository whose operations all
+	fail with err.
+*/
+
+type failingRepository struct {
+	db.AddressBookRepository
+	err error
+}
+
+func (f failingRepository) CurrentTable() string            { return db.DEFAULT_TABLE }
+func (f failingRepository) All() ([]*db.Entry, error)       { return nil, f.err }
+func (f failingRepository) ClearTable() error               { return f.err }
+func (f failingRepository) ListTables() ([]string, error)   { return nil, f.err }
+func (f failingRepository) NewTable(tableName string) error { return f.err }
+
+func TestRepositoryFailures(t *testing.T) {
+	repo := failingRepository{err: db.ErrDatabaseLocked}
+	expected := fmt.Sprintf("[-] %v\n\n", db.ErrDatabaseLocked)
+
+	tt := []struct {
+		description string
+		run         func(w *bytes.Buffer) error
+	}{
+		{
+			description: "show users",
+			run:         func(w *bytes.Buffer) error { return showUsers(repo, w) },
+		},
+		{
+			description: "clear table",
+			run:         func(w *bytes.Buffer) error { return clearTable(repo, w) },
+		},
+		{
+			description: "list tables",
+			run:         func(w *bytes.Buffer) error { return listTables(repo, w) },
+		},
+		{
+			description: "create table",
+			run: func(w *bytes.Buffer) error {
+				return createTable(repo, w, "new_table")
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			var got bytes.Buffer
+			err := tc.run(&got)
+			if err != db.ErrDatabaseLocked {
+				t.Fatalf("got: %v, expected: %v", err, db.ErrDatabaseLocked)
+			}
+
+			if got.String() != expected {
+				t.Fatalf("got: %v, expected: %v", got.String(), expected)
+			}
+		})
+	}
+}
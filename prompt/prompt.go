@@ -1,23 +1,29 @@
 package prompt
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/chzyer/readline"
 	"github.com/tweekes0/kyocera-ab-tool/db"
+	"github.com/tweekes0/kyocera-ab-tool/importer"
+)
+
+var (
+	ErrUnknownCommand = errors.New("command is not valid")
+	ErrMissingParam   = errors.New("command requires a parameter")
 )
 
 /*
 	Driver for terminal application
 */
 
-func Prompt(r *db.SQLiteRepository, rd io.ReadCloser, w io.Writer) {
+func Prompt(r db.AddressBookRepository, rd io.ReadCloser, w io.Writer) {
 	l := newReadLine(rd)
 	defer l.Close()
 
-Loop:
 	for {
 		p := fmt.Sprintf("%v» ", r.CurrentTable())
 
@@ -38,73 +44,166 @@ Loop:
 			continue
 		}
 
-		switch {
-		case param == "":
-			switch command {
-			case "clear_table":
-				clearTable(r, w)
-			case "list_tables":
-				listTables(r, w)
-			case "show_users":
-				showUsers(r, w)
-			case "export_table":
-				all, err := r.All()
-				if err != nil {
-					OutputMessage(w, '-', err.Error())
-				}
-
-				if len(all) == 0 {
-					msg := "cannot export empty table"
-					OutputMessage(w, '-', msg)
-					continue
-				}
-
-				f, err := createFile(r.CurrentTable())
-				if err != nil {
-					OutputMessage(w, '-', err.Error())
-					continue
-				}
-
-				exportTable(r, w, f)
-				f.Close()
-			case "help":
-				listCommands(w)
-			case "exit", "quit":
-				break Loop
-			case "create_table", "switch_table", "delete_table", "add_user",
-				"delete_user", "update_user", "import_csv":
-				helpCommand(w, command)
-			default:
-				helpUser(w)
+		exit, _ := execCommand(r, w, command, param)
+		if exit {
+			break
+		}
+	}
+}
+
+/*
+	Runs a single prompt command against the repository and writes its output
+	to w. Returns exit as true when the command ends the session and the error
+	of the command if it failed.
+*/
+
+func execCommand(r db.AddressBookRepository, w io.Writer, command, param string) (exit bool, err error) {
+	switch {
+	case param == "":
+		switch command {
+		case "clear_table":
+			return false, clearTable(r, w)
+		case "list_tables":
+			return false, listTables(r, w)
+		case "show_users":
+			return false, showUsers(r, w)
+		case "show_groups":
+			return false, showGroups(r, w)
+		case "show_slots":
+			return false, showSlots(r, w)
+		case "compact_table":
+			return false, compactTable(r, w)
+		case "export_table":
+			return false, exportTableFile(r, w, "")
+		case "help":
+			listCommands(w)
+		case "exit", "quit":
+			return true, nil
+		case "create_table", "switch_table", "delete_table", "add_user",
+			"delete_user", "update_user", "import_csv", "import_xlsx",
+			"import_vcard", "import_xml", "sync_ldap", "create_group",
+			"add_to_group", "move_slot", "swap_slots", "reserve_slot",
+			"release_slot", "diff_table", "merge_table", "copy_table",
+			"rename_table":
+			helpCommand(w, command)
+			return false, ErrMissingParam
+		default:
+			helpUser(w)
+			return false, ErrUnknownCommand
+		}
+
+	case param != "":
+		switch command {
+		case "create_table":
+			return false, createTable(r, w, param)
+		case "switch_table":
+			return false, switchTable(r, w, param)
+		case "delete_table":
+			return false, deleteTable(r, w, param)
+		case "copy_table":
+			return false, copyTable(r, w, param)
+		case "rename_table":
+			return false, renameTable(r, w, param)
+		case "add_user":
+			return false, addUser(r, w, param)
+		case "delete_user":
+			return false, deleteUser(r, w, param)
+		case "update_user":
+			return false, updateUser(r, w, param)
+		case "create_group":
+			return false, createGroup(r, w, param)
+		case "add_to_group":
+			return false, addToGroup(r, w, param)
+		case "export_table":
+			return false, exportTableFile(r, w, param)
+		case "move_slot":
+			return false, moveSlot(r, w, param)
+		case "swap_slots":
+			return false, swapSlots(r, w, param)
+		case "reserve_slot":
+			return false, reserveSlot(r, w, param)
+		case "release_slot":
+			return false, releaseSlot(r, w, param)
+		case "diff_table":
+			return false, diffTable(r, w, param)
+		case "merge_table":
+			return false, mergeTable(r, w, param)
+		case "import_csv":
+			param, pairs := splitMapping(param)
+			mapping, err := importer.ParseMapping(pairs)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
+			}
 
+			path, mode := splitOption(param, importModes...)
+			f, err := os.Open(path)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
 			}
+			defer f.Close()
 
-		case param != "":
-			switch command {
-			case "create_table":
-				createTable(r, w, param)
-			case "switch_table":
-				switchTable(r, w, param)
-			case "delete_table":
-				deleteTable(r, w, param)
-			case "add_user":
-				addUser(r, w, param)
-			case "delete_user":
-				deleteUser(r, w, param)
-			case "update_user":
-				updateUser(r, w, param)
-			case "import_csv":
-				f, err := os.Open(param)
-				if err != nil {
-					OutputMessage(w, '-', err.Error())
-					continue
-				}
-				importCSV(r, f, w)
-			case "help":
-				helpCommand(w, param)
-			default:
-				helpUser(w)
+			return false, importCSV(r, f, w, mode, mapping)
+		case "import_xlsx":
+			param, pairs := splitMapping(param)
+			mapping, err := importer.ParseMapping(pairs)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
 			}
+
+			param, mode := splitOption(param, importModes...)
+			path, sheet := splitSheet(param)
+			wb, err := openXLSX(path)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
+			}
+
+			return false, importXLSX(r, wb, w, sheet, mode, mapping)
+		case "import_vcard":
+			param, mode := splitOption(param, importModes...)
+			path, option := splitOption(param, importer.UsernameRules...)
+			rule, err := importer.ParseUsernameRule(option)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
+			}
+			defer f.Close()
+
+			return false, importVCard(r, f, w, rule, mode)
+		case "import_xml":
+			f, err := os.Open(param)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
+			}
+			defer f.Close()
+
+			return false, importXML(r, f, w)
+		case "sync_ldap":
+			path, mode := splitOption(param, ldapModes...)
+			c, err := openLDAPConfig(path)
+			if err != nil {
+				OutputMessage(w, '-', err.Error())
+				return false, err
+			}
+
+			return false, syncLDAP(r, c, w, mode)
+		case "help":
+			helpCommand(w, param)
+		default:
+			helpUser(w)
+			return false, ErrUnknownCommand
 		}
 	}
+
+	return false, nil
 }
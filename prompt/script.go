@@ -0,0 +1,99 @@
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rodaine/table"
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+/*
+	scriptLine holds the outcome of a command ran from a script.
+
+	Line: line number of the command in the script
+	Command: the command as it was written in the script
+	OK: whether the command succeeded
+	Error: reason the command failed
+*/
+
+type scriptLine struct {
+	Line    int    `json:"line"`
+	Command string `json:"command"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+/*
+	Runs the prompt commands read from rd in order, one per line, writing the
+	output of each command to w. Blank lines and lines starting with # are
+	skipped. The script stops at the first failed command unless keepGoing is
+	true, or at an exit or quit command. Returns the outcome of every command
+	that was ran.
+*/
+
+func runScript(r db.AddressBookRepository, rd io.Reader, w io.Writer, keepGoing bool) ([]scriptLine, error) {
+	var lines []scriptLine
+
+	sc := bufio.NewScanner(rd)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		command, param := parseArgs(text)
+		fmt.Fprintf(w, "%v» %v\n", r.CurrentTable(), text)
+
+		exit, err := execCommand(r, w, command, param)
+
+		l := scriptLine{Line: n, Command: text, OK: err == nil}
+		if err != nil {
+			l.Error = err.Error()
+		}
+
+		lines = append(lines, l)
+		if exit || (err != nil && !keepGoing) {
+			break
+		}
+	}
+
+	return lines, sc.Err()
+}
+
+/*
+	Runs a script of prompt commands, ie
+		switch_table hr
+		import_csv new.csv
+		export_table
+
+	and writes a summary of each command's result to w once the script is
+	done. Returns false if the script could not be read or any command failed.
+*/
+
+func Script(r db.AddressBookRepository, rd io.Reader, w io.Writer, keepGoing bool) bool {
+	lines, err := runScript(r, rd, w, keepGoing)
+
+	ok := err == nil
+	tbl := table.New("Line", "Command", "Result").WithWriter(w)
+	for _, l := range lines {
+		result := "ok"
+		if !l.OK {
+			result = l.Error
+			ok = false
+		}
+
+		tbl.AddRow(l.Line, l.Command, result)
+	}
+
+	fmt.Fprintln(w)
+	tbl.Print()
+
+	if err != nil {
+		OutputMessage(w, '-', err.Error())
+	}
+
+	return ok
+}
@@ -0,0 +1,126 @@
+package prompt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tweekes0/kyocera-ab-tool/db"
+)
+
+func TestRunScript(t *testing.T) {
+	script := strings.Join([]string{
+		"# create the hr table",
+		"create_table hr",
+		"",
+		"add_user jane doe,jdoe,jdoe@email.com",
+		"add_user jane 1,jdoe1,jdoe1@email.com",
+		"switch_table " + db.DEFAULT_TABLE,
+	}, "\n")
+
+	tt := []struct {
+		description string
+		script      string
+		keepGoing   bool
+		expected    []scriptLine
+	}{
+		{
+			description: "stop on error",
+			script:      script,
+			keepGoing:   false,
+			expected: []scriptLine{
+				{Line: 2, Command: "create_table hr", OK: true},
+				{Line: 4, Command: "add_user jane doe,jdoe,jdoe@email.com", OK: true},
+				{Line: 5, Command: "add_user jane 1,jdoe1,jdoe1@email.com",
+					Error: db.ErrInvalidName.Error()},
+			},
+		},
+		{
+			description: "continue on error",
+			script:      script,
+			keepGoing:   true,
+			expected: []scriptLine{
+				{Line: 2, Command: "create_table hr", OK: true},
+				{Line: 4, Command: "add_user jane doe,jdoe,jdoe@email.com", OK: true},
+				{Line: 5, Command: "add_user jane 1,jdoe1,jdoe1@email.com",
+					Error: db.ErrInvalidName.Error()},
+				{Line: 6, Command: "switch_table " + db.DEFAULT_TABLE, OK: true},
+			},
+		},
+		{
+			description: "stop at exit",
+			script:      "list_tables\nexit\nlist_tables",
+			keepGoing:   false,
+			expected: []scriptLine{
+				{Line: 1, Command: "list_tables", OK: true},
+				{Line: 2, Command: "exit", OK: true},
+			},
+		},
+		{
+			description: "unknown command",
+			script:      "unknown_command",
+			keepGoing:   false,
+			expected: []scriptLine{
+				{Line: 1, Command: "unknown_command",
+					Error: ErrUnknownCommand.Error()},
+			},
+		},
+		{
+			description: "command without its parameter",
+			script:      "switch_table",
+			keepGoing:   false,
+			expected: []scriptLine{
+				{Line: 1, Command: "switch_table",
+					Error: ErrMissingParam.Error()},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.description, func(t *testing.T) {
+			repo, teardown := db.SetupWithInserts(t)
+			defer teardown()
+
+			got, err := runScript(repo, strings.NewReader(tc.script),
+				ioutil.Discard, tc.keepGoing)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("got: %+v, expected: %+v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestScript(t *testing.T) {
+	repo, teardown := db.SetupWithInserts(t)
+	defer teardown()
+
+	t.Run("successful script", func(t *testing.T) {
+		var w bytes.Buffer
+		ok := Script(repo, strings.NewReader("create_table hr\n"), &w, false)
+		if !ok {
+			t.Fatalf("got: %v, expected: %v", ok, true)
+		}
+
+		if !strings.Contains(w.String(), "[+] hr was created successfully") {
+			t.Fatalf("got: %v, expected: %v", w.String(), "hr was created")
+		}
+	})
+
+	t.Run("failed script", func(t *testing.T) {
+		var w bytes.Buffer
+		ok := Script(repo, strings.NewReader("create_table hr\n"), &w, false)
+		if ok {
+			t.Fatalf("got: %v, expected: %v", ok, false)
+		}
+
+		if !strings.Contains(w.String(), db.ErrTableExists.Error()) {
+			t.Fatalf("got: %v, expected: %v", w.String(), db.ErrTableExists)
+		}
+	})
+}
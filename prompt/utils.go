@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"github.com/chzyer/readline"
+	"github.com/tweekes0/kyocera-ab-tool/db"
+	"github.com/tweekes0/kyocera-ab-tool/importer"
 )
 
 /*
@@ -54,6 +57,20 @@ func stripQuotes(s string) string {
 	return s
 }
 
+/*
+	Reports whether s is the key of one of the optional Entry fields.
+*/
+
+func isDestinationField(s string) bool {
+	for _, f := range db.DestinationFields {
+		if s == f {
+			return true
+		}
+	}
+
+	return false
+}
+
 /*
 	Take a from user input and returns two strings a command and it's optional
 	parameter
@@ -80,15 +97,92 @@ func parseArgs(s string) (command string, param string) {
 	return
 }
 
+/*
+	Splits a trailing ',OPTION' off of a parameter, ie 'users.csv,skip', if it
+	is one of the given options. The option is returned in lower case, or
+	empty if the parameter does not end in one.
+*/
+
+func splitOption(param string, options ...string) (string, string) {
+	i := strings.LastIndex(param, ",")
+	if i < 0 {
+		return strings.TrimSpace(param), ""
+	}
+
+	option := strings.ToLower(strings.TrimSpace(param[i+1:]))
+	for _, o := range options {
+		if option == o {
+			return strings.TrimSpace(param[:i]), option
+		}
+	}
+
+	return strings.TrimSpace(param), ""
+}
+
+/*
+	Splits the trailing ',FIELD=COLUMN' pairs off of a parameter, ie
+	'users.csv,skip,name=Full Name,username=login'. Returns the rest of the
+	parameter and the pairs, joined by commas.
+*/
+
+func splitMapping(param string) (string, string) {
+	parts := strings.Split(param, ",")
+	i := len(parts)
+	for i > 1 && strings.Contains(parts[i-1], "=") {
+		i--
+	}
+
+	return strings.Join(parts[:i], ","), strings.Join(parts[i:], ",")
+}
+
+/*
+	Splits the name of a sheet off of the path of an xlsx workbook, ie
+	'users.xlsx,Staff'. The sheet is empty if the path is not followed by one.
+*/
+
+func splitSheet(param string) (string, string) {
+	i := strings.LastIndex(param, ",")
+	if i < 0 || !strings.HasSuffix(strings.ToLower(strings.TrimSpace(param[:i])), ".xlsx") {
+		return strings.TrimSpace(param), ""
+	}
+
+	return strings.TrimSpace(param[:i]), strings.TrimSpace(param[i+1:])
+}
+
+/*
+	Opens the xlsx workbook at a path. The workbook is read into memory so
+	that the file can be closed.
+*/
+
+func openXLSX(path string) (*importer.Workbook, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return importer.OpenXLSX(bytes.NewReader(b), int64(len(b)))
+}
+
+/*
+	Reads the LDAPConfig in the file at a path.
+*/
+
+func openLDAPConfig(path string) (*importer.LDAPConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return importer.ParseLDAPConfig(f)
+}
+
 func createFile(tblName string) (*os.File, error) {
-	_, err := os.Stat("./Address Book")
 	fname := fmt.Sprintf("./Address Books/%v %s.xml",
 		tblName, time.Now().Format("2006-Jan-02"))
 
-	if os.IsNotExist(err) {
-		if err = os.Mkdir("./Address Books", os.ModePerm); err != nil {
-			return nil, err
-		}
+	if err := os.MkdirAll("./Address Books", os.ModePerm); err != nil {
+		return nil, err
 	}
 
 	f, err := os.Create(fname)